@@ -0,0 +1,59 @@
+package todo
+
+import (
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Attachment is a file uploaded to an item. Its content type is sniffed
+// from the content, not taken from the upload.
+type Attachment struct {
+	Id     int `json:"id" db:"id"`
+	ItemId int `json:"item_id" db:"item_id"`
+	// UserId is the uploader, null once they are deleted.
+	UserId      *int      `json:"user_id" db:"user_id"`
+	Filename    string    `json:"filename" db:"filename"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	Size        int64     `json:"size" db:"size"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	// DownloadURL works without authentication until URLExpiresAt.
+	DownloadURL  string     `json:"download_url,omitempty" db:"-"`
+	URLExpiresAt *time.Time `json:"url_expires_at,omitempty" db:"-"`
+
+	StorageKey string `json:"-" db:"storage_key"`
+	// ListId is the list of the item, access to an attachment follows it.
+	ListId int `json:"-" db:"list_id"`
+}
+
+// maxFilenameLength is the most characters kept of an uploaded file name.
+const maxFilenameLength = 255
+
+// CleanFilename keeps the last element of an uploaded file name, without
+// control characters and cut to a sane length.
+func CleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "file"
+	}
+	for utf8.RuneCountInString(name) > maxFilenameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// StorageUsage is how much of their quota the files uploaded by a user take
+// up, in bytes. A quota of 0 is unlimited.
+type StorageUsage struct {
+	Used  int64 `json:"used"`
+	Quota int64 `json:"quota"`
+}
@@ -0,0 +1,17 @@
+package todo
+
+import "time"
+
+const (
+	AuditLoginLocked     = "login_locked"
+	AuditShareLinkLocked = "share_link_locked"
+)
+
+type AuditEntry struct {
+	Id        int       `json:"id" db:"id"`
+	UserId    *int      `json:"user_id" db:"user_id"`
+	Event     string    `json:"event" db:"event"`
+	IP        string    `json:"ip" db:"ip"`
+	Detail    string    `json:"detail" db:"detail"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
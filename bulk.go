@@ -0,0 +1,111 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Actions of a bulk change of items.
+const (
+	BulkComplete = "complete"
+	BulkReopen   = "reopen"
+	BulkDelete   = "delete"
+	BulkMove     = "move"
+	BulkUpdate   = "update"
+)
+
+// Modes of a bulk change. Atomic applies all changes or none of them,
+// partial applies every change that succeeds.
+const (
+	BulkAtomic  = "atomic"
+	BulkPartial = "partial"
+)
+
+// Outcomes of a single item in a bulk change. Skipped items were not
+// changed because another item failed in atomic mode.
+const (
+	BulkStatusOk      = "ok"
+	BulkStatusFailed  = "failed"
+	BulkStatusSkipped = "skipped"
+)
+
+// MaxBulkItems is the most items one bulk change can cover.
+const MaxBulkItems = 500
+
+type BulkItemInput struct {
+	Ids    []int  `json:"ids" binding:"required"`
+	Action string `json:"action" binding:"required"`
+	// Mode defaults to atomic.
+	Mode string `json:"mode"`
+	// Move is required by the move action. The items are placed one after
+	// another in the order of ids, in every list they end up in.
+	Move *MoveItemInput `json:"move"`
+	// Update holds the fields set by the update action.
+	Update *UpdateItemInput `json:"update"`
+}
+
+func (i *BulkItemInput) Validate() error {
+	if len(i.Ids) == 0 || len(i.Ids) > MaxBulkItems {
+		return fmt.Errorf("ids must hold between 1 and %d items", MaxBulkItems)
+	}
+	seen := make(map[int]bool, len(i.Ids))
+	for _, id := range i.Ids {
+		if seen[id] {
+			return fmt.Errorf("item %d is listed more than once", id)
+		}
+		seen[id] = true
+	}
+
+	switch i.Mode {
+	case "", BulkAtomic, BulkPartial:
+	default:
+		return errors.New("mode must be atomic or partial")
+	}
+
+	switch i.Action {
+	case BulkComplete, BulkReopen, BulkDelete:
+	case BulkMove:
+		if i.Move == nil {
+			return errors.New("the move action needs move")
+		}
+	case BulkUpdate:
+		if i.Update == nil {
+			return errors.New("the update action needs update")
+		}
+		return i.Update.Validate()
+	default:
+		return errors.New("action must be one of complete, reopen, delete, move or update")
+	}
+	return nil
+}
+
+// ItemOperation is the change an update or a bulk action makes to one item,
+// as prepared by the service. Update is applied first, then Complete.
+type ItemOperation struct {
+	ItemId   int
+	Delete   bool
+	Move     *MoveItemInput
+	Update   *UpdateItemInput
+	Complete *OccurrenceCompletion
+}
+
+// OccurrenceCompletion completes an occurrence of a recurring item and moves
+// it on to Next, or closes it for good when Next is nil.
+type OccurrenceCompletion struct {
+	Occurrence int
+	Next       *Occurrence
+}
+
+type BulkItemResult struct {
+	Id     int    `json:"id"`
+	Status string `json:"status"`
+	// Code is the HTTP status a request for the item alone would have got.
+	Code  int    `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
+	Err   error  `json:"-"`
+}
+
+type BulkItemReport struct {
+	Mode    string           `json:"mode"`
+	Results []BulkItemResult `json:"results"`
+}
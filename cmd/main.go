@@ -4,7 +4,11 @@ import (
 	"context"
 	"fmt"
 	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/blob"
 	"github.com/Olmosbek510/todo-app/pkg/handler"
+	"github.com/Olmosbek510/todo-app/pkg/mailer"
+	"github.com/Olmosbek510/todo-app/pkg/notifier"
+	"github.com/Olmosbek510/todo-app/pkg/oidc"
 	"github.com/Olmosbek510/todo-app/pkg/repository"
 	"github.com/Olmosbek510/todo-app/pkg/service"
 	"github.com/joho/godotenv"
@@ -13,7 +17,12 @@ import (
 	"github.com/spf13/viper"
 	"os"
 	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
 	"syscall"
+	// time zones of users are validated without relying on the zoneinfo of the host
+	_ "time/tzdata"
 )
 
 // @title Todo App Api
@@ -50,18 +59,125 @@ func main() {
 		logrus.Fatalf("failed to initialize db: %s", err.Error())
 	}
 
+	authConfig := viper.Sub("auth")
+	keys, err := service.NewKeySet(service.KeyConfig{
+		ActiveKid:  authConfig.GetString("jwt.active_kid"),
+		HMACKid:    authConfig.GetString("jwt.hmac_kid"),
+		HMACSecret: os.Getenv("JWT_SIGNING_KEY"),
+		KeysDir:    authConfig.GetString("jwt.keys_dir"),
+	})
+	if err != nil {
+		logrus.Fatalf("failed to load signing keys: %s", err.Error())
+	}
+
+	mailConfig := viper.Sub("mail")
+	mail, err := mailer.New(mailer.Config{
+		Driver:   mailConfig.GetString("driver"),
+		From:     mailConfig.GetString("from"),
+		Host:     mailConfig.GetString("smtp.host"),
+		Port:     mailConfig.GetString("smtp.port"),
+		Username: mailConfig.GetString("smtp.username"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		Path:     mailConfig.GetString("file.path"),
+	})
+	if err != nil {
+		logrus.Fatalf("failed to initialize mailer: %s", err.Error())
+	}
+
+	storageConfig := viper.Sub("storage")
+	store, err := blob.New(blob.Config{
+		Driver:    storageConfig.GetString("driver"),
+		Path:      storageConfig.GetString("local.path"),
+		Endpoint:  storageConfig.GetString("s3.endpoint"),
+		Region:    storageConfig.GetString("s3.region"),
+		Bucket:    storageConfig.GetString("s3.bucket"),
+		AccessKey: storageConfig.GetString("s3.access_key"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+		PathStyle: storageConfig.GetBool("s3.path_style"),
+	})
+	if err != nil {
+		logrus.Fatalf("failed to initialize file storage: %s", err.Error())
+	}
+
+	oidcProviders, err := loadOIDCProviders()
+	if err != nil {
+		logrus.Fatalf("failed to load oidc providers: %s", err.Error())
+	}
+
 	repos := repository.NewRepository(db)
-	services := service.NewService(repos)
+	serviceConfig := service.Config{
+		Keys:                 keys,
+		LegacyPasswordSalt:   authConfig.GetString("legacy_password_salt"),
+		Mailer:               mail,
+		PublicURL:            viper.GetString("public_url"),
+		RequireVerifiedEmail: authConfig.GetBool("require_verified_email"),
+		TOTPIssuer:           authConfig.GetString("totp_issuer"),
+		OIDCProviders:        oidcProviders,
+		Lockout: service.LockoutConfig{
+			UsernameThreshold: authConfig.GetInt("lockout.username_threshold"),
+			IPThreshold:       authConfig.GetInt("lockout.ip_threshold"),
+			BaseDelay:         authConfig.GetDuration("lockout.base_delay"),
+			MaxDelay:          authConfig.GetDuration("lockout.max_delay"),
+			Window:            authConfig.GetDuration("lockout.window"),
+		},
+		MaxItemDepth: viper.GetInt("items.max_depth"),
+		Notifiers:    loadNotifiers(mail),
+		Reminders:    loadDeliveryConfig("reminders"),
+		Comments: service.CommentConfig{
+			EditWindow:     viper.GetDuration("comments.edit_window"),
+			MentionChannel: viper.GetString("comments.mention_channel"),
+			Mentions:       loadDeliveryConfig("comments.mentions"),
+		},
+		Attachments: service.AttachmentConfig{
+			Store:         store,
+			MaxSize:       int64(viper.GetSizeInBytes("attachments.max_size")),
+			Quota:         int64(viper.GetSizeInBytes("attachments.quota")),
+			URLTTL:        viper.GetDuration("attachments.url_ttl"),
+			SweepInterval: viper.GetDuration("attachments.sweep_interval"),
+		},
+		Trash: service.TrashConfig{
+			Retention:     viper.GetDuration("trash.retention"),
+			PurgeInterval: viper.GetDuration("trash.purge_interval"),
+		},
+	}
+	if err := checkWorkerConfig(); err != nil {
+		logrus.Fatalf("invalid worker config: %s", err.Error())
+	}
+	services := service.NewService(repos, serviceConfig)
 	handlers := handler.NewHandler(services)
+	routes, err := handlers.InitRoutes(viper.GetStringSlice("trusted_proxies"))
+	if err != nil {
+		logrus.Fatalf("invalid trusted proxies: %s", err.Error())
+	}
 	srv := new(todo.Server)
 
 	go func() {
-		if err := srv.Run(viper.GetString("port"), handlers.InitRoutes()); err != nil {
+		if err := srv.Run(viper.GetString("port"), routes); err != nil {
 			logrus.Fatalf("error occured while running http server: %s",
 				err.Error())
 		}
 	}()
 
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(4)
+	go func() {
+		defer workers.Done()
+		service.NewReminderWorker(repos.Reminder, serviceConfig).Run(workerCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		service.NewMentionWorker(repos.Comment, serviceConfig).Run(workerCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		service.NewAttachmentSweeper(repos.Attachment, serviceConfig).Run(workerCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		service.NewTrashPurger(repos.Trash, serviceConfig).Run(workerCtx)
+	}()
+
 	logrus.Println("TodoApp Started")
 
 	quit := make(chan os.Signal, 1)
@@ -73,6 +189,10 @@ func main() {
 	if err := srv.ShutDown(context.Background()); err != nil {
 		logrus.Errorf("error occurred on server shutting down: %s", err.Error())
 	}
+	// reminders and mentions being sent and files being removed are finished
+	// before the database goes away
+	stopWorkers()
+	workers.Wait()
 	if err := db.Close(); err != nil {
 		logrus.Errorf("error occurred on server database connection close: %s", err.Error())
 	}
@@ -85,9 +205,86 @@ func initConfig() error {
 	return viper.ReadInConfig()
 }
 
+// loadOIDCProviders reads oidc.providers from the config. Client secrets are
+// taken from OIDC_<NAME>_CLIENT_SECRET.
+func loadOIDCProviders() (map[string]*oidc.Provider, error) {
+	var configs map[string]oidc.Config
+	if err := viper.UnmarshalKey("oidc.providers", &configs); err != nil {
+		return nil, err
+	}
+
+	providers := make(map[string]*oidc.Provider, len(configs))
+	for name, cfg := range configs {
+		cfg.ClientSecret = os.Getenv("OIDC_" + strings.ToUpper(name) + "_CLIENT_SECRET")
+		providers[name] = oidc.NewProvider(cfg)
+	}
+	return providers, nil
+}
+
+// checkWorkerConfig makes sure the background workers have a positive
+// interval to tick at and a batch to work through, time.NewTicker panics on
+// anything else. The trash retention has to be positive as well, the purger
+// would delete everything in the trash right away otherwise.
+func checkWorkerConfig() error {
+	for _, key := range []string{"reminders.poll_interval", "reminders.lease", "comments.mentions.poll_interval",
+		"comments.mentions.lease", "attachments.sweep_interval", "trash.retention", "trash.purge_interval"} {
+		if viper.GetDuration(key) <= 0 {
+			return fmt.Errorf("%s has to be a positive duration, got %q", key, viper.GetString(key))
+		}
+	}
+	for _, key := range []string{"reminders.batch_size", "comments.mentions.batch_size"} {
+		if viper.GetInt(key) <= 0 {
+			return fmt.Errorf("%s has to be positive, got %q", key, viper.GetString(key))
+		}
+	}
+	return nil
+}
+
+// loadDeliveryConfig reads the settings of a notification worker under key.
+func loadDeliveryConfig(key string) service.DeliveryConfig {
+	return service.DeliveryConfig{
+		PollInterval: viper.GetDuration(key + ".poll_interval"),
+		BatchSize:    viper.GetInt(key + ".batch_size"),
+		Lease:        viper.GetDuration(key + ".lease"),
+		MaxAttempts:  viper.GetInt(key + ".max_attempts"),
+		RetryDelay:   viper.GetDuration(key + ".retry_delay"),
+	}
+}
+
+// loadNotifiers sets up the channels reminders can be sent through. The
+// webhook channel is only available with notifications.webhook.url, its
+// requests are signed with WEBHOOK_SECRET.
+func loadNotifiers(mail mailer.Mailer) map[string]notifier.Notifier {
+	notifiers := map[string]notifier.Notifier{
+		notifier.ChannelEmail: notifier.NewEmailNotifier(mail),
+		notifier.ChannelLog:   notifier.NewLogNotifier(),
+	}
+	if url := viper.GetString("notifications.webhook.url"); url != "" {
+		notifiers[notifier.ChannelWebhook] = notifier.NewWebhookNotifier(url, os.Getenv("WEBHOOK_SECRET"),
+			viper.GetDuration("notifications.webhook.timeout"))
+	}
+	return notifiers
+}
+
+// secretPatterns match credentials that may end up in log messages, the
+// first group is kept and the rest replaced.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)("(?:password|new_password|current_password|token|refresh_token|challenge_token|code|secret|client_secret)"\s*:\s*)"[^"]*"`),
+	regexp.MustCompile(`(?i)\b((?:password|new_password|current_password|token|refresh_token|challenge_token|code|secret|client_secret)=)[^&\s]+`),
+	regexp.MustCompile(`(?i)(Bearer\s+)[A-Za-z0-9\-._~+/]+=*`),
+	regexp.MustCompile(`()tdp_[A-Za-z0-9_\-]+`),
+}
+
+func scrubSecrets(message string) string {
+	for _, pattern := range secretPatterns {
+		message = pattern.ReplaceAllString(message, "${1}[REDACTED]")
+	}
+	return message
+}
+
 type SimpleFormatter struct{}
 
 func (f *SimpleFormatter) Format(entry *logrus.Entry) ([]byte, error) {
-	log := fmt.Sprintf("[%s] %s: %s\n", entry.Time.Format("2006-01-02 15:04:05"), entry.Level, entry.Message)
+	log := fmt.Sprintf("[%s] %s: %s\n", entry.Time.Format("2006-01-02 15:04:05"), entry.Level, scrubSecrets(entry.Message))
 	return []byte(log), nil
 }
@@ -0,0 +1,116 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Comment is a message on an item. Comments either start a thread or reply
+// to one, replies to a reply join the thread of that reply.
+type Comment struct {
+	Id       int  `json:"id" db:"id"`
+	ItemId   int  `json:"item_id" db:"item_id"`
+	ParentId *int `json:"parent_id" db:"parent_id"`
+	// UserId and Username are null once the author is deleted.
+	UserId    *int       `json:"user_id" db:"user_id"`
+	Username  *string    `json:"username" db:"username"`
+	Body      string     `json:"body" db:"body"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	EditedAt  *time.Time `json:"edited_at" db:"edited_at"`
+	// ListId is the list of the item, access to a comment follows it.
+	ListId int `json:"-" db:"list_id"`
+	// Replies are only filled in for the comments starting a thread.
+	Replies []Comment `json:"replies,omitempty" db:"-"`
+}
+
+// MaxCommentLength is the most characters a comment can have.
+const MaxCommentLength = 10000
+
+type CreateCommentInput struct {
+	Body string `json:"body" binding:"required"`
+	// ParentId makes the comment a reply.
+	ParentId *int `json:"parent_id"`
+}
+
+func (i *CreateCommentInput) Validate() error {
+	return validateCommentBody(i.Body)
+}
+
+type UpdateCommentInput struct {
+	Body string `json:"body" binding:"required"`
+}
+
+func (i *UpdateCommentInput) Validate() error {
+	return validateCommentBody(i.Body)
+}
+
+func validateCommentBody(body string) error {
+	if strings.TrimSpace(body) == "" {
+		return errors.New("body must not be empty")
+	}
+	if utf8.RuneCountInString(body) > MaxCommentLength {
+		return fmt.Errorf("body must not be longer than %d characters", MaxCommentLength)
+	}
+	return nil
+}
+
+// CommentPage is a page of the threads on an item, the oldest first.
+type CommentPage struct {
+	Data []Comment `json:"data"`
+	// NextAfter is the after parameter of the next page, null on the last
+	// one.
+	NextAfter *int `json:"next_after"`
+}
+
+// Limits of a page of comments.
+const (
+	DefaultCommentPageSize = 20
+	MaxCommentPageSize     = 100
+)
+
+// maxMentions caps the users one comment can notify.
+const maxMentions = 20
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w[\w.-]*)`)
+
+// Mentions returns the usernames mentioned as @username in the body, each
+// once and in the order they appear.
+func Mentions(body string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		// a mention at the end of a sentence
+		username := strings.TrimRight(match[1], ".-")
+		if seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+		if len(usernames) == maxMentions {
+			break
+		}
+	}
+	return usernames
+}
+
+// MentionDelivery is a mention claimed to be sent to the mentioned member
+// of the list. Mentions go through the same states as reminders.
+type MentionDelivery struct {
+	Id       int    `db:"id"`
+	Attempts int    `db:"attempts"`
+	UserId   int    `db:"user_id"`
+	Username string `db:"username"`
+	// Email is only set once it is verified.
+	Email     *string `db:"email"`
+	CommentId int     `db:"comment_id"`
+	// Author is null once the author is deleted.
+	Author *string `db:"author"`
+	Body   string  `db:"body"`
+	ItemId int     `db:"item_id"`
+	ListId int     `db:"list_id"`
+	Title  string  `db:"title"`
+}
@@ -0,0 +1,52 @@
+package todo
+
+import (
+	"errors"
+	"time"
+)
+
+// Invitation states. Expired is not stored, a pending invitation reads as
+// expired once its expiry has passed.
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationExpired  = "expired"
+)
+
+// Invitation offers a role on a list to an existing user or to an email
+// address that may not be registered yet.
+type Invitation struct {
+	Id          int        `json:"id" db:"id"`
+	ListId      int        `json:"list_id" db:"list_id"`
+	ListTitle   string     `json:"list_title" db:"list_title"`
+	InviterId   int        `json:"inviter_id" db:"inviter_id"`
+	Inviter     string     `json:"inviter" db:"inviter"`
+	InviteeId   *int       `json:"invitee_id" db:"invitee_id"`
+	Email       *string    `json:"email" db:"email"`
+	Role        string     `json:"role" db:"role"`
+	Status      string     `json:"status" db:"status"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at" db:"responded_at"`
+}
+
+type CreateInvitationInput struct {
+	Username string `json:"username"`
+	Email    string `json:"email" binding:"omitempty,email"`
+	Role     string `json:"role" binding:"required"`
+}
+
+func (i *CreateInvitationInput) Validate() error {
+	if (i.Username == "") == (i.Email == "") {
+		return errors.New("either username or email is required")
+	}
+	if !ValidListRole(i.Role) {
+		return errors.New("role must be one of owner, editor or viewer")
+	}
+	return nil
+}
+
+type AcceptInvitationInput struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -0,0 +1,72 @@
+package todo
+
+import (
+	"errors"
+	"regexp"
+)
+
+// Label tags items. Labels of a workspace can be used by all of its members
+// on the items of its lists, the other labels belong to the user that
+// created them and can be put on any item they can edit.
+type Label struct {
+	Id          int    `json:"id" db:"id"`
+	Name        string `json:"name" db:"name"`
+	Color       string `json:"color" db:"color"`
+	WorkspaceId *int   `json:"workspace_id" db:"workspace_id"`
+}
+
+var labelColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func validateLabel(name, color *string) error {
+	if name != nil && (*name == "" || len(*name) > 64) {
+		return errors.New("name must be between 1 and 64 characters")
+	}
+	if color != nil && !labelColor.MatchString(*color) {
+		return errors.New("color must be a hex colour such as #ff8800")
+	}
+	return nil
+}
+
+type CreateLabelInput struct {
+	Name  string `json:"name" binding:"required"`
+	Color string `json:"color" binding:"required"`
+	// WorkspaceId shares the label with a workspace instead of keeping it
+	// personal, it needs the editor role there.
+	WorkspaceId *int `json:"workspace_id"`
+}
+
+func (i *CreateLabelInput) Validate() error {
+	return validateLabel(&i.Name, &i.Color)
+}
+
+type UpdateLabelInput struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+func (i *UpdateLabelInput) Validate() error {
+	if i.Name == nil && i.Color == nil {
+		return errors.New("update structure has no values")
+	}
+	return validateLabel(i.Name, i.Color)
+}
+
+// Priorities of items, higher is more important.
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+func ValidPriority(priority int) bool {
+	return priority >= PriorityNone && priority <= PriorityHigh
+}
+
+var ErrInvalidPriority = errors.New("priority must be between 0 (none) and 3 (high)")
+
+// ItemFilter narrows down the items of a list, nil fields match every item.
+type ItemFilter struct {
+	LabelId  *int
+	Priority *int
+}
@@ -0,0 +1,151 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestLocalStore(t *testing.T) (*LocalStore, string) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, dir
+}
+
+func readBlob(t *testing.T, store BlobStore, key string) string {
+	t.Helper()
+	rc, err := store.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", key, err)
+	}
+	defer rc.Close()
+	body, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+// leftovers returns the temporary upload files below dir.
+func leftovers(t *testing.T, dir string) []string {
+	t.Helper()
+	var found []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".upload-") {
+			found = append(found, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func TestLocalStoreInvalidKeys(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"", ".", "..", "../escape", "items/../../escape", "/etc/passwd", "items/./1", "items//1", "items/1/"} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Put(%q) succeeded, want an invalid key error", key)
+		}
+		if _, err := store.Get(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) = %v, want an invalid key error", key, err)
+		}
+		if err := store.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded, want an invalid key error", key)
+		}
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	store, dir := newTestLocalStore(t)
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "items/1/3f2a", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readBlob(t, store, "items/1/3f2a"); got != "hello" {
+		t.Errorf("Get = %q, want %q", got, "hello")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "items", "1", "3f2a")); err != nil {
+		t.Errorf("blob is not kept below the directory: %v", err)
+	}
+
+	if err := store.Delete(ctx, "items/1/3f2a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "items/1/3f2a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "items/1/3f2a"); err != nil {
+		t.Errorf("Delete of a missing blob = %v, want nil", err)
+	}
+}
+
+func TestLocalStoreSizeMismatch(t *testing.T) {
+	store, dir := newTestLocalStore(t)
+	ctx := context.Background()
+
+	for _, size := range []int64{4, 6} {
+		if err := store.Put(ctx, "items/1/short", strings.NewReader("hello"), size, "text/plain"); err == nil {
+			t.Errorf("Put with size %d succeeded, want a size mismatch", size)
+		}
+	}
+	if _, err := store.Get(ctx, "items/1/short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after failed Put = %v, want ErrNotFound", err)
+	}
+	if found := leftovers(t, dir); len(found) > 0 {
+		t.Errorf("temporary files left behind: %v", found)
+	}
+}
+
+func TestLocalStoreReplaceIsAtomic(t *testing.T) {
+	store, dir := newTestLocalStore(t)
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "items/1/3f2a", strings.NewReader("first"), 5, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a reader that opened the blob before it was replaced keeps the old
+	// contents, the new ones only show up under the key once complete
+	old, err := store.Get(ctx, "items/1/3f2a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+
+	if err := store.Put(ctx, "items/1/3f2a", strings.NewReader("second!"), 7, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "items/1/3f2a", strings.NewReader("broken"), 100, "text/plain"); err == nil {
+		t.Fatal("Put with a wrong size succeeded")
+	}
+
+	body, err := io.ReadAll(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "first" {
+		t.Errorf("reader of the old blob got %q, want %q", body, "first")
+	}
+	if got := readBlob(t, store, "items/1/3f2a"); got != "second!" {
+		t.Errorf("Get = %q, want %q", got, "second!")
+	}
+	if found := leftovers(t, dir); len(found) > 0 {
+		t.Errorf("temporary files left behind: %v", found)
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"errors"
+	"github.com/Olmosbek510/todo-app/pkg/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type emailInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type verifyEmailInput struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+type resetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// @Summary Verify Email
+// @Tags auth
+// @Description confirm an email address with the token from the verification email
+// @ID verify-email
+// @Accept json
+// @Produce json
+// @Param input body verifyEmailInput true "verification token"
+// @Success 200 {object} statusResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/verify-email [post]
+func (h *Handler) verifyEmail(c *gin.Context) {
+	var input verifyEmailInput
+	// the link in the email is opened with GET and carries the token in the query
+	if err := c.ShouldBind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.VerifyEmail(input.Token); err != nil {
+		if errors.Is(err, service.ErrInvalidUserToken) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Resend Verification Email
+// @Tags auth
+// @Description send a new verification link, succeeds for unknown addresses as well
+// @ID resend-verification-email
+// @Accept json
+// @Produce json
+// @Param input body emailInput true "email"
+// @Success 200 {object} statusResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/verify-email/resend [post]
+func (h *Handler) resendVerificationEmail(c *gin.Context) {
+	var input emailInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.SendVerificationEmail(input.Email); err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Request Password Reset
+// @Tags auth
+// @Description email a password reset token, succeeds for unknown addresses as well
+// @ID request-password-reset
+// @Accept json
+// @Produce json
+// @Param input body emailInput true "email"
+// @Success 200 {object} statusResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/reset-password/request [post]
+func (h *Handler) requestPasswordReset(c *gin.Context) {
+	var input emailInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.RequestPasswordReset(input.Email); err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Reset Password
+// @Tags auth
+// @Description set a new password with a reset token, all sessions are signed out
+// @ID reset-password
+// @Accept json
+// @Produce json
+// @Param input body resetPasswordInput true "reset token and new password"
+// @Success 200 {object} statusResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/reset-password [post]
+func (h *Handler) resetPassword(c *gin.Context) {
+	var input resetPasswordInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.ResetPassword(input.Token, input.Password); err != nil {
+		if errors.Is(err, service.ErrInvalidUserToken) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
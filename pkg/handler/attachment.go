@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"errors"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/service"
+	"github.com/gin-gonic/gin"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// multipartOverhead is the room left for the multipart framing around an
+// uploaded file.
+const multipartOverhead = 1 << 20
+
+type getAllAttachmentsResponse struct {
+	Data []todo.Attachment `json:"data"`
+}
+
+// @Summary Upload Attachment
+// @Security ApiKeyAuth
+// @Tags attachments
+// @Description upload a file to an item as the multipart field file, editors and owners of the list can. The content type is sniffed from the content and the file counts against the storage quota of the uploader.
+// @ID upload-attachment
+// @Accept mpfd
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param file formData file true "the file"
+// @Success 200 {object} todo.Attachment
+// @Failure 400,403,404,413,507 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/attachments [post]
+func (h *Handler) uploadAttachment(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.services.Attachment.MaxSize()+multipartOverhead)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			newErrorResponse(c, http.StatusRequestEntityTooLarge, service.ErrAttachmentTooLarge.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.services.Attachment.Create(c.Request.Context(), userId, itemId, header.Filename, header.Size, file)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, attachment)
+}
+
+// @Summary Get Item Attachments
+// @Security ApiKeyAuth
+// @Tags attachments
+// @Description get the files of an item, each with a download URL that works for a limited time
+// @ID get-item-attachments
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} getAllAttachmentsResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/attachments [get]
+func (h *Handler) getItemAttachments(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	attachments, err := h.services.Attachment.GetAll(userId, itemId)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, getAllAttachmentsResponse{Data: attachments})
+}
+
+// @Summary Get Attachment
+// @Security ApiKeyAuth
+// @Tags attachments
+// @Description get a file with a fresh download URL
+// @ID get-attachment
+// @Produce json
+// @Param id path int true "Attachment ID"
+// @Success 200 {object} todo.Attachment
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/attachments/{id} [get]
+func (h *Handler) getAttachmentById(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	attachment, err := h.services.Attachment.GetById(userId, id)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, attachment)
+}
+
+// @Summary Delete Attachment
+// @Security ApiKeyAuth
+// @Tags attachments
+// @Description delete a file, uploaders can delete their own files and owners of the list any file
+// @ID delete-attachment
+// @Produce json
+// @Param id path int true "Attachment ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/attachments/{id} [delete]
+func (h *Handler) deleteAttachment(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Attachment.Delete(userId, id); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Get Storage Usage
+// @Security ApiKeyAuth
+// @Tags profile
+// @Description get how many bytes the files uploaded by the user take up and their quota, 0 for none
+// @ID get-storage-usage
+// @Produce json
+// @Success 200 {object} todo.StorageUsage
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/me/storage [get]
+func (h *Handler) getStorageUsage(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	usage, err := h.services.Attachment.GetUsage(userId)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// @Summary Download Attachment
+// @Tags attachments
+// @Description download a file through the download_url of an attachment, no authentication needed until the URL expires
+// @ID download-attachment
+// @Produce octet-stream
+// @Param token path string true "download token"
+// @Success 200 {file} file
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /files/{token} [get]
+func (h *Handler) downloadAttachment(c *gin.Context) {
+	// the token is part of the URL, keep it out of shared caches and the
+	// referrer of outgoing links
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+
+	attachment, content, err := h.services.Attachment.Open(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		status := listErrorStatus(err)
+		if errors.Is(err, service.ErrInvalidUserToken) {
+			status = http.StatusNotFound
+		}
+		newErrorResponse(c, status, err.Error())
+		return
+	}
+	defer content.Close()
+
+	// uploads are never rendered by the browser, an HTML file could
+	// otherwise run scripts on this origin
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+		"Content-Disposition":     mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}),
+		"X-Content-Type-Options":  "nosniff",
+		"Content-Security-Policy": "default-src 'none'; sandbox",
+	})
+}
@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/service"
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
+	"math"
 	"net/http"
+	"strconv"
 )
 
 // @Summary SignUp
@@ -28,6 +31,10 @@ func (h *Handler) signUp(c *gin.Context) {
 	}
 	id, err := h.services.Authorization.CreateUser(input)
 	if err != nil {
+		if errors.Is(err, service.ErrEmailRequired) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
 		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -37,19 +44,41 @@ func (h *Handler) signUp(c *gin.Context) {
 }
 
 type signInInput struct {
-	Username string `json:"username" binding:"required"`
+	// Username is limited like the column, longer ones cannot exist and
+	// would not fit the lockout key either.
+	Username string `json:"username" binding:"required,max=255"`
 	Password string `json:"password" binding:"required"`
 }
 
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func newTokenResponse(tokens todo.Tokens) tokenResponse {
+	return tokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int(tokens.ExpiresIn.Seconds()),
+	}
+}
+
+type twoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
 // @Summary SignIn
 // @Tags auth
-// @Description login
+// @Description login, accounts with two-factor authentication receive a twoFactorChallengeResponse instead of tokens
 // @ID login
 // @Accept json
 // @Produce json
 // @Param input body signInInput true "credentials"
-// @Success 200 {string} string "token"
-// @Failure 400,404 {object} errorResponse
+// @Success 200 {object} tokenResponse
+// @Failure 400,401,403 {object} errorResponse
+// @Failure 429 {object} errorResponse "locked out, see the Retry-After header"
 // @Failure 500 {object} errorResponse
 // @Failure default {object} errorResponse
 // @Router /auth/sign-in [post]
@@ -59,13 +88,172 @@ func (h *Handler) signIn(c *gin.Context) {
 		newErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	logrus.Info("Request body:", input)
-	token, err := h.services.Authorization.GenerateToken(input.Username, input.Password)
+	result, err := h.services.Authorization.SignIn(input.Username, input.Password, c.ClientIP())
 	if err != nil {
+		if tooManyAttempts(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			newErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrEmailNotVerified) {
+			newErrorResponse(c, http.StatusForbidden, err.Error())
+			return
+		}
 		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
-	c.JSONP(http.StatusOK, map[string]interface{}{
-		"token": token,
-	})
+	if result.Tokens == nil {
+		c.JSON(http.StatusOK, twoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    result.ChallengeToken,
+		})
+		return
+	}
+	c.JSONP(http.StatusOK, newTokenResponse(*result.Tokens))
+}
+
+// tooManyAttempts answers a TooManyAttemptsError with 429 and a Retry-After
+// header, it reports whether err was one.
+func tooManyAttempts(c *gin.Context, err error) bool {
+	var tooMany *service.TooManyAttemptsError
+	if !errors.As(err, &tooMany) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(tooMany.RetryAfter.Seconds()))))
+	newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+	return true
+}
+
+type signInTwoFactorInput struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	// Code is a current authenticator code or one of the recovery codes.
+	Code string `json:"code" binding:"required"`
+}
+
+// @Summary SignIn Two-Factor
+// @Tags auth
+// @Description exchange the challenge token from sign-in and a second factor code for tokens
+// @ID login-2fa
+// @Accept json
+// @Produce json
+// @Param input body signInTwoFactorInput true "challenge and code"
+// @Success 200 {object} tokenResponse
+// @Failure 400,401 {object} errorResponse
+// @Failure 429 {object} errorResponse "locked out, see the Retry-After header"
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/sign-in/2fa [post]
+func (h *Handler) signInTwoFactor(c *gin.Context) {
+	var input signInTwoFactorInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	tokens, err := h.services.Authorization.VerifyTwoFactor(input.ChallengeToken, input.Code, c.ClientIP())
+	if err != nil {
+		if tooManyAttempts(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidUserToken) || errors.Is(err, service.ErrInvalidTwoFactorCode) {
+			newErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, newTokenResponse(tokens))
+}
+
+type refreshInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// @Summary Refresh
+// @Tags auth
+// @Description exchange a refresh token for a new token pair, the old refresh token becomes invalid
+// @ID refresh
+// @Accept json
+// @Produce json
+// @Param input body refreshInput true "refresh token"
+// @Success 200 {object} tokenResponse
+// @Failure 400,401 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/refresh [post]
+func (h *Handler) refresh(c *gin.Context) {
+	var input refreshInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	tokens, err := h.services.Authorization.RefreshTokens(input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			newErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, newTokenResponse(tokens))
+}
+
+// @Summary SignOut
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description revoke the current access token and the refresh tokens of the same sign-in
+// @ID sign-out
+// @Produce json
+// @Success 200 {object} statusResponse
+// @Failure 401 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/sign-out [post]
+func (h *Handler) signOut(c *gin.Context) {
+	identity, err := h.getIdentity(c)
+	if err != nil {
+		return
+	}
+	if err := h.services.Authorization.SignOut(identity); err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary SignOutAll
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description revoke every access and refresh token of the current user
+// @ID sign-out-all
+// @Produce json
+// @Success 200 {object} statusResponse
+// @Failure 401 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/sign-out-all [post]
+func (h *Handler) signOutAll(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+	if err := h.services.Authorization.SignOutAll(userId); err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary JWKS
+// @Tags auth
+// @Description public keys for verifying access tokens issued by this app
+// @ID jwks
+// @Produce json
+// @Success 200 {object} service.JSONWebKeySet
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) jwks(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.services.Authorization.JWKS())
 }
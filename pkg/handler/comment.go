@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// @Summary Create Comment
+// @Security ApiKeyAuth
+// @Tags comments
+// @Description comment on an item, editors and owners of the list can. With parent_id the comment replies to another one on the item, replies to a reply join its thread. Members of the list mentioned as @username are notified.
+// @ID create-comment
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param input body todo.CreateCommentInput true "comment"
+// @Success 200 {integer} integer 1
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/comments [post]
+func (h *Handler) createComment(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.CreateCommentInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := h.services.Comment.Create(userId, itemId, input)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
+// @Summary Get Item Comments
+// @Security ApiKeyAuth
+// @Tags comments
+// @Description get the threads on an item, the oldest first, each with all of its replies. Pass next_after of a page as after to get the next one.
+// @ID get-item-comments
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param after query int false "only threads started after the comment with this id"
+// @Param limit query int false "threads per page, 20 by default and at most 100"
+// @Success 200 {object} todo.CommentPage
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/comments [get]
+func (h *Handler) getItemComments(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+	after, err := strconv.Atoi(c.DefaultQuery("after", "0"))
+	if err != nil || after < 0 {
+		newErrorResponse(c, http.StatusBadRequest, "invalid after param")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(todo.DefaultCommentPageSize)))
+	if err != nil || limit < 1 || limit > todo.MaxCommentPageSize {
+		newErrorResponse(c, http.StatusBadRequest,
+			fmt.Sprintf("limit must be between 1 and %d", todo.MaxCommentPageSize))
+		return
+	}
+
+	page, err := h.services.Comment.GetAll(userId, itemId, after, limit)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// @Summary Update Comment
+// @Security ApiKeyAuth
+// @Tags comments
+// @Description change the body of a comment, only its author can and only for a while after posting it. Members mentioned for the first time are notified.
+// @ID update-comment
+// @Accept json
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Param input body todo.UpdateCommentInput true "comment"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/comments/{id} [put]
+func (h *Handler) updateComment(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.UpdateCommentInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Comment.Update(userId, id, input); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Delete Comment
+// @Security ApiKeyAuth
+// @Tags comments
+// @Description delete a comment together with its replies, authors can delete their own comments and owners of the list any comment
+// @ID delete-comment
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/comments/{id} [delete]
+func (h *Handler) deleteComment(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Comment.Delete(userId, id); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"github.com/Olmosbek510/todo-app"
 	_ "github.com/Olmosbek510/todo-app/docs"
 	"github.com/Olmosbek510/todo-app/pkg/service"
 	"github.com/gin-gonic/gin"
@@ -16,41 +17,196 @@ func NewHandler(services *service.Service) *Handler {
 	return &Handler{services: services}
 }
 
-func (h *Handler) InitRoutes() *gin.Engine {
+// InitRoutes builds the router. Client addresses, which the sign-in lockout
+// is keyed on, are only taken from X-Forwarded-For and X-Real-IP when the
+// request comes from one of trustedProxies; without any the peer address is
+// used.
+func (h *Handler) InitRoutes(trustedProxies []string) (*gin.Engine, error) {
 	router := gin.New()
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, err
+	}
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router.GET("/.well-known/jwks.json", h.jwks)
+
+	// public share links, they work without an account
+	router.GET("/share/:token", h.openShareLink)
+	router.POST("/share/:token", h.openShareLink)
+	// signed download URLs of attachments
+	router.GET("/files/:token", h.downloadAttachment)
 
 	auth := router.Group("/auth")
 	{
 		auth.POST("/sign-up", h.signUp)
 		auth.POST("/sign-in", h.signIn)
+		auth.POST("/sign-in/2fa", h.signInTwoFactor)
+		auth.POST("/refresh", h.refresh)
+		auth.POST("/sign-out", h.userIdentity, h.requireSession, h.signOut)
+		auth.POST("/sign-out-all", h.userIdentity, h.requireSession, h.signOutAll)
+		auth.GET("/verify-email", h.verifyEmail)
+		auth.POST("/verify-email", h.verifyEmail)
+		auth.POST("/verify-email/resend", h.resendVerificationEmail)
+		auth.POST("/reset-password/request", h.requestPasswordReset)
+		auth.POST("/reset-password", h.resetPassword)
+		auth.GET("/oidc/:provider/login", h.oidcLogin)
+		auth.GET("/oidc/:provider/callback", h.oidcCallback)
 	}
 
 	api := router.Group("/api", h.userIdentity)
 	{
+		workspaces := api.Group("/workspaces")
+		{
+			workspaces.POST("/", h.requireScope(todo.ScopeListsWrite), h.createWorkspace)
+			workspaces.GET("/", h.requireScope(todo.ScopeListsRead), h.getAllWorkspaces)
+			workspaces.GET("/:id", h.requireScope(todo.ScopeListsRead), h.getWorkspaceById)
+			workspaces.PUT("/:id", h.requireScope(todo.ScopeListsWrite), h.updateWorkspace)
+			workspaces.DELETE("/:id", h.requireScope(todo.ScopeListsWrite), h.deleteWorkspace)
+			workspaces.GET("/:id/lists", h.requireScope(todo.ScopeListsRead), h.getWorkspaceLists)
+
+			members := workspaces.Group(":id/members")
+			{
+				members.GET("/", h.requireScope(todo.ScopeListsRead), h.getWorkspaceMembers)
+				members.POST("/", h.requireScope(todo.ScopeListsWrite), h.addWorkspaceMember)
+				members.PUT("/:user_id", h.requireScope(todo.ScopeListsWrite), h.updateWorkspaceMember)
+				members.DELETE("/:user_id", h.requireScope(todo.ScopeListsWrite), h.removeWorkspaceMember)
+			}
+		}
+
 		lists := api.Group("/lists")
 		{
-			lists.POST("/", h.createList)
-			lists.GET("/", h.getAllLists)
-			lists.GET("/:id", h.getListById)
-			lists.PUT("/:id", h.updateList)
-			lists.DELETE("/:id", h.deleteList)
+			lists.POST("/", h.requireScope(todo.ScopeListsWrite), h.createList)
+			lists.GET("/", h.requireScope(todo.ScopeListsRead), h.getAllLists)
+			lists.GET("/:id", h.requireScope(todo.ScopeListsRead), h.getListById)
+			lists.PUT("/:id", h.requireScope(todo.ScopeListsWrite), h.updateList)
+			lists.DELETE("/:id", h.requireScope(todo.ScopeListsWrite), h.deleteList)
+			lists.POST("/:id/move", h.requireScope(todo.ScopeListsWrite), h.moveList)
 
 			items := lists.Group(":id/items")
 			{
-				items.POST("/", h.createItem)
-				items.GET("/", h.getAllItems)
+				items.POST("/", h.requireScope(todo.ScopeItemsWrite), h.createItem)
+				items.GET("/", h.requireScope(todo.ScopeItemsRead), h.getAllItems)
+			}
+
+			members := lists.Group(":id/members")
+			{
+				members.GET("/", h.requireScope(todo.ScopeListsRead), h.getAllMembers)
+				members.POST("/", h.requireScope(todo.ScopeListsWrite), h.addMember)
+				members.PUT("/:user_id", h.requireScope(todo.ScopeListsWrite), h.updateMember)
+				members.DELETE("/:user_id", h.requireScope(todo.ScopeListsWrite), h.removeMember)
+			}
+
+			invitations := lists.Group(":id/invitations")
+			{
+				invitations.GET("/", h.requireScope(todo.ScopeListsRead), h.getListInvitations)
+				invitations.POST("/", h.requireScope(todo.ScopeListsWrite), h.createInvitation)
+				invitations.DELETE("/:invitation_id", h.requireScope(todo.ScopeListsWrite), h.revokeInvitation)
+			}
+
+			shareLinks := lists.Group(":id/share-links")
+			{
+				shareLinks.GET("/", h.requireScope(todo.ScopeListsRead), h.getAllShareLinks)
+				shareLinks.POST("/", h.requireScope(todo.ScopeListsWrite), h.createShareLink)
+				shareLinks.DELETE("/:link_id", h.requireScope(todo.ScopeListsWrite), h.revokeShareLink)
 			}
 		}
 
 		items := api.Group("items")
 		{
-			items.GET("/:id", h.getItemById)
-			items.PUT("/:id", h.updateItem)
-			items.DELETE("/:id", h.deleteItem)
+			items.GET("/overdue", h.requireScope(todo.ScopeItemsRead), h.getOverdueItems)
+			items.GET("/due-today", h.requireScope(todo.ScopeItemsRead), h.getItemsDueToday)
+			items.GET("/due-this-week", h.requireScope(todo.ScopeItemsRead), h.getItemsDueThisWeek)
+			items.POST("/bulk", h.requireScope(todo.ScopeItemsWrite), h.bulkItems)
+			items.GET("/:id", h.requireScope(todo.ScopeItemsRead), h.getItemById)
+			items.PUT("/:id", h.requireScope(todo.ScopeItemsWrite), h.updateItem)
+			items.DELETE("/:id", h.requireScope(todo.ScopeItemsWrite), h.deleteItem)
+			items.POST("/:id/move", h.requireScope(todo.ScopeItemsWrite), h.moveItem)
+			items.POST("/:id/copy", h.requireScope(todo.ScopeItemsWrite), h.copyItem)
+			items.GET("/:id/completions", h.requireScope(todo.ScopeItemsRead), h.getItemCompletions)
+			items.POST("/:id/labels/:label_id", h.requireScope(todo.ScopeItemsWrite), h.addItemLabel)
+			items.DELETE("/:id/labels/:label_id", h.requireScope(todo.ScopeItemsWrite), h.removeItemLabel)
+			items.POST("/:id/reminders", h.requireScope(todo.ScopeItemsWrite), h.createReminder)
+			items.GET("/:id/reminders", h.requireScope(todo.ScopeItemsRead), h.getItemReminders)
+			items.POST("/:id/comments", h.requireScope(todo.ScopeItemsWrite), h.createComment)
+			items.GET("/:id/comments", h.requireScope(todo.ScopeItemsRead), h.getItemComments)
+			items.POST("/:id/attachments", h.requireScope(todo.ScopeItemsWrite), h.uploadAttachment)
+			items.GET("/:id/attachments", h.requireScope(todo.ScopeItemsRead), h.getItemAttachments)
+		}
+
+		reminders := api.Group("/reminders")
+		{
+			reminders.DELETE("/:id", h.requireScope(todo.ScopeItemsWrite), h.deleteReminder)
+		}
+
+		comments := api.Group("/comments")
+		{
+			comments.PUT("/:id", h.requireScope(todo.ScopeItemsWrite), h.updateComment)
+			comments.DELETE("/:id", h.requireScope(todo.ScopeItemsWrite), h.deleteComment)
+		}
+
+		attachments := api.Group("/attachments")
+		{
+			attachments.GET("/:id", h.requireScope(todo.ScopeItemsRead), h.getAttachmentById)
+			attachments.DELETE("/:id", h.requireScope(todo.ScopeItemsWrite), h.deleteAttachment)
+		}
+
+		trash := api.Group("/trash")
+		{
+			trash.GET("/", h.requireScope(todo.ScopeListsRead), h.getTrash)
+			trash.POST("/lists/:id/restore", h.requireScope(todo.ScopeListsWrite), h.restoreList)
+			trash.DELETE("/lists/:id", h.requireScope(todo.ScopeListsWrite), h.purgeList)
+			trash.POST("/items/:id/restore", h.requireScope(todo.ScopeItemsWrite), h.restoreItem)
+			trash.DELETE("/items/:id", h.requireScope(todo.ScopeItemsWrite), h.purgeItem)
+		}
+
+		labels := api.Group("/labels")
+		{
+			labels.POST("/", h.requireScope(todo.ScopeItemsWrite), h.createLabel)
+			labels.GET("/", h.requireScope(todo.ScopeItemsRead), h.getAllLabels)
+			labels.GET("/:id", h.requireScope(todo.ScopeItemsRead), h.getLabelById)
+			labels.PUT("/:id", h.requireScope(todo.ScopeItemsWrite), h.updateLabel)
+			labels.DELETE("/:id", h.requireScope(todo.ScopeItemsWrite), h.deleteLabel)
+		}
+
+		me := api.Group("/me", h.requireSession)
+		{
+			me.GET("", h.getProfile)
+			me.PUT("", h.updateProfile)
+			me.DELETE("", h.deleteAccount)
+			me.PUT("/password", h.changePassword)
+			me.GET("/storage", h.getStorageUsage)
+		}
+
+		invitations := api.Group("/invitations", h.requireSession)
+		{
+			invitations.GET("/", h.getMyInvitations)
+			invitations.POST("/accept", h.acceptInvitationToken)
+			invitations.POST("/:id/accept", h.acceptInvitation)
+			invitations.POST("/:id/decline", h.declineInvitation)
+		}
+
+		twoFactor := api.Group("/2fa", h.requireSession)
+		{
+			twoFactor.POST("/enroll", h.enrollTwoFactor)
+			twoFactor.POST("/confirm", h.confirmTwoFactor)
+			twoFactor.POST("/disable", h.disableTwoFactor)
+		}
+
+		identities := api.Group("/identities", h.requireSession)
+		{
+			identities.GET("/", h.getAllIdentities)
+			identities.POST("/:provider/link", h.linkIdentity)
+			identities.POST("/:provider/reauth", h.reauthIdentity)
+			identities.DELETE("/:id", h.unlinkIdentity)
+		}
+
+		tokens := api.Group("/tokens", h.requireSession)
+		{
+			tokens.POST("/", h.createToken)
+			tokens.GET("/", h.getAllTokens)
+			tokens.DELETE("/:id", h.deleteToken)
 		}
 	}
 
-	return router
+	return router, nil
 }
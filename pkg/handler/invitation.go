@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"github.com/Olmosbek510/todo-app"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type createInvitationResponse struct {
+	Id    int    `json:"id"`
+	Token string `json:"token"`
+}
+
+type getAllInvitationsResponse struct {
+	Data []todo.Invitation `json:"data"`
+}
+
+type acceptInvitationResponse struct {
+	ListId int `json:"list_id"`
+}
+
+// @Summary Invite To List
+// @Security ApiKeyAuth
+// @Tags invitations
+// @Description invite a user by username or an email address, only owners can invite. The returned token accepts the invitation and is also mailed to the invitee.
+// @ID create-invitation
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.CreateInvitationInput true "invitee and role"
+// @Success 200 {object} createInvitationResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/invitations [post]
+func (h *Handler) createInvitation(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	var input todo.CreateInvitationInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, token, err := h.services.Invitation.Create(userId, listId, input)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, createInvitationResponse{Id: id, Token: token})
+}
+
+// @Summary Get List Invitations
+// @Security ApiKeyAuth
+// @Tags invitations
+// @Description get all invitations of a list with their status, only owners can see them
+// @ID get-list-invitations
+// @Produce json
+// @Param id path int true "List ID"
+// @Success 200 {object} getAllInvitationsResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/invitations [get]
+func (h *Handler) getListInvitations(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	invitations, err := h.services.Invitation.GetAllByList(userId, listId)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, getAllInvitationsResponse{Data: invitations})
+}
+
+// @Summary Revoke Invitation
+// @Security ApiKeyAuth
+// @Tags invitations
+// @Description delete an invitation of a list, its token stops working
+// @ID revoke-invitation
+// @Produce json
+// @Param id path int true "List ID"
+// @Param invitation_id path int true "Invitation ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/invitations/{invitation_id} [delete]
+func (h *Handler) revokeInvitation(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+	id, err := strconv.Atoi(c.Param("invitation_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid invitation id param")
+		return
+	}
+
+	if err := h.services.Invitation.Revoke(userId, listId, id); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Get My Invitations
+// @Security ApiKeyAuth
+// @Tags invitations
+// @Description get the pending invitations addressed to the user or their verified email
+// @ID get-my-invitations
+// @Produce json
+// @Success 200 {object} getAllInvitationsResponse
+// @Failure 403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/invitations [get]
+func (h *Handler) getMyInvitations(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	invitations, err := h.services.Invitation.GetPending(userId)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, getAllInvitationsResponse{Data: invitations})
+}
+
+// @Summary Accept Invitation
+// @Security ApiKeyAuth
+// @Tags invitations
+// @Description join the list of a pending invitation
+// @ID accept-invitation
+// @Produce json
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/invitations/{id}/accept [post]
+func (h *Handler) acceptInvitation(c *gin.Context) {
+	h.answerInvitation(c, h.services.Invitation.Accept)
+}
+
+// @Summary Decline Invitation
+// @Security ApiKeyAuth
+// @Tags invitations
+// @Description decline a pending invitation
+// @ID decline-invitation
+// @Produce json
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/invitations/{id}/decline [post]
+func (h *Handler) declineInvitation(c *gin.Context) {
+	h.answerInvitation(c, h.services.Invitation.Decline)
+}
+
+func (h *Handler) answerInvitation(c *gin.Context, answer func(userId, id int) error) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := answer(userId, id); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Accept Invitation Token
+// @Security ApiKeyAuth
+// @Tags invitations
+// @Description accept an invitation with the token from the invitation email, also works for accounts created after the invitation was sent
+// @ID accept-invitation-token
+// @Accept json
+// @Produce json
+// @Param input body todo.AcceptInvitationInput true "invitation token"
+// @Success 200 {object} acceptInvitationResponse
+// @Failure 400,403,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/invitations/accept [post]
+func (h *Handler) acceptInvitationToken(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.AcceptInvitationInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	listId, err := h.services.Invitation.AcceptToken(userId, input.Token)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, acceptInvitationResponse{ListId: listId})
+}
@@ -10,7 +10,7 @@ import (
 // @Summary Create Item
 // @Security ApiKeyAuth
 // @Tags items
-// @Description Create a todo item in a specific list
+// @Description Create a todo item in a specific list, optionally with a start and due date. For all-day items only the date is kept. With parent_id the item becomes a subtask of another item in the list. recurrence takes an RRULE with FREQ (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL, BYDAY, UNTIL and COUNT and needs a due date.
 // @ID create-item
 // @Accept json
 // @Produce json
@@ -18,6 +18,8 @@ import (
 // @Param input body todo.TodoItem true "Item Input"
 // @Success 200 {object} map[string]interface{} "ID of the created item"
 // @Failure 400 {object} errorResponse "Invalid request"
+// @Failure 403 {object} errorResponse "Viewers cannot add items"
+// @Failure 404 {object} errorResponse "List not found"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Router /api/lists/{id}/items [post]
 func (h *Handler) createItem(c *gin.Context) {
@@ -29,15 +31,17 @@ func (h *Handler) createItem(c *gin.Context) {
 	listId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
 	}
 	var input todo.TodoItem
 	if err := c.BindJSON(&input); err != nil {
 		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	id, err := h.services.TodoItem.Create(userId, listId, input)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, listErrorStatus(err), err.Error())
 		return
 	}
 
@@ -49,13 +53,16 @@ func (h *Handler) createItem(c *gin.Context) {
 // @Summary Get All Items
 // @Security ApiKeyAuth
 // @Tags items
-// @Description Get all todo items from a specific list
+// @Description Get all todo items from a specific list, optionally only those with a label or a priority
 // @ID get-all-items
 // @Accept json
 // @Produce json
 // @Param id path int true "List ID"
+// @Param label_id query int false "only items with this label"
+// @Param priority query int false "only items with this priority, 0 (none) to 3 (high)"
+// @Param view query string false "flat (default) or tree to nest subtasks under their parents in children" Enums(flat, tree)
 // @Success 200 {array} todo.TodoItem
-// @Failure 400 {object} errorResponse "Invalid list ID parameter"
+// @Failure 400 {object} errorResponse "Invalid list ID or filter parameter"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Router /api/lists/{id}/items [get]
 func (h *Handler) getAllItems(c *gin.Context) {
@@ -67,13 +74,41 @@ func (h *Handler) getAllItems(c *gin.Context) {
 	listId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	var filter todo.ItemFilter
+	if value, ok := c.GetQuery("label_id"); ok {
+		labelId, err := strconv.Atoi(value)
+		if err != nil {
+			newErrorResponse(c, http.StatusBadRequest, "invalid label_id param")
+			return
+		}
+		filter.LabelId = &labelId
+	}
+	if value, ok := c.GetQuery("priority"); ok {
+		priority, err := strconv.Atoi(value)
+		if err != nil || !todo.ValidPriority(priority) {
+			newErrorResponse(c, http.StatusBadRequest, todo.ErrInvalidPriority.Error())
+			return
+		}
+		filter.Priority = &priority
+	}
+
+	view := c.DefaultQuery("view", "flat")
+	if view != "flat" && view != "tree" {
+		newErrorResponse(c, http.StatusBadRequest, "view must be flat or tree")
+		return
 	}
 
-	items, err := h.services.TodoItem.GetAll(userId, listId)
+	items, err := h.services.TodoItem.GetAll(userId, listId, filter)
 	if err != nil {
 		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if view == "tree" {
+		items = todo.ItemTree(items)
+	}
 	c.JSON(http.StatusOK, items)
 }
 
@@ -99,11 +134,12 @@ func (h *Handler) getItemById(c *gin.Context) {
 	itemId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
 	}
 
 	item, err := h.services.TodoItem.GetById(userId, itemId)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, listErrorStatus(err), err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, item)
@@ -112,7 +148,7 @@ func (h *Handler) getItemById(c *gin.Context) {
 // @Summary Update Item
 // @Security ApiKeyAuth
 // @Tags items
-// @Description Update a todo item by its ID
+// @Description Update a todo item by its ID. start_at and due_at can be cleared with null, for all-day items only their date is kept. parent_id moves the item under another item of the list, null makes it a top-level item. recurrence replaces the RRULE of the item, an empty string stops it from recurring. Marking a recurring item done records the completion and moves it to its next occurrence, it only stays done once the rule runs out.
 // @ID update-item
 // @Accept json
 // @Produce json
@@ -120,6 +156,7 @@ func (h *Handler) getItemById(c *gin.Context) {
 // @Param input body todo.UpdateItemInput true "Update Item Input"
 // @Success 200 {object} statusResponse
 // @Failure 400 {object} errorResponse "Invalid request"
+// @Failure 403 {object} errorResponse "Viewers cannot change items"
 // @Failure 404 {object} errorResponse "Item not found"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Router /api/items/{id} [put]
@@ -140,9 +177,13 @@ func (h *Handler) updateItem(c *gin.Context) {
 		newErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	if err := h.services.TodoItem.Update(userId, id, input); err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, listErrorStatus(err), err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
@@ -151,13 +192,14 @@ func (h *Handler) updateItem(c *gin.Context) {
 // @Summary Delete Item
 // @Security ApiKeyAuth
 // @Tags items
-// @Description Delete a todo item by its ID
+// @Description Move a todo item together with its subtasks to the trash, it can be restored until the retention runs out
 // @ID delete-item
 // @Accept json
 // @Produce json
 // @Param id path int true "Item ID"
 // @Success 200 {object} statusResponse
 // @Failure 400 {object} errorResponse "Invalid item ID parameter"
+// @Failure 403 {object} errorResponse "Viewers cannot delete items"
 // @Failure 404 {object} errorResponse "Item not found"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Router /api/items/{id} [delete]
@@ -170,12 +212,229 @@ func (h *Handler) deleteItem(c *gin.Context) {
 	itemId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
 	}
 
 	err = h.services.TodoItem.Delete(userId, itemId)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Move Item
+// @Security ApiKeyAuth
+// @Tags items
+// @Description Move an item right after after_id and ahead of before_id. One anchor is enough, without any the item goes to the end. With list_id the item is moved together with its subtasks to another list the user can edit, where it becomes a top-level item and loses the labels of other workspaces.
+// @ID move-item
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param input body todo.MoveItemInput true "list and items to place it between"
+// @Success 200 {object} statusResponse
+// @Failure 400 {object} errorResponse "Invalid request or anchor"
+// @Failure 403 {object} errorResponse "Viewers cannot move items"
+// @Failure 404 {object} errorResponse "Item or list not found"
+// @Failure 409 {object} errorResponse "No room left between the neighbours"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /api/items/{id}/move [post]
+func (h *Handler) moveItem(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.MoveItemInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.TodoItem.Move(userId, id, input); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
 }
+
+// @Summary Copy Item
+// @Security ApiKeyAuth
+// @Tags items
+// @Description Copy an item with its subtasks and labels into its own list or, with list_id, into another list the user can edit. The copy is placed like a moved item, anchors may refer to the original. Labels of other workspaces, reminders and the completion history are not copied.
+// @ID copy-item
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param input body todo.MoveItemInput true "list and items to place the copy between"
+// @Success 200 {object} map[string]interface{} "ID of the copy"
+// @Failure 400 {object} errorResponse "Invalid request or anchor"
+// @Failure 403 {object} errorResponse "Viewers cannot copy items"
+// @Failure 404 {object} errorResponse "Item or list not found"
+// @Failure 409 {object} errorResponse "No room left between the neighbours"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /api/items/{id}/copy [post]
+func (h *Handler) copyItem(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.MoveItemInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	copyId, err := h.services.TodoItem.Copy(userId, id, input)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": copyId,
+	})
+}
+
+// @Summary Get Overdue Items
+// @Security ApiKeyAuth
+// @Tags items
+// @Description Get the open items past their due date from all lists of the user. All-day items are overdue the day after their due date in the time zone of the user.
+// @ID get-overdue-items
+// @Produce json
+// @Success 200 {array} todo.TodoItem
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /api/items/overdue [get]
+func (h *Handler) getOverdueItems(c *gin.Context) {
+	h.getDueItems(c, todo.DueOverdue)
+}
+
+// @Summary Get Items Due Today
+// @Security ApiKeyAuth
+// @Tags items
+// @Description Get the open items due today in the time zone of the user from all of their lists
+// @ID get-items-due-today
+// @Produce json
+// @Success 200 {array} todo.TodoItem
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /api/items/due-today [get]
+func (h *Handler) getItemsDueToday(c *gin.Context) {
+	h.getDueItems(c, todo.DueToday)
+}
+
+// @Summary Get Items Due This Week
+// @Security ApiKeyAuth
+// @Tags items
+// @Description Get the open items due this week, Monday to Sunday in the time zone of the user, from all of their lists
+// @ID get-items-due-this-week
+// @Produce json
+// @Success 200 {array} todo.TodoItem
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /api/items/due-this-week [get]
+func (h *Handler) getItemsDueThisWeek(c *gin.Context) {
+	h.getDueItems(c, todo.DueThisWeek)
+}
+
+func (h *Handler) getDueItems(c *gin.Context, due string) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	items, err := h.services.TodoItem.GetDue(userId, due)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// @Summary Get Item Completions
+// @Security ApiKeyAuth
+// @Tags items
+// @Description Get the completed occurrences of a recurring item, the latest first
+// @ID get-item-completions
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {array} todo.ItemCompletion
+// @Failure 400 {object} errorResponse "Invalid item ID parameter"
+// @Failure 404 {object} errorResponse "Item not found"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /api/items/{id}/completions [get]
+func (h *Handler) getItemCompletions(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	completions, err := h.services.TodoItem.GetCompletions(userId, itemId)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, completions)
+}
+
+// @Summary Bulk Change Items
+// @Security ApiKeyAuth
+// @Tags items
+// @Description Apply one action to up to 500 items in a single transaction: complete, reopen, delete, move (with move, the items are placed one after another in the order of ids) or update (with update, like PUT /api/items/{id}). In atomic mode, the default, nothing is changed once an item fails and the other items are reported as skipped; in partial mode every item that can be changed is. The report holds the status of every item, the answer is 207 when any of them failed.
+// @ID bulk-items
+// @Accept json
+// @Produce json
+// @Param input body todo.BulkItemInput true "items and action"
+// @Success 200 {object} todo.BulkItemReport
+// @Success 207 {object} todo.BulkItemReport
+// @Failure 400 {object} errorResponse "Invalid request"
+// @Failure 500 {object} errorResponse "Internal server error"
+// @Router /api/items/bulk [post]
+func (h *Handler) bulkItems(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.BulkItemInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := h.services.TodoItem.Bulk(userId, input)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	status := http.StatusOK
+	for i, result := range report.Results {
+		if result.Err != nil {
+			report.Results[i].Code = listErrorStatus(result.Err)
+			report.Results[i].Error = result.Err.Error()
+			status = http.StatusMultiStatus
+		}
+	}
+	c.JSON(status, report)
+}
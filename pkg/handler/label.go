@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"github.com/Olmosbek510/todo-app"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type getAllLabelsResponse struct {
+	Data []todo.Label `json:"data"`
+}
+
+// @Summary Create Label
+// @Security ApiKeyAuth
+// @Tags labels
+// @Description create a personal label, or a label shared with a workspace when workspace_id is given
+// @ID create-label
+// @Accept json
+// @Produce json
+// @Param input body todo.CreateLabelInput true "label info"
+// @Success 200 {integer} integer 1
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/labels [post]
+func (h *Handler) createLabel(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.CreateLabelInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := h.services.Label.Create(userId, input)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
+// @Summary Get All Labels
+// @Security ApiKeyAuth
+// @Tags labels
+// @Description get the personal labels of the user and the labels of their workspaces
+// @ID get-all-labels
+// @Produce json
+// @Success 200 {object} getAllLabelsResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/labels [get]
+func (h *Handler) getAllLabels(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	labels, err := h.services.Label.GetAll(userId)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, getAllLabelsResponse{Data: labels})
+}
+
+// @Summary Get Label By ID
+// @Security ApiKeyAuth
+// @Tags labels
+// @Description get a label
+// @ID get-label-by-id
+// @Produce json
+// @Param id path int true "Label ID"
+// @Success 200 {object} todo.Label
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/labels/{id} [get]
+func (h *Handler) getLabelById(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	label, err := h.services.Label.GetById(userId, id)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, label)
+}
+
+// @Summary Update Label
+// @Security ApiKeyAuth
+// @Tags labels
+// @Description rename or recolour a label, workspace labels need the editor role in the workspace
+// @ID update-label
+// @Accept json
+// @Produce json
+// @Param id path int true "Label ID"
+// @Param input body todo.UpdateLabelInput true "label fields"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/labels/{id} [put]
+func (h *Handler) updateLabel(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.UpdateLabelInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Label.Update(userId, id, input); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Delete Label
+// @Security ApiKeyAuth
+// @Tags labels
+// @Description delete a label and take it off all items
+// @ID delete-label
+// @Produce json
+// @Param id path int true "Label ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/labels/{id} [delete]
+func (h *Handler) deleteLabel(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Label.Delete(userId, id); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Add Label To Item
+// @Security ApiKeyAuth
+// @Tags items
+// @Description put a label on an item, workspace labels only fit items in lists of their workspace
+// @ID add-item-label
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param label_id path int true "Label ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/labels/{label_id} [post]
+func (h *Handler) addItemLabel(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	itemId, labelId, ok := itemLabelParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Label.AddToItem(userId, itemId, labelId); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Remove Label From Item
+// @Security ApiKeyAuth
+// @Tags items
+// @Description take a label off an item
+// @ID remove-item-label
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param label_id path int true "Label ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/labels/{label_id} [delete]
+func (h *Handler) removeItemLabel(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	itemId, labelId, ok := itemLabelParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Label.RemoveFromItem(userId, itemId, labelId); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+func itemLabelParams(c *gin.Context) (int, int, bool) {
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return 0, 0, false
+	}
+	labelId, err := strconv.Atoi(c.Param("label_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid label_id param")
+		return 0, 0, false
+	}
+	return itemId, labelId, true
+}
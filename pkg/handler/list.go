@@ -1,22 +1,57 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
 	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/service"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
 )
 
+// listErrorStatus maps the errors of list, item, member, invitation,
+// workspace, label, comment and attachment operations.
+func listErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidUserToken), errors.Is(err, todo.ErrStartAfterDue),
+		errors.Is(err, todo.ErrInvalidPriority), errors.Is(err, service.ErrInvalidParent),
+		errors.Is(err, service.ErrItemCycle), errors.Is(err, service.ErrMaxDepth),
+		errors.Is(err, service.ErrInvalidAnchor), errors.Is(err, service.ErrInvalidRecurrence),
+		errors.Is(err, todo.ErrRecurrenceWithoutDue), errors.Is(err, todo.ErrReminderWithoutDue),
+		errors.Is(err, service.ErrUnknownChannel), errors.Is(err, service.ErrInvalidCommentParent):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInsufficientRole):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrAttachmentTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrQuotaExceeded):
+		return http.StatusInsufficientStorage
+	// ErrLastOwner only comes from workspace members, the direct members of a
+	// list can all go since its workspace keeps owning it
+	case errors.Is(err, service.ErrLastOwner), errors.Is(err, service.ErrAlreadyMember),
+		errors.Is(err, service.ErrInvitationClosed), errors.Is(err, service.ErrPersonalWorkspace),
+		errors.Is(err, service.ErrLabelExists), errors.Is(err, service.ErrLabelWorkspace),
+		errors.Is(err, service.ErrCommentEditClosed), errors.Is(err, service.ErrPositionOrder),
+		errors.Is(err, service.ErrPositionExhausted):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // @Summary todo List
 // @Security ApiKeyAuth
 // @Tags lists
-// @Description create todo list
+// @Description create todo list in the given workspace, the personal one by default
 // @ID create-list
 // @Accept json
 // @Produce json
 // @Param input body todo.TodoList true "list info"
 // @Success 200 {integer} integer 1
-// @Failure 400,404 {object} errorResponse
+// @Failure 400,403,404 {object} errorResponse
 // @Failure 500 {object} errorResponse
 // @Failure default {object} errorResponse
 // @Router /api/lists [post]
@@ -34,7 +69,7 @@ func (h *Handler) createList(c *gin.Context) {
 	// call service method
 	id, err := h.services.TodoList.Create(userId, input)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, listErrorStatus(err), err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{
@@ -98,7 +133,7 @@ func (h *Handler) getListById(c *gin.Context) {
 
 	list, err := h.services.TodoList.GetById(userId, id)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, listErrorStatus(err), err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
@@ -117,6 +152,7 @@ func (h *Handler) getListById(c *gin.Context) {
 // @Param input body todo.UpdateListInput true "Update List Input"
 // @Success 200 {object} statusResponse
 // @Failure 400 {object} errorResponse "Invalid request"
+// @Failure 403 {object} errorResponse "Viewers cannot change the list"
 // @Failure 404 {object} errorResponse "List not found"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Router /api/lists/{id} [put]
@@ -139,7 +175,7 @@ func (h *Handler) updateList(c *gin.Context) {
 	}
 
 	if err := h.services.TodoList.Update(userId, id, input); err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, listErrorStatus(err), err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
@@ -148,13 +184,14 @@ func (h *Handler) updateList(c *gin.Context) {
 // @Summary Delete List
 // @Security ApiKeyAuth
 // @Tags lists
-// @Description Delete a todo list by its ID
+// @Description Move a todo list with its items to the trash, it can be restored until the retention runs out
 // @ID delete-list
 // @Accept json
 // @Produce json
 // @Param id path int true "List ID"
 // @Success 200 {object} statusResponse
 // @Failure 400 {object} errorResponse "Invalid ID parameter"
+// @Failure 403 {object} errorResponse "Only owners can delete the list"
 // @Failure 404 {object} errorResponse "List not found"
 // @Failure 500 {object} errorResponse "Internal server error"
 // @Router /api/lists/{id} [delete]
@@ -172,7 +209,46 @@ func (h *Handler) deleteList(c *gin.Context) {
 
 	err = h.services.TodoList.DeleteById(userId, id)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Move List
+// @Security ApiKeyAuth
+// @Tags lists
+// @Description change where the list is shown among the lists of the user, other members keep their own order
+// @ID move-list
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.MoveInput true "lists to place it between, none moves it to the end"
+// @Success 200 {object} statusResponse
+// @Failure 400,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/move [post]
+func (h *Handler) moveList(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.MoveInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.TodoList.Move(userId, id, input); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
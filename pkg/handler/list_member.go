@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"github.com/Olmosbek510/todo-app"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type getAllMembersResponse struct {
+	Data []todo.ListMember `json:"data"`
+}
+
+// @Summary Get List Members
+// @Security ApiKeyAuth
+// @Tags members
+// @Description get the users a list is shared with and their roles
+// @ID get-list-members
+// @Produce json
+// @Param id path int true "List ID"
+// @Success 200 {object} getAllMembersResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/members [get]
+func (h *Handler) getAllMembers(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	members, err := h.services.ListMember.GetAll(userId, listId)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, getAllMembersResponse{Data: members})
+}
+
+// @Summary Add List Member
+// @Security ApiKeyAuth
+// @Tags members
+// @Description share a list with another user, only owners can add members
+// @ID add-list-member
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.AddMemberInput true "username and role"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/members [post]
+func (h *Handler) addMember(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	var input todo.AddMemberInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.ListMember.Add(userId, listId, input); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Change Member Role
+// @Security ApiKeyAuth
+// @Tags members
+// @Description change the role of a member, only owners can change roles
+// @ID update-list-member
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param user_id path int true "User ID of the member"
+// @Param input body todo.UpdateMemberInput true "new role"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/members/{user_id} [put]
+func (h *Handler) updateMember(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, memberId, ok := memberParams(c)
+	if !ok {
+		return
+	}
+
+	var input todo.UpdateMemberInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.ListMember.UpdateRole(userId, listId, memberId, input); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Remove List Member
+// @Security ApiKeyAuth
+// @Tags members
+// @Description stop sharing a list with a user, members can also remove themselves to leave the list
+// @ID remove-list-member
+// @Produce json
+// @Param id path int true "List ID"
+// @Param user_id path int true "User ID of the member"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/members/{user_id} [delete]
+func (h *Handler) removeMember(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, memberId, ok := memberParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.ListMember.Remove(userId, listId, memberId); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// memberParams reads the id of the list or workspace and the user id of the
+// member from the path.
+func memberParams(c *gin.Context) (int, int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return 0, 0, false
+	}
+	memberId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user id param")
+		return 0, 0, false
+	}
+	return id, memberId, true
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"errors"
+	"github.com/Olmosbek510/todo-app/pkg/service"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strings"
@@ -10,6 +11,7 @@ import (
 const (
 	authorizationHeader = "Authorization"
 	userCtx             = "userId"
+	identityCtx         = "identity"
 )
 
 func (h *Handler) userIdentity(c *gin.Context) {
@@ -27,13 +29,14 @@ func (h *Handler) userIdentity(c *gin.Context) {
 
 	// parse token
 
-	userId, err := h.services.Authorization.ParseToken(headerParts[1])
+	identity, err := h.services.Authorization.ParseToken(headerParts[1])
 	if err != nil {
 		newErrorResponse(c, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	c.Set(userCtx, userId)
+	c.Set(userCtx, identity.UserId)
+	c.Set(identityCtx, identity)
 }
 
 func (h *Handler) getUserId(c *gin.Context) (int, error) {
@@ -49,3 +52,45 @@ func (h *Handler) getUserId(c *gin.Context) (int, error) {
 	}
 	return idInt, nil
 }
+
+func (h *Handler) getIdentity(c *gin.Context) (service.Identity, error) {
+	identity, ok := c.Get(identityCtx)
+	if !ok {
+		newErrorResponse(c, http.StatusInternalServerError, "identity not found")
+		return service.Identity{}, errors.New("identity not found")
+	}
+	identityValue, ok := identity.(service.Identity)
+	if !ok {
+		newErrorResponse(c, http.StatusInternalServerError, "identity is of invalid type")
+		return service.Identity{}, errors.New("identity is of invalid type")
+	}
+	return identityValue, nil
+}
+
+// requireScope rejects personal access tokens that were not granted scope.
+// It has to run after userIdentity.
+func (h *Handler) requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, err := h.getIdentity(c)
+		if err != nil {
+			return
+		}
+		if !identity.Allows(scope) {
+			newErrorResponse(c, http.StatusForbidden, "token is missing scope "+scope)
+			return
+		}
+	}
+}
+
+// requireSession rejects personal access tokens, for routes that must only
+// be reachable with a signed-in session. It has to run after userIdentity.
+func (h *Handler) requireSession(c *gin.Context) {
+	identity, err := h.getIdentity(c)
+	if err != nil {
+		return
+	}
+	if !identity.IsSession() {
+		newErrorResponse(c, http.StatusForbidden, "not allowed with a personal access token")
+		return
+	}
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// oidcStateCookie keeps the state of a login in the browser that started
+// it, the callback is only accepted from there.
+const oidcStateCookie = "oidc_state"
+
+func oidcErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUnknownProvider):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidLoginState):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrReauthFailed), errors.Is(err, service.ErrEmailNotVerified):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrIdentityAlreadyLinked), errors.Is(err, service.ErrLastSignInMethod):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// @Summary OIDC Login
+// @Tags auth
+// @Description redirect to the identity provider to sign in
+// @ID oidc-login
+// @Param provider path string true "provider name"
+// @Success 302
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /auth/oidc/{provider}/login [get]
+func (h *Handler) oidcLogin(c *gin.Context) {
+	login, err := h.services.Authorization.OIDCLoginURL(c.Request.Context(), c.Param("provider"), 0)
+	if err != nil {
+		newErrorResponse(c, oidcErrorStatus(err), err.Error())
+		return
+	}
+	setOIDCStateCookie(c, login.State, login.ExpiresAt)
+	c.Redirect(http.StatusFound, login.URL)
+}
+
+// setOIDCStateCookie scopes the state to the callback of the provider. Lax
+// lets it through on the redirect back from the provider, which is a
+// cross-site navigation.
+func setOIDCStateCookie(c *gin.Context, state string, expiresAt time.Time) {
+	cookie := &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc/" + c.Param("provider") + "/callback",
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+	if state == "" {
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = expiresAt
+		cookie.MaxAge = int(time.Until(expiresAt).Seconds())
+	}
+	http.SetCookie(c.Writer, cookie)
+}
+
+// @Summary OIDC Callback
+// @Tags auth
+// @Description redirect target of the identity provider, signs the user in, links the identity or hands out a reauthResponse. Accounts with two-factor authentication receive a twoFactorChallengeResponse instead of tokens.
+// @ID oidc-callback
+// @Produce json
+// @Param provider path string true "provider name"
+// @Param code query string true "authorization code"
+// @Param state query string true "login state"
+// @Success 200 {object} tokenResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 429 {object} errorResponse "locked out, see the Retry-After header"
+// @Failure 500 {object} errorResponse
+// @Router /auth/oidc/{provider}/callback [get]
+func (h *Handler) oidcCallback(c *gin.Context) {
+	if providerError := c.Query("error"); providerError != "" {
+		newErrorResponse(c, http.StatusBadRequest, providerError+": "+c.Query("error_description"))
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		newErrorResponse(c, http.StatusBadRequest, "code and state are required")
+		return
+	}
+
+	boundState, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", time.Time{})
+	result, err := h.services.Authorization.OIDCCallback(c.Request.Context(), c.Param("provider"), state, boundState,
+		code, c.ClientIP())
+	if err != nil {
+		if tooManyAttempts(c, err) {
+			return
+		}
+		newErrorResponse(c, oidcErrorStatus(err), err.Error())
+		return
+	}
+	if result.Linked {
+		c.JSON(http.StatusOK, statusResponse{Status: "linked"})
+		return
+	}
+	if result.ReauthToken != "" {
+		c.JSON(http.StatusOK, reauthResponse{ReauthToken: result.ReauthToken})
+		return
+	}
+	if result.Tokens == nil {
+		c.JSON(http.StatusOK, twoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    result.ChallengeToken,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, newTokenResponse(*result.Tokens))
+}
+
+type getAllIdentitiesResponse struct {
+	Data []todo.UserIdentity `json:"data"`
+}
+
+// @Summary Get Linked Identities
+// @Security ApiKeyAuth
+// @Tags identities
+// @Description list the external accounts linked to the current user
+// @ID get-all-identities
+// @Produce json
+// @Success 200 {object} getAllIdentitiesResponse
+// @Failure 403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /api/identities [get]
+func (h *Handler) getAllIdentities(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	identities, err := h.services.Authorization.GetIdentities(userId)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, getAllIdentitiesResponse{Data: identities})
+}
+
+type linkIdentityResponse struct {
+	URL string `json:"url"`
+}
+
+// @Summary Link Identity
+// @Security ApiKeyAuth
+// @Tags identities
+// @Description start linking an external account, open the returned url in the same browser, the response sets a cookie the callback is checked against
+// @ID link-identity
+// @Produce json
+// @Param provider path string true "provider name"
+// @Success 200 {object} linkIdentityResponse
+// @Failure 403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /api/identities/{provider}/link [post]
+func (h *Handler) linkIdentity(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	login, err := h.services.Authorization.OIDCLoginURL(c.Request.Context(), c.Param("provider"), userId)
+	if err != nil {
+		newErrorResponse(c, oidcErrorStatus(err), err.Error())
+		return
+	}
+	setOIDCStateCookie(c, login.State, login.ExpiresAt)
+	c.JSON(http.StatusOK, linkIdentityResponse{URL: login.URL})
+}
+
+type reauthResponse struct {
+	// ReauthToken confirms it is the user for a few minutes, see
+	// todo.Reauthentication.
+	ReauthToken string `json:"reauth_token"`
+}
+
+// @Summary Reauthenticate
+// @Security ApiKeyAuth
+// @Tags identities
+// @Description start signing in again at the identity provider, for users without a password to confirm it is them. Open the returned url in the same browser, the callback answers with a reauthResponse.
+// @ID reauth-identity
+// @Produce json
+// @Param provider path string true "provider name"
+// @Success 200 {object} linkIdentityResponse
+// @Failure 403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /api/identities/{provider}/reauth [post]
+func (h *Handler) reauthIdentity(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	login, err := h.services.Authorization.OIDCReauthURL(c.Request.Context(), c.Param("provider"), userId)
+	if err != nil {
+		newErrorResponse(c, oidcErrorStatus(err), err.Error())
+		return
+	}
+	setOIDCStateCookie(c, login.State, login.ExpiresAt)
+	c.JSON(http.StatusOK, linkIdentityResponse{URL: login.URL})
+}
+
+// @Summary Unlink Identity
+// @Security ApiKeyAuth
+// @Tags identities
+// @Description remove a linked external account
+// @ID unlink-identity
+// @Produce json
+// @Param id path int true "Identity ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /api/identities/{id} [delete]
+func (h *Handler) unlinkIdentity(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Authorization.UnlinkIdentity(userId, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			newErrorResponse(c, http.StatusNotFound, "identity not found")
+			return
+		}
+		newErrorResponse(c, oidcErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
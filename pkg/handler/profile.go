@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// @Summary Get Profile
+// @Security ApiKeyAuth
+// @Tags me
+// @Description get the profile of the signed-in user
+// @ID get-profile
+// @Produce json
+// @Success 200 {object} todo.Profile
+// @Failure 401,403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/me [get]
+func (h *Handler) getProfile(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	profile, err := h.services.Authorization.GetProfile(userId)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Update Profile
+// @Security ApiKeyAuth
+// @Tags me
+// @Description change name, email or time zone, a new email has to be verified again
+// @ID update-profile
+// @Accept json
+// @Produce json
+// @Param input body todo.UpdateProfileInput true "profile fields"
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/me [put]
+func (h *Handler) updateProfile(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.UpdateProfileInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.UpdateProfile(userId, input); err != nil {
+		if errors.Is(err, service.ErrEmailTaken) {
+			newErrorResponse(c, http.StatusConflict, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Change Password
+// @Security ApiKeyAuth
+// @Tags me
+// @Description set a new password, signs out every other session and returns new tokens for this one. Users without a password confirm it is them with a reauth_token or a two-factor code.
+// @ID change-password
+// @Accept json
+// @Produce json
+// @Param input body todo.ChangePasswordInput true "current and new password"
+// @Success 200 {object} tokenResponse
+// @Failure 400,401,403,429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/me/password [put]
+func (h *Handler) changePassword(c *gin.Context) {
+	identity, err := h.getIdentity(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.ChangePasswordInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tokens, err := h.services.Authorization.ChangePassword(identity, input)
+	if err != nil {
+		if reauthFailed(c, err, "current password is wrong") {
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, newTokenResponse(tokens))
+}
+
+// @Summary Delete Account
+// @Security ApiKeyAuth
+// @Tags me
+// @Description delete the account and every list nobody else can edit, lists shared with other owners or editors are handed to one of them. Returns an export of the deleted data. Users without a password confirm it is them with a reauth_token or a two-factor code.
+// @ID delete-account
+// @Accept json
+// @Produce json
+// @Param input body todo.DeleteAccountInput true "current password"
+// @Success 200 {object} todo.AccountExport
+// @Failure 400,401,403,429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/me [delete]
+func (h *Handler) deleteAccount(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.DeleteAccountInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	export, err := h.services.Authorization.DeleteAccount(userId, input)
+	if err != nil {
+		if reauthFailed(c, err, "password is wrong") {
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+// reauthFailed answers the errors of a failed password check or
+// todo.Reauthentication, it reports whether err was one.
+func reauthFailed(c *gin.Context, err error, wrongPassword string) bool {
+	switch {
+	case tooManyAttempts(c, err):
+	case errors.Is(err, service.ErrInvalidCredentials):
+		newErrorResponse(c, http.StatusUnauthorized, wrongPassword)
+	case errors.Is(err, service.ErrInvalidUserToken), errors.Is(err, service.ErrInvalidTwoFactorCode):
+		newErrorResponse(c, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, service.ErrReauthRequired):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"github.com/Olmosbek510/todo-app"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type getAllRemindersResponse struct {
+	Data []todo.Reminder `json:"data"`
+}
+
+// @Summary Create Reminder
+// @Security ApiKeyAuth
+// @Tags reminders
+// @Description remind the user about an item at remind_at or offset_minutes before it is due, through email (the default), webhook or log
+// @ID create-reminder
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param input body todo.CreateReminderInput true "reminder info"
+// @Success 200 {integer} integer 1
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/reminders [post]
+func (h *Handler) createReminder(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.CreateReminderInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := h.services.Reminder.Create(userId, itemId, input)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
+// @Summary Get Item Reminders
+// @Security ApiKeyAuth
+// @Tags reminders
+// @Description get the reminders of the user on an item with their delivery status
+// @ID get-item-reminders
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} getAllRemindersResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/reminders [get]
+func (h *Handler) getItemReminders(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	reminders, err := h.services.Reminder.GetAllByItem(userId, itemId)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, getAllRemindersResponse{Data: reminders})
+}
+
+// @Summary Delete Reminder
+// @Security ApiKeyAuth
+// @Tags reminders
+// @Description delete a reminder of the user
+// @ID delete-reminder
+// @Produce json
+// @Param id path int true "Reminder ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/reminders/{id} [delete]
+func (h *Handler) deleteReminder(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Reminder.Delete(userId, id); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
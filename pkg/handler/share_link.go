@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/service"
+	"github.com/gin-gonic/gin"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const sharePasswordHeader = "X-Share-Password"
+
+type createShareLinkResponse struct {
+	Id    int    `json:"id"`
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+type getAllShareLinksResponse struct {
+	Data []todo.ShareLink `json:"data"`
+}
+
+// @Summary Create Share Link
+// @Security ApiKeyAuth
+// @Tags share-links
+// @Description create a public read-only link to the list, only owners can share. The token is only shown once.
+// @ID create-share-link
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.CreateShareLinkInput true "optional expiry and password"
+// @Success 200 {object} createShareLinkResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/share-links [post]
+func (h *Handler) createShareLink(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	var input todo.CreateShareLinkInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, token, err := h.services.ShareLink.Create(userId, listId, input)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, createShareLinkResponse{Id: id, Token: token, URL: h.services.ShareLink.URL(token)})
+}
+
+// @Summary Get Share Links
+// @Security ApiKeyAuth
+// @Tags share-links
+// @Description get the share links of a list, only owners can see them
+// @ID get-share-links
+// @Produce json
+// @Param id path int true "List ID"
+// @Success 200 {object} getAllShareLinksResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/share-links [get]
+func (h *Handler) getAllShareLinks(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	links, err := h.services.ShareLink.GetAll(userId, listId)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, getAllShareLinksResponse{Data: links})
+}
+
+// @Summary Revoke Share Link
+// @Security ApiKeyAuth
+// @Tags share-links
+// @Description delete a share link, it stops working immediately
+// @ID revoke-share-link
+// @Produce json
+// @Param id path int true "List ID"
+// @Param link_id path int true "Share link ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/share-links/{link_id} [delete]
+func (h *Handler) revokeShareLink(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+	id, err := strconv.Atoi(c.Param("link_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid share link id param")
+		return
+	}
+
+	if err := h.services.ShareLink.Revoke(userId, listId, id); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+var sharedListPage = template.Must(template.New("shared-list").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>{{if .List}}{{.List.Title}}{{else}}Shared list{{end}}</title>
+    <style>
+        body { font-family: sans-serif; max-width: 40rem; margin: 2rem auto; padding: 0 1rem; }
+        ul { list-style: none; padding: 0; }
+        li ul { padding-left: 1.5rem; }
+        li { padding: .25rem 0; }
+        li.done span { text-decoration: line-through; color: #888; }
+    </style>
+</head>
+<body>
+{{- if .List}}
+<h1>{{.List.Title}}</h1>
+{{- with .List.Description}}
+<p>{{.}}</p>
+{{- end}}
+{{- if .List.Items}}
+{{template "items" .List.Items}}
+{{- else}}
+<p>This list is empty.</p>
+{{- end}}
+{{- else}}
+<h1>Shared list</h1>
+<p>{{.Error}}</p>
+{{- if .AskPassword}}
+<form method="post">
+    <input type="password" name="password" placeholder="Password" required autofocus>
+    <button type="submit">Open</button>
+</form>
+{{- end}}
+{{- end}}
+</body>
+</html>
+{{- define "items"}}
+<ul>
+    {{- range .}}
+    <li{{if .Done}} class="done"{{end}}>
+        <input type="checkbox" disabled{{if .Done}} checked{{end}}> <span>{{.Title}}</span>
+        {{- with .Description}}<br><small>{{.}}</small>{{end}}
+        {{- if .DueAt}}<br><small>due {{if .AllDay}}{{.DueAt.Format "Jan 2, 2006"}}{{else}}{{.DueAt.Format "Jan 2, 2006 15:04 MST"}}{{end}}</small>{{end}}
+        {{- if .Children}}{{template "items" .Children}}{{end}}
+    </li>
+    {{- end}}
+</ul>
+{{- end}}
+`))
+
+type sharedListPageData struct {
+	List        *todo.SharedList
+	Error       string
+	AskPassword bool
+}
+
+// @Summary Open Share Link
+// @Tags share-links
+// @Description read a shared list without an account. Answers with an HTML page when the client prefers text/html. The password of protected links is sent in the X-Share-Password header or, with POST, as the form field password.
+// @ID open-share-link
+// @Produce json,html
+// @Param token path string true "share link token"
+// @Param X-Share-Password header string false "password of a protected link"
+// @Success 200 {object} todo.SharedList
+// @Failure 401,404 {object} errorResponse
+// @Failure 429 {object} errorResponse "too many wrong passwords, see the Retry-After header"
+// @Failure 500 {object} errorResponse
+// @Router /share/{token} [get]
+func (h *Handler) openShareLink(c *gin.Context) {
+	// the token is part of the URL, keep it out of caches, search engines and
+	// the referrer of outgoing links
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex")
+
+	password := c.GetHeader(sharePasswordHeader)
+	if password == "" {
+		password = c.PostForm("password")
+	}
+
+	list, err := h.services.ShareLink.Open(c.Param("token"), password, c.ClientIP())
+	status := http.StatusOK
+	if err != nil {
+		var tooMany *service.TooManyAttemptsError
+		switch {
+		case errors.As(err, &tooMany):
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(tooMany.RetryAfter.Seconds()))))
+			status = http.StatusTooManyRequests
+		case errors.Is(err, service.ErrShareLinkNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrSharePasswordRequired), errors.Is(err, service.ErrInvalidSharePassword):
+			status = http.StatusUnauthorized
+		default:
+			status = http.StatusInternalServerError
+		}
+	}
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) != gin.MIMEHTML {
+		if err != nil {
+			newErrorResponse(c, status, err.Error())
+			return
+		}
+		c.JSON(status, list)
+		return
+	}
+
+	data := sharedListPageData{}
+	if err != nil {
+		data.Error = err.Error()
+		data.AskPassword = status == http.StatusUnauthorized
+		if status == http.StatusInternalServerError {
+			newErrorResponse(c, status, err.Error())
+			return
+		}
+	} else {
+		data.List = &list
+	}
+
+	var page bytes.Buffer
+	if err := sharedListPage.Execute(&page, data); err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", page.Bytes())
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type createTokenResponse struct {
+	Id    int    `json:"id"`
+	Token string `json:"token"`
+}
+
+// @Summary Create Personal Access Token
+// @Security ApiKeyAuth
+// @Tags tokens
+// @Description create a scoped token for scripts, the token is only shown once
+// @ID create-token
+// @Accept json
+// @Produce json
+// @Param input body todo.CreateTokenInput true "token info"
+// @Success 200 {object} createTokenResponse
+// @Failure 400,403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/tokens [post]
+func (h *Handler) createToken(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.CreateTokenInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, token, err := h.services.PersonalAccessToken.Create(userId, input)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, createTokenResponse{Id: id, Token: token})
+}
+
+type getAllTokensResponse struct {
+	Data []todo.PersonalAccessToken `json:"data"`
+}
+
+// @Summary Get All Personal Access Tokens
+// @Security ApiKeyAuth
+// @Tags tokens
+// @Description list the personal access tokens of the current user
+// @ID get-all-tokens
+// @Produce json
+// @Success 200 {object} getAllTokensResponse
+// @Failure 403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/tokens [get]
+func (h *Handler) getAllTokens(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	tokens, err := h.services.PersonalAccessToken.GetAll(userId)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, getAllTokensResponse{Data: tokens})
+}
+
+// @Summary Revoke Personal Access Token
+// @Security ApiKeyAuth
+// @Tags tokens
+// @Description revoke a personal access token
+// @ID delete-token
+// @Produce json
+// @Param id path int true "Token ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/tokens/{id} [delete]
+func (h *Handler) deleteToken(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.PersonalAccessToken.Delete(userId, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			newErrorResponse(c, http.StatusNotFound, "token not found")
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
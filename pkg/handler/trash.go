@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// @Summary Get Trash
+// @Security ApiKeyAuth
+// @Tags trash
+// @Description get the deleted lists the user owns and the deleted items of lists they can edit, the most recently deleted first. purge_at is when they are deleted for good.
+// @ID get-trash
+// @Produce json
+// @Success 200 {object} todo.Trash
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/trash [get]
+func (h *Handler) getTrash(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	trash, err := h.services.Trash.GetAll(userId)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, trash)
+}
+
+// @Summary Restore List
+// @Security ApiKeyAuth
+// @Tags trash
+// @Description take a list out of the trash together with the items deleted along with it, only owners can. Items deleted on their own before stay in the trash.
+// @ID restore-list
+// @Produce json
+// @Param id path int true "List ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/trash/lists/{id}/restore [post]
+func (h *Handler) restoreList(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Trash.RestoreList(userId, id); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Delete List Permanently
+// @Security ApiKeyAuth
+// @Tags trash
+// @Description delete a list in the trash for good with all of its items, only owners can
+// @ID purge-list
+// @Produce json
+// @Param id path int true "List ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/trash/lists/{id} [delete]
+func (h *Handler) purgeList(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Trash.DeleteList(userId, id); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Restore Item
+// @Security ApiKeyAuth
+// @Tags trash
+// @Description take an item out of the trash together with the subtasks deleted along with it, editors and owners of the list can. An item whose parent is still in the trash comes back at the top level.
+// @ID restore-item
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/trash/items/{id}/restore [post]
+func (h *Handler) restoreItem(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Trash.RestoreItem(userId, id); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Delete Item Permanently
+// @Security ApiKeyAuth
+// @Tags trash
+// @Description delete an item in the trash for good with all of its subtasks, editors and owners of the list can
+// @ID purge-item
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/trash/items/{id} [delete]
+func (h *Handler) purgeItem(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Trash.DeleteItem(userId, id); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
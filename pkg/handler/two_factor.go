@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"github.com/Olmosbek510/todo-app/pkg/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type twoFactorCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func twoFactorErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidTwoFactorCode):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, service.ErrTwoFactorNotEnrolled),
+		errors.Is(err, service.ErrTwoFactorNotEnabled):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// @Summary Enroll Two-Factor
+// @Security ApiKeyAuth
+// @Tags 2fa
+// @Description create a TOTP secret, it becomes active after confirmation
+// @ID enroll-2fa
+// @Produce json
+// @Success 200 {object} todo.TwoFactorEnrollment
+// @Failure 403,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/2fa/enroll [post]
+func (h *Handler) enrollTwoFactor(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	enrollment, err := h.services.Authorization.EnrollTOTP(userId)
+	if err != nil {
+		newErrorResponse(c, twoFactorErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// @Summary Confirm Two-Factor
+// @Security ApiKeyAuth
+// @Tags 2fa
+// @Description enable two-factor authentication with a code from the authenticator app, returns recovery codes
+// @ID confirm-2fa
+// @Accept json
+// @Produce json
+// @Param input body twoFactorCodeInput true "authenticator code"
+// @Success 200 {object} recoveryCodesResponse
+// @Failure 400,403,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/2fa/confirm [post]
+func (h *Handler) confirmTwoFactor(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input twoFactorCodeInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	codes, err := h.services.Authorization.ConfirmTOTP(userId, input.Code)
+	if err != nil {
+		newErrorResponse(c, twoFactorErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Disable Two-Factor
+// @Security ApiKeyAuth
+// @Tags 2fa
+// @Description disable two-factor authentication with an authenticator or recovery code
+// @ID disable-2fa
+// @Accept json
+// @Produce json
+// @Param input body twoFactorCodeInput true "authenticator or recovery code"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/2fa/disable [post]
+func (h *Handler) disableTwoFactor(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input twoFactorCodeInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.DisableTOTP(userId, input.Code); err != nil {
+		newErrorResponse(c, twoFactorErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
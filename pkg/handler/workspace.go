@@ -0,0 +1,362 @@
+package handler
+
+import (
+	"github.com/Olmosbek510/todo-app"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type getAllWorkspacesResponse struct {
+	Data []todo.Workspace `json:"data"`
+}
+
+type getAllWorkspaceMembersResponse struct {
+	Data []todo.WorkspaceMember `json:"data"`
+}
+
+// @Summary Create Workspace
+// @Security ApiKeyAuth
+// @Tags workspaces
+// @Description create a workspace, the creator becomes its owner
+// @ID create-workspace
+// @Accept json
+// @Produce json
+// @Param input body todo.CreateWorkspaceInput true "workspace info"
+// @Success 200 {integer} integer 1
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/workspaces [post]
+func (h *Handler) createWorkspace(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.CreateWorkspaceInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := h.services.Workspace.Create(userId, input)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
+// @Summary Get All Workspaces
+// @Security ApiKeyAuth
+// @Tags workspaces
+// @Description get the workspaces the user is a member of, the personal one first
+// @ID get-all-workspaces
+// @Produce json
+// @Success 200 {object} getAllWorkspacesResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/workspaces [get]
+func (h *Handler) getAllWorkspaces(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	workspaces, err := h.services.Workspace.GetAll(userId)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, getAllWorkspacesResponse{Data: workspaces})
+}
+
+// @Summary Get Workspace By ID
+// @Security ApiKeyAuth
+// @Tags workspaces
+// @Description get a workspace the user is a member of
+// @ID get-workspace-by-id
+// @Produce json
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} todo.Workspace
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/workspaces/{id} [get]
+func (h *Handler) getWorkspaceById(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	workspace, err := h.services.Workspace.GetById(userId, id)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, workspace)
+}
+
+// @Summary Update Workspace
+// @Security ApiKeyAuth
+// @Tags workspaces
+// @Description rename a workspace, only owners can change it
+// @ID update-workspace
+// @Accept json
+// @Produce json
+// @Param id path int true "Workspace ID"
+// @Param input body todo.UpdateWorkspaceInput true "workspace fields"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/workspaces/{id} [put]
+func (h *Handler) updateWorkspace(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.UpdateWorkspaceInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Workspace.Update(userId, id, input); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Delete Workspace
+// @Security ApiKeyAuth
+// @Tags workspaces
+// @Description delete a workspace with all of its lists, only owners can delete it
+// @ID delete-workspace
+// @Produce json
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/workspaces/{id} [delete]
+func (h *Handler) deleteWorkspace(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Workspace.Delete(userId, id); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Get Workspace Lists
+// @Security ApiKeyAuth
+// @Tags workspaces
+// @Description get the lists of a workspace
+// @ID get-workspace-lists
+// @Produce json
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} getAllListsResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/workspaces/{id}/lists [get]
+func (h *Handler) getWorkspaceLists(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	lists, err := h.services.Workspace.GetLists(userId, id)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, getAllListsResponse{Data: lists})
+}
+
+// @Summary Get Workspace Members
+// @Security ApiKeyAuth
+// @Tags workspaces
+// @Description get the members of a workspace and their roles
+// @ID get-workspace-members
+// @Produce json
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} getAllWorkspaceMembersResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/workspaces/{id}/members [get]
+func (h *Handler) getWorkspaceMembers(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	members, err := h.services.Workspace.GetMembers(userId, id)
+	if err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, getAllWorkspaceMembersResponse{Data: members})
+}
+
+// @Summary Add Workspace Member
+// @Security ApiKeyAuth
+// @Tags workspaces
+// @Description add a user to a workspace, they can reach all of its lists with the given role. Only owners can add members.
+// @ID add-workspace-member
+// @Accept json
+// @Produce json
+// @Param id path int true "Workspace ID"
+// @Param input body todo.AddMemberInput true "username and role"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/workspaces/{id}/members [post]
+func (h *Handler) addWorkspaceMember(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.AddMemberInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Workspace.AddMember(userId, id, input); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Change Workspace Member Role
+// @Security ApiKeyAuth
+// @Tags workspaces
+// @Description change the role of a workspace member, only owners can change roles
+// @ID update-workspace-member
+// @Accept json
+// @Produce json
+// @Param id path int true "Workspace ID"
+// @Param user_id path int true "User ID of the member"
+// @Param input body todo.UpdateMemberInput true "new role"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/workspaces/{id}/members/{user_id} [put]
+func (h *Handler) updateWorkspaceMember(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, memberId, ok := memberParams(c)
+	if !ok {
+		return
+	}
+
+	var input todo.UpdateMemberInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Workspace.UpdateMemberRole(userId, id, memberId, input); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Remove Workspace Member
+// @Security ApiKeyAuth
+// @Tags workspaces
+// @Description remove a member, which revokes their access to every list of the workspace. Members can also remove themselves to leave.
+// @ID remove-workspace-member
+// @Produce json
+// @Param id path int true "Workspace ID"
+// @Param user_id path int true "User ID of the member"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/workspaces/{id}/members/{user_id} [delete]
+func (h *Handler) removeWorkspaceMember(c *gin.Context) {
+	userId, err := h.getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, memberId, ok := memberParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Workspace.RemoveMember(userId, id, memberId); err != nil {
+		newErrorResponse(c, listErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
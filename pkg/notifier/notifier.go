@@ -0,0 +1,145 @@
+// Package notifier delivers notifications to users, by email, to a webhook
+// or into the log.
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Olmosbek510/todo-app/pkg/mailer"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Channels notifications can be sent through.
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelLog     = "log"
+)
+
+type Notification struct {
+	// Event names what happened, like "reminder".
+	Event  string
+	UserId int
+	// Email is the verified address of the user, empty without one.
+	Email   string
+	Subject string
+	Body    string
+	// Data is sent as is by the webhook notifier.
+	Data map[string]interface{}
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// ErrPermanent marks failures that retrying will not fix.
+var ErrPermanent = errors.New("notification cannot be delivered")
+
+func permanent(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrPermanent, fmt.Sprintf(format, args...))
+}
+
+// EmailNotifier mails notifications to the address of the user.
+type EmailNotifier struct {
+	mailer mailer.Mailer
+}
+
+func NewEmailNotifier(m mailer.Mailer) *EmailNotifier {
+	return &EmailNotifier{mailer: m}
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, notification Notification) error {
+	if notification.Email == "" {
+		return permanent("user %d has no verified email", notification.UserId)
+	}
+	return n.mailer.Send(mailer.Message{
+		To:      notification.Email,
+		Subject: notification.Subject,
+		Body:    notification.Body,
+	})
+}
+
+// WebhookNotifier posts notifications as JSON to a URL. The body is signed
+// with HMAC-SHA256 of the secret in the X-Todo-Signature header, so the
+// receiver can tell it came from the app.
+type WebhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url, secret string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{url: url, secret: secret, client: &http.Client{Timeout: timeout}}
+}
+
+type webhookPayload struct {
+	Event   string                 `json:"event"`
+	UserId  int                    `json:"user_id"`
+	Subject string                 `json:"subject"`
+	Body    string                 `json:"body"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+	SentAt  time.Time              `json:"sent_at"`
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(webhookPayload{
+		Event:   notification.Event,
+		UserId:  notification.UserId,
+		Subject: notification.Subject,
+		Body:    notification.Body,
+		Data:    notification.Data,
+		SentAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.secret))
+		mac.Write(body)
+		req.Header.Set("X-Todo-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	// the receiver rejected the request, sending it again will not help
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests &&
+		resp.StatusCode != http.StatusRequestTimeout:
+		return permanent("webhook answered %d", resp.StatusCode)
+	}
+	return fmt.Errorf("webhook answered %d", resp.StatusCode)
+}
+
+// LogNotifier writes notifications to the log, for local development.
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	logrus.Infof("notification %s for user %d: %s", notification.Event, notification.UserId, notification.Subject)
+	return nil
+}
@@ -0,0 +1,208 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID = "todo-app"
+	testNonce    = "n-0S6_WzA2Mj"
+	testCode     = "SplxlOBeZQQYbYS6WxSbIA"
+	testVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// fakeIssuer is a mock OpenID Connect provider. Its token endpoint signs the
+// claims set by the test with the key named by kid.
+type fakeIssuer struct {
+	url string
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims jwt.MapClaims
+	kid    string
+	// jwksFetches counts the requests for the key set.
+	jwksFetches int
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &fakeIssuer{key: key, kid: "k1"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(metadata{
+			Issuer:                issuer.url,
+			AuthorizationEndpoint: issuer.url + "/authorize",
+			TokenEndpoint:         issuer.url + "/token",
+			JWKSURI:               issuer.url + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		issuer.mu.Lock()
+		issuer.jwksFetches++
+		issuer.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jsonWebKey{{
+			Kty: "RSA",
+			Kid: "k1",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != testCode || r.PostFormValue("code_verifier") != testVerifier {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		issuer.mu.Lock()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, issuer.claims)
+		if issuer.kid != "" {
+			token.Header["kid"] = issuer.kid
+		}
+		issuer.mu.Unlock()
+		signed, err := token.SignedString(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	issuer.url = srv.URL
+	return issuer
+}
+
+// validClaims are the claims of an ID token the provider accepts.
+func (i *fakeIssuer) validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            i.url,
+		"aud":            testClientID,
+		"sub":            "248289761001",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          testNonce,
+		"email":          "jane@example.com",
+		"email_verified": true,
+	}
+}
+
+func (i *fakeIssuer) sign(claims jwt.MapClaims, kid string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.claims = claims
+	i.kid = kid
+}
+
+func (i *fakeIssuer) provider() *Provider {
+	return NewProvider(Config{Issuer: i.url, ClientID: testClientID, RedirectURL: "http://localhost/callback"})
+}
+
+func TestExchange(t *testing.T) {
+	issuer := newFakeIssuer(t)
+
+	tests := []struct {
+		name    string
+		change  func(claims jwt.MapClaims)
+		kid     string
+		wantErr string
+	}{
+		{name: "valid", change: func(jwt.MapClaims) {}, kid: "k1"},
+		{name: "audience list", change: func(c jwt.MapClaims) { c["aud"] = []string{"other", testClientID} }, kid: "k1"},
+		{name: "wrong nonce", change: func(c jwt.MapClaims) { c["nonce"] = "replayed" }, kid: "k1",
+			wantErr: "nonce mismatch"},
+		{name: "no nonce", change: func(c jwt.MapClaims) { delete(c, "nonce") }, kid: "k1",
+			wantErr: "nonce mismatch"},
+		{name: "wrong audience", change: func(c jwt.MapClaims) { c["aud"] = "other-client" }, kid: "k1",
+			wantErr: "wrong audience"},
+		{name: "wrong issuer", change: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, kid: "k1",
+			wantErr: "wrong issuer"},
+		{name: "expired", change: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, kid: "k1",
+			wantErr: "expired"},
+		{name: "no expiry", change: func(c jwt.MapClaims) { delete(c, "exp") }, kid: "k1",
+			wantErr: "no expiry"},
+		{name: "no subject", change: func(c jwt.MapClaims) { delete(c, "sub") }, kid: "k1",
+			wantErr: "no subject"},
+		{name: "unknown kid", change: func(jwt.MapClaims) {}, kid: "rotated-away",
+			wantErr: `unknown key id "rotated-away"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := issuer.validClaims()
+			tt.change(claims)
+			issuer.sign(claims, tt.kid)
+
+			got, err := issuer.provider().Exchange(context.Background(), testCode, testVerifier, testNonce)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Exchange() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if got.Subject != "248289761001" || got.Email != "jane@example.com" || !got.EmailVerified {
+				t.Errorf("Exchange() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestExchangeWrongCode(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.sign(issuer.validClaims(), "k1")
+
+	_, err := issuer.provider().Exchange(context.Background(), "stolen", testVerifier, testNonce)
+	if err == nil || !strings.Contains(err.Error(), "returned 400") {
+		t.Fatalf("Exchange() error = %v, want the token endpoint to refuse", err)
+	}
+}
+
+func TestExchangeWithoutKid(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.sign(issuer.validClaims(), "")
+	p := issuer.provider()
+
+	// the second login is served from the cached key set
+	for i := 0; i < 2; i++ {
+		if _, err := p.Exchange(context.Background(), testCode, testVerifier, testNonce); err != nil {
+			t.Fatalf("login %d: Exchange() error = %v", i+1, err)
+		}
+	}
+	if issuer.jwksFetches != 1 {
+		t.Errorf("key set fetched %d times, want 1", issuer.jwksFetches)
+	}
+}
+
+func TestExchangeUnknownKidRefetchesOnce(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.sign(issuer.validClaims(), "k2")
+	p := issuer.provider()
+
+	for i := 0; i < 3; i++ {
+		if _, err := p.Exchange(context.Background(), testCode, testVerifier, testNonce); err == nil {
+			t.Fatalf("login %d: Exchange() succeeded with an unknown kid", i+1)
+		}
+	}
+	if issuer.jwksFetches != 1 {
+		t.Errorf("key set fetched %d times, want 1 within a minute", issuer.jwksFetches)
+	}
+}
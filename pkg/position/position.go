@@ -0,0 +1,232 @@
+// Package position generates sort keys for manually ordered rows. A key can
+// always be generated between two others, so moving a row only rewrites that
+// row. Keys are compared byte by byte, columns holding them need the "C"
+// collation.
+//
+// A key is a variable length integer followed by an optional fraction, both
+// in base 62. The first character of the integer encodes its length, which
+// keeps keys short when rows are appended one after another: the integers
+// run a0..az, b00..bzz and so on. Rows placed between two neighbours get a
+// fraction.
+package position
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	zero     = "a0"
+	smallest = "A00000000000000000000000000"
+)
+
+var (
+	ErrOrder      = errors.New("position: keys are not in order")
+	ErrInvalidKey = errors.New("position: invalid key")
+	ErrExhausted  = errors.New("position: no key left at the edge of the order")
+)
+
+// Between returns a key that sorts after before and ahead of after. An empty
+// before stands for the start and an empty after for the end of the order.
+func Between(before, after string) (string, error) {
+	if before != "" {
+		if err := validate(before); err != nil {
+			return "", err
+		}
+	}
+	if after != "" {
+		if err := validate(after); err != nil {
+			return "", err
+		}
+	}
+	if before != "" && after != "" && before >= after {
+		return "", ErrOrder
+	}
+
+	switch {
+	case before == "" && after == "":
+		return zero, nil
+
+	case before == "":
+		intB := integerPart(after)
+		fracB := after[len(intB):]
+		if intB == smallest {
+			return intB + midpoint("", fracB), nil
+		}
+		if intB < after {
+			return intB, nil
+		}
+		key, ok := decrement(intB)
+		if !ok {
+			return "", ErrExhausted
+		}
+		return key, nil
+
+	case after == "":
+		intA := integerPart(before)
+		fracA := before[len(intA):]
+		key, ok := increment(intA)
+		if !ok {
+			return intA + midpoint(fracA, ""), nil
+		}
+		return key, nil
+	}
+
+	intA := integerPart(before)
+	fracA := before[len(intA):]
+	intB := integerPart(after)
+	fracB := after[len(intB):]
+	if intA == intB {
+		return intA + midpoint(fracA, fracB), nil
+	}
+	key, ok := increment(intA)
+	if !ok {
+		return "", ErrExhausted
+	}
+	if key < after {
+		return key, nil
+	}
+	return intA + midpoint(fracA, ""), nil
+}
+
+// midpoint returns a fraction between a and b, an empty b being the end.
+// No fraction ends in the lowest digit, so there is always room in front of
+// any of them.
+func midpoint(a, b string) string {
+	if b != "" {
+		// keep the common prefix, a missing digit of a counts as the lowest
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(tail(a, n), b[n:])
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(digits, a[0])
+	}
+	digitB := len(digits)
+	if b != "" {
+		digitB = strings.IndexByte(digits, b[0])
+	}
+
+	if digitB-digitA > 1 {
+		return string(digits[(digitA+digitB+1)/2])
+	}
+	// the first digits are adjacent
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(digits[digitA]) + midpoint(tail(a, 1), "")
+}
+
+// integerLength is the length of the integer part starting with head, a..z
+// for positive integers of growing length and Z..A for negative ones.
+func integerLength(head byte) int {
+	switch {
+	case head >= 'a' && head <= 'z':
+		return int(head-'a') + 2
+	case head >= 'A' && head <= 'Z':
+		return int('Z'-head) + 2
+	}
+	return 0
+}
+
+func integerPart(key string) string {
+	return key[:integerLength(key[0])]
+}
+
+func validate(key string) error {
+	length := integerLength(key[0])
+	if length == 0 || length > len(key) || key == smallest {
+		return ErrInvalidKey
+	}
+	for i := 1; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return ErrInvalidKey
+		}
+	}
+	if len(key) > length && key[len(key)-1] == digits[0] {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+func increment(integer string) (string, bool) {
+	head, digs := integer[0], []byte(integer[1:])
+	carry := true
+	for i := len(digs) - 1; carry && i >= 0; i-- {
+		d := strings.IndexByte(digits, digs[i]) + 1
+		if d == len(digits) {
+			digs[i] = digits[0]
+		} else {
+			digs[i] = digits[d]
+			carry = false
+		}
+	}
+	if !carry {
+		return string(head) + string(digs), true
+	}
+	if head == 'Z' {
+		return zero, true
+	}
+	if head == 'z' {
+		return "", false
+	}
+	head++
+	if head > 'a' {
+		digs = append(digs, digits[0])
+	} else {
+		digs = digs[:len(digs)-1]
+	}
+	return string(head) + string(digs), true
+}
+
+func decrement(integer string) (string, bool) {
+	head, digs := integer[0], []byte(integer[1:])
+	borrow := true
+	for i := len(digs) - 1; borrow && i >= 0; i-- {
+		d := strings.IndexByte(digits, digs[i]) - 1
+		if d == -1 {
+			digs[i] = digits[len(digits)-1]
+		} else {
+			digs[i] = digits[d]
+			borrow = false
+		}
+	}
+	if !borrow {
+		return string(head) + string(digs), true
+	}
+	if head == 'a' {
+		return "Z" + string(digits[len(digits)-1]), true
+	}
+	if head == 'A' {
+		return "", false
+	}
+	head--
+	if head < 'Z' {
+		digs = append(digs, digits[len(digits)-1])
+	} else {
+		digs = digs[:len(digs)-1]
+	}
+	return string(head) + string(digs), true
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return digits[0]
+}
+
+func tail(key string, n int) string {
+	if n >= len(key) {
+		return ""
+	}
+	return key[n:]
+}
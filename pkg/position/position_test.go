@@ -0,0 +1,159 @@
+package position
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// largest is the highest integer part, nothing can be appended after it
+// without a fraction.
+var largest = "z" + strings.Repeat("z", integerLength('z')-1)
+
+func mustBetween(t *testing.T, before, after string) string {
+	t.Helper()
+	key, err := Between(before, after)
+	if err != nil {
+		t.Fatalf("Between(%q, %q): %v", before, after, err)
+	}
+	if err := validate(key); err != nil {
+		t.Fatalf("Between(%q, %q) = %q, which is invalid", before, after, key)
+	}
+	if before != "" && key <= before || after != "" && key >= after {
+		t.Fatalf("Between(%q, %q) = %q, out of order", before, after, key)
+	}
+	return key
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		before, after string
+		want          string
+	}{
+		{"", "", "a0"},
+		{"a0", "", "a1"},
+		{"", "a0", "Zz"},
+		{"az", "", "b00"},
+		{"", "Zz", "Zy"},
+		{"a0", "a1", "a0V"},
+		{"a0", "a2", "a1"},
+		{"a0V", "a1", "a0l"},
+		{"a0", "a0V", "a0G"},
+		{"a1", "a1001", "a1000V"},
+		{"Zz", "a0", "ZzV"},
+		{"a0", "b00", "a1"},
+	}
+	for _, tt := range tests {
+		if got := mustBetween(t, tt.before, tt.after); got != tt.want {
+			t.Errorf("Between(%q, %q) = %q, want %q", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestBetweenErrors(t *testing.T) {
+	tests := []struct {
+		before, after string
+		want          error
+	}{
+		{"a0", "a0", ErrOrder},
+		{"a0V", "a0V", ErrOrder},
+		{"a1", "a0", ErrOrder},
+		{"b00", "az", ErrOrder},
+		{"a0", "Zz", ErrOrder},
+		{"a", "", ErrInvalidKey},
+		{"", "a00", ErrInvalidKey},
+		{"a0!", "", ErrInvalidKey},
+		{"-0", "", ErrInvalidKey},
+		{"", smallest, ErrInvalidKey},
+		{"a0", "b0", ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		if _, err := Between(tt.before, tt.after); !errors.Is(err, tt.want) {
+			t.Errorf("Between(%q, %q) = %v, want %v", tt.before, tt.after, err, tt.want)
+		}
+	}
+}
+
+// TestBetweenAppend adds rows at the end, keys stay short.
+func TestBetweenAppend(t *testing.T) {
+	key := ""
+	for i := 0; i < 10000; i++ {
+		key = mustBetween(t, key, "")
+	}
+	if len(key) > 4 {
+		t.Errorf("key after 10000 appends is %q, want at most 4 characters", key)
+	}
+}
+
+// TestBetweenPrepend adds rows at the start, keys stay short.
+func TestBetweenPrepend(t *testing.T) {
+	key := ""
+	for i := 0; i < 10000; i++ {
+		key = mustBetween(t, "", key)
+	}
+	if len(key) > 4 {
+		t.Errorf("key after 10000 prepends is %q, want at most 4 characters", key)
+	}
+}
+
+func TestBetweenEdges(t *testing.T) {
+	// past the largest integer keys get a fraction instead
+	key := largest
+	for i := 0; i < 100; i++ {
+		key = mustBetween(t, key, "")
+	}
+	if !strings.HasPrefix(key, largest) {
+		t.Errorf("key after the largest integer is %q, want it to start with %q", key, largest)
+	}
+
+	// ahead of the smallest integer keys get a smaller fraction
+	key = smallest + "1"
+	for i := 0; i < 100; i++ {
+		key = mustBetween(t, "", key)
+	}
+	if !strings.HasPrefix(key, smallest) {
+		t.Errorf("key ahead of the smallest integer is %q, want it to start with %q", key, smallest)
+	}
+
+	if _, err := Between(smallest+"1", smallest+"1"); !errors.Is(err, ErrOrder) {
+		t.Errorf("Between of equal edge keys = %v, want ErrOrder", err)
+	}
+}
+
+// TestBetweenSameGap keeps inserting right after the first row.
+func TestBetweenSameGap(t *testing.T) {
+	first, next := "a0", "a1"
+	for i := 0; i < 1000; i++ {
+		next = mustBetween(t, first, next)
+	}
+}
+
+// TestBetweenRandom inserts rows between random neighbours, including the
+// ends, and checks the keys stay unique and in the order they were placed.
+func TestBetweenRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	keys := []string{mustBetween(t, "", "")}
+	for i := 0; i < 5000; i++ {
+		at := rnd.Intn(len(keys) + 1)
+		var before, after string
+		if at > 0 {
+			before = keys[at-1]
+		}
+		if at < len(keys) {
+			after = keys[at]
+		}
+		key := mustBetween(t, before, after)
+		keys = append(keys[:at], append([]string{key}, keys[at:]...)...)
+	}
+
+	if !sort.StringsAreSorted(keys) {
+		t.Fatal("keys are not sorted in the order they were placed")
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i] == keys[i-1] {
+			t.Fatalf("key %q was handed out twice", keys[i])
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+)
+
+type AttachmentPostgres struct {
+	db *sqlx.DB
+}
+
+func NewAttachmentPostgres(db *sqlx.DB) *AttachmentPostgres {
+	return &AttachmentPostgres{db: db}
+}
+
+const attachmentColumns = "a.id, a.item_id, a.user_id, a.filename, a.content_type, a.size, a.storage_key, a.created_at, li.list_id"
+
+// attachmentsFrom leaves out the files of items in the trash.
+var attachmentsFrom = fmt.Sprintf(`%s a
+         JOIN %s ti ON ti.id = a.item_id AND ti.deleted_at IS NULL
+         JOIN %s li ON li.item_id = a.item_id`, attachmentsTable, todoItemsTable, listsItemsTable)
+
+// Create records an uploaded file. With a quota, the files of the uploader
+// must not grow beyond it, uploads of the same user are serialized to keep
+// the check exact.
+func (r *AttachmentPostgres) Create(attachment todo.Attachment, quota int64) (int, error) {
+	var id int
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		if quota > 0 {
+			query := fmt.Sprintf("SELECT id FROM %s WHERE id = $1 FOR UPDATE", usersTable)
+			if err := tx.QueryRow(query, attachment.UserId).Scan(new(int)); err != nil {
+				return err
+			}
+			used, err := storageUsed(tx, *attachment.UserId)
+			if err != nil {
+				return err
+			}
+			if used+attachment.Size > quota {
+				return ErrQuotaExceeded
+			}
+		}
+
+		query := fmt.Sprintf(`INSERT INTO %s (item_id, user_id, filename, content_type, size, storage_key)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`, attachmentsTable)
+		return tx.QueryRow(query, attachment.ItemId, attachment.UserId, attachment.Filename, attachment.ContentType,
+			attachment.Size, attachment.StorageKey).Scan(&id)
+	})
+	return id, err
+}
+
+// GetById returns the attachment if the user can read its item.
+func (r *AttachmentPostgres) GetById(userId, id int) (todo.Attachment, error) {
+	var attachment todo.Attachment
+	query := fmt.Sprintf(`SELECT %s FROM %s
+	         JOIN %s ul ON ul.list_id = li.list_id
+	WHERE a.id = $1 AND ul.user_id = $2`, attachmentColumns, attachmentsFrom, listAccessView)
+	err := r.db.Get(&attachment, query, id, userId)
+	return attachment, err
+}
+
+// GetStored returns the attachment regardless of who asks, as long as its
+// item still exists.
+func (r *AttachmentPostgres) GetStored(id int) (todo.Attachment, error) {
+	var attachment todo.Attachment
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE a.id = $1", attachmentColumns, attachmentsFrom)
+	err := r.db.Get(&attachment, query, id)
+	return attachment, err
+}
+
+func (r *AttachmentPostgres) GetAllByItem(itemId int) ([]todo.Attachment, error) {
+	attachments := make([]todo.Attachment, 0)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE a.item_id = $1 ORDER BY a.id", attachmentColumns, attachmentsFrom)
+	err := r.db.Select(&attachments, query, itemId)
+	return attachments, err
+}
+
+func (r *AttachmentPostgres) Delete(id int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", attachmentsTable)
+	res, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// GetUsage returns the bytes taken up by the files the user uploaded to
+// items that still exist.
+func (r *AttachmentPostgres) GetUsage(userId int) (int64, error) {
+	return storageUsed(r.db, userId)
+}
+
+// GetDetached returns up to limit attachments whose item was deleted, their
+// files are still to be removed.
+func (r *AttachmentPostgres) GetDetached(limit int) ([]todo.Attachment, error) {
+	attachments := make([]todo.Attachment, 0)
+	query := fmt.Sprintf(`SELECT id, user_id, filename, content_type, size, storage_key, created_at FROM %s
+	WHERE item_id IS NULL ORDER BY id LIMIT $1`, attachmentsTable)
+	err := r.db.Select(&attachments, query, limit)
+	return attachments, err
+}
+
+func storageUsed(q sqlx.Queryer, userId int) (int64, error) {
+	var used int64
+	query := fmt.Sprintf("SELECT coalesce(sum(size), 0) FROM %s WHERE user_id = $1 AND item_id IS NOT NULL", attachmentsTable)
+	err := q.QueryRowx(query, userId).Scan(&used)
+	return used, err
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+)
+
+type AuditPostgres struct {
+	db *sqlx.DB
+}
+
+func NewAuditPostgres(db *sqlx.DB) *AuditPostgres {
+	return &AuditPostgres{db: db}
+}
+
+func (r *AuditPostgres) Record(entry todo.AuditEntry) error {
+	query := fmt.Sprintf("INSERT INTO %s (user_id, event, ip, detail) VALUES ($1, $2, $3, $4)", auditLogTable)
+	_, err := r.db.Exec(query, entry.UserId, entry.Event, entry.IP, entry.Detail)
+	return err
+}
@@ -1,32 +1,280 @@
 package repository
 
 import (
+	"database/sql"
 	"fmt"
 	"github.com/Olmosbek510/todo-app"
 	"github.com/jmoiron/sqlx"
+	"strings"
 )
 
 type AuthPostgres struct {
 	db *sqlx.DB
 }
 
-func (r *AuthPostgres) GetUser(username, password string) (todo.User, error) {
+const userColumns = "id, name, username, email, password_hash, email_verified_at, totp_secret, totp_enabled, totp_last_step, time_zone"
+
+func (r *AuthPostgres) GetUser(username string) (todo.User, error) {
+	var user todo.User
+	query := fmt.Sprintf("SELECT %s FROM %s AS u where u.username = $1", userColumns, usersTable)
+	err := r.db.Get(&user, query, username)
+	return user, err
+}
+
+func (r *AuthPostgres) GetUserById(id int) (todo.User, error) {
 	var user todo.User
-	query := fmt.Sprintf("SELECT id FROM %s AS u where u.username = $1 AND u.password_hash = $2", usersTable)
-	err := r.db.Get(&user, query, username, password)
+	query := fmt.Sprintf("SELECT %s FROM %s AS u where u.id = $1", userColumns, usersTable)
+	err := r.db.Get(&user, query, id)
 	return user, err
 }
 
+func (r *AuthPostgres) GetUserByEmail(email string) (todo.User, error) {
+	var user todo.User
+	query := fmt.Sprintf("SELECT %s FROM %s AS u where u.email = $1", userColumns, usersTable)
+	err := r.db.Get(&user, query, email)
+	return user, err
+}
+
+// MarkEmailVerified only succeeds while the user still has the given email,
+// so a verification link goes stale once the address is changed.
+func (r *AuthPostgres) MarkEmailVerified(userId int, email string) error {
+	query := fmt.Sprintf("UPDATE %s SET email_verified_at = now() WHERE id = $1 AND email = $2", usersTable)
+	res, err := r.db.Exec(query, userId, email)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (r *AuthPostgres) UpdatePasswordHash(userId int, passwordHash string) error {
+	query := fmt.Sprintf("UPDATE %s SET password_hash = $1 WHERE id = $2", usersTable)
+	_, err := r.db.Exec(query, passwordHash, userId)
+	return err
+}
+
 func NewAuthPostgres(db *sqlx.DB) *AuthPostgres {
 	return &AuthPostgres{db: db}
 }
 
 func (r *AuthPostgres) CreateUser(user todo.User) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
 	var id int
-	query := fmt.Sprintf("INSERT INTO %s (name, username, password_hash) VALUES ($1, $2, $3) RETURNING id", usersTable)
-	row := r.db.QueryRow(query, user.Name, user.Username, user.Password)
+	query := fmt.Sprintf("INSERT INTO %s (name, username, email, password_hash) VALUES ($1, $2, $3, $4) RETURNING id", usersTable)
+	row := tx.QueryRow(query, user.Name, user.Username, user.Email, user.PasswordHash)
 	if err := row.Scan(&id); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := createPersonalWorkspace(tx, id); err != nil {
+		tx.Rollback()
 		return 0, err
 	}
-	return id, nil
+	return id, tx.Commit()
+}
+
+func (r *AuthPostgres) SetTOTPSecret(userId int, secret string) error {
+	query := fmt.Sprintf("UPDATE %s SET totp_secret = $1, totp_last_step = NULL WHERE id = $2 AND NOT totp_enabled", usersTable)
+	res, err := r.db.Exec(query, secret, userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// EnableTOTP switches two-factor authentication on and replaces the
+// recovery codes of the user.
+func (r *AuthPostgres) EnableTOTP(userId int, lastStep int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	enableQuery := fmt.Sprintf(`UPDATE %s SET totp_enabled = true, totp_last_step = $1
+	WHERE id = $2 AND totp_secret IS NOT NULL AND NOT totp_enabled`, usersTable)
+	res, err := tx.Exec(enableQuery, lastStep, userId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := replaceRecoveryCodes(tx, userId, recoveryCodeHashes); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *AuthPostgres) DisableTOTP(userId int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	disableQuery := fmt.Sprintf(`UPDATE %s SET totp_enabled = false, totp_secret = NULL, totp_last_step = NULL
+	WHERE id = $1`, usersTable)
+	if _, err := tx.Exec(disableQuery, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := replaceRecoveryCodes(tx, userId, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userId int, codeHashes []string) error {
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", recoveryCodesTable)
+	if _, err := tx.Exec(deleteQuery, userId); err != nil {
+		return err
+	}
+
+	insertQuery := fmt.Sprintf("INSERT INTO %s (user_id, code_hash) VALUES ($1, $2)", recoveryCodesTable)
+	for _, codeHash := range codeHashes {
+		if _, err := tx.Exec(insertQuery, userId, codeHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseTOTPStep records the time step of an accepted code. It fails with
+// sql.ErrNoRows if the same or a later step was used already.
+func (r *AuthPostgres) UseTOTPStep(userId int, step int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET totp_last_step = $1
+	WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1)`, usersTable)
+	res, err := r.db.Exec(query, step, userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (r *AuthPostgres) UseRecoveryCode(userId int, codeHash string) error {
+	query := fmt.Sprintf(`UPDATE %s SET used_at = now()
+	WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, recoveryCodesTable)
+	res, err := r.db.Exec(query, userId, codeHash)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// UpdateProfile sets the fields that are not nil. A changed email has to be
+// verified again.
+func (r *AuthPostgres) UpdateProfile(userId int, input todo.UpdateProfileInput) error {
+	setValues := make([]string, 0)
+	args := make([]interface{}, 0)
+	argId := 1
+
+	if input.Name != nil {
+		setValues = append(setValues, fmt.Sprintf("name=$%d", argId))
+		args = append(args, *input.Name)
+		argId++
+	}
+
+	if input.Email != nil {
+		setValues = append(setValues, fmt.Sprintf("email=$%d", argId),
+			fmt.Sprintf("email_verified_at=CASE WHEN email IS NOT DISTINCT FROM $%d THEN email_verified_at END", argId))
+		args = append(args, *input.Email)
+		argId++
+	}
+
+	if input.TimeZone != nil {
+		setValues = append(setValues, fmt.Sprintf("time_zone=$%d", argId))
+		args = append(args, *input.TimeZone)
+		argId++
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d", usersTable, strings.Join(setValues, ", "), argId)
+	args = append(args, userId)
+
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// DeleteUser removes the user together with the workspaces nobody else is a
+// member of, including the personal one. Lists of those workspaces that were
+// shared directly with an owner or editor move to the personal workspace of
+// one of them first, owners before editors. Workspaces the user was the only
+// owner of are handed to the member that joined first. Everything else owned
+// by the user goes with the on delete cascade of the referencing tables.
+func (r *AuthPostgres) DeleteUser(userId int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	soleWorkspaces := fmt.Sprintf(`SELECT wm.workspace_id FROM %[1]s wm WHERE wm.user_id = $1
+	AND NOT EXISTS (SELECT 1 FROM %[1]s o WHERE o.workspace_id = wm.workspace_id AND o.user_id <> $1)`, workspaceMembersTable)
+
+	// the heir reaches the list through their personal workspace from now on,
+	// like the lists moved by the workspaces migration
+	handOverQuery := fmt.Sprintf(`WITH heirs AS (
+		SELECT DISTINCT ON (ul.list_id) ul.list_id, ul.user_id
+		FROM %[1]s ul
+		         JOIN %[2]s tl ON tl.id = ul.list_id
+		WHERE tl.workspace_id IN (%[4]s) AND ul.user_id <> $1 AND ul.role IN ('%[5]s', '%[6]s')
+		ORDER BY ul.list_id, ul.role = '%[5]s' DESC, ul.id
+	), moved AS (
+		UPDATE %[2]s tl SET workspace_id = w.id
+		FROM heirs h
+		         JOIN %[3]s w ON w.personal_user_id = h.user_id
+		WHERE tl.id = h.list_id
+		RETURNING tl.id, h.user_id
+	)
+	DELETE FROM %[1]s ul USING moved m WHERE ul.list_id = m.id AND ul.user_id = m.user_id`,
+		usersListsTable, todoListsTable, workspacesTable, soleWorkspaces, todo.ListRoleOwner, todo.ListRoleEditor)
+	if _, err := tx.Exec(handOverQuery, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := deleteWorkspaceItems(tx, soleWorkspaces, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	deleteWorkspacesQuery := fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", workspacesTable, soleWorkspaces)
+	if _, err := tx.Exec(deleteWorkspacesQuery, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	promoteQuery := fmt.Sprintf(`UPDATE %[1]s SET role = '%[2]s' WHERE id IN (
+	SELECT DISTINCT ON (o.workspace_id) o.id
+	FROM %[1]s o
+	         JOIN %[1]s wm ON wm.workspace_id = o.workspace_id AND wm.user_id = $1 AND wm.role = '%[2]s'
+	WHERE o.user_id <> $1
+	  AND NOT EXISTS (SELECT 1 FROM %[1]s x WHERE x.workspace_id = o.workspace_id AND x.user_id <> $1 AND x.role = '%[2]s')
+	ORDER BY o.workspace_id, o.id)`, workspaceMembersTable, todo.ListRoleOwner)
+	if _, err := tx.Exec(promoteQuery, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	deleteUserQuery := fmt.Sprintf("DELETE FROM %s WHERE id = $1", usersTable)
+	res, err := tx.Exec(deleteUserQuery, userId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+type CommentPostgres struct {
+	db *sqlx.DB
+}
+
+func NewCommentPostgres(db *sqlx.DB) *CommentPostgres {
+	return &CommentPostgres{db: db}
+}
+
+const commentColumns = "c.id, c.item_id, c.parent_id, c.user_id, u.username, c.body, c.created_at, c.edited_at, li.list_id"
+
+// commentsFrom leaves out the comments on items in the trash.
+var commentsFrom = fmt.Sprintf(`%s c
+         JOIN %s ti ON ti.id = c.item_id AND ti.deleted_at IS NULL
+         JOIN %s li ON li.item_id = c.item_id
+         LEFT JOIN %s u ON u.id = c.user_id`, itemCommentsTable, todoItemsTable, listsItemsTable, usersTable)
+
+// Create adds the comment and queues the notifications of the mentioned
+// members of the list.
+func (r *CommentPostgres) Create(comment todo.Comment, mentions []string) (int, error) {
+	var id int
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		query := fmt.Sprintf(`INSERT INTO %s (item_id, parent_id, user_id, body) VALUES ($1, $2, $3, $4)
+		RETURNING id`, itemCommentsTable)
+		err := tx.QueryRow(query, comment.ItemId, comment.ParentId, comment.UserId, comment.Body).Scan(&id)
+		if err != nil {
+			return err
+		}
+		return addMentions(tx, id, mentions)
+	})
+	return id, err
+}
+
+// GetById returns the comment if the user can read its item.
+func (r *CommentPostgres) GetById(userId, id int) (todo.Comment, error) {
+	var comment todo.Comment
+	query := fmt.Sprintf(`SELECT %s FROM %s
+	         JOIN %s ul ON ul.list_id = li.list_id
+	WHERE c.id = $1 AND ul.user_id = $2`, commentColumns, commentsFrom, listAccessView)
+	err := r.db.Get(&comment, query, id, userId)
+	return comment, err
+}
+
+// GetThreads returns up to limit threads of the item started after the
+// comment after, each with all of its replies.
+func (r *CommentPostgres) GetThreads(itemId, after, limit int) ([]todo.Comment, error) {
+	threads := make([]todo.Comment, 0)
+	query := fmt.Sprintf(`SELECT %s FROM %s
+	WHERE c.item_id = $1 AND c.parent_id IS NULL AND c.id > $2
+	ORDER BY c.id
+	LIMIT $3`, commentColumns, commentsFrom)
+	if err := r.db.Select(&threads, query, itemId, after, limit); err != nil {
+		return nil, err
+	}
+	if len(threads) == 0 {
+		return threads, nil
+	}
+
+	ids := make([]int, len(threads))
+	index := make(map[int]int, len(threads))
+	for i, thread := range threads {
+		ids[i] = thread.Id
+		index[thread.Id] = i
+	}
+	var replies []todo.Comment
+	query = fmt.Sprintf(`SELECT %s FROM %s
+	WHERE c.parent_id = ANY($1)
+	ORDER BY c.id`, commentColumns, commentsFrom)
+	if err := r.db.Select(&replies, query, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	for _, reply := range replies {
+		i := index[*reply.ParentId]
+		threads[i].Replies = append(threads[i].Replies, reply)
+	}
+	return threads, nil
+}
+
+// Update replaces the body of the comment and queues the notifications of
+// the members who are mentioned for the first time.
+func (r *CommentPostgres) Update(id int, body string, mentions []string) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		query := fmt.Sprintf("UPDATE %s SET body = $1, edited_at = now() WHERE id = $2", itemCommentsTable)
+		res, err := tx.Exec(query, body, id)
+		if err != nil {
+			return err
+		}
+		if err := checkAffected(res); err != nil {
+			return err
+		}
+		return addMentions(tx, id, mentions)
+	})
+}
+
+// Delete removes the comment together with its replies.
+func (r *CommentPostgres) Delete(id int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", itemCommentsTable)
+	res, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// addMentions records the users mentioned in a comment who can read its
+// item, leaving out the author and users mentioned before. The new mentions
+// are pending until the mention worker sends them.
+func addMentions(tx *sqlx.Tx, commentId int, usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+	query := fmt.Sprintf(`INSERT INTO %s (comment_id, user_id)
+	SELECT c.id, u.id FROM %s c
+	         JOIN %s li ON li.item_id = c.item_id
+	         JOIN %s ul ON ul.list_id = li.list_id
+	         JOIN %s u ON u.id = ul.user_id
+	WHERE c.id = $1 AND u.username = ANY($2) AND u.id IS DISTINCT FROM c.user_id
+	ON CONFLICT DO NOTHING`, commentMentionsTable, itemCommentsTable, listsItemsTable, listAccessView, usersTable)
+	_, err := tx.Exec(query, commentId, pq.Array(usernames))
+	return err
+}
+
+// ClaimMentions is Claim of the reminders for mention notifications: it
+// marks up to limit pending mentions as being sent by the caller for the
+// length of lease and returns them. Mentions whose lease ran out are claimed
+// again unless they used up maxAttempts, then they are marked failed. They
+// are only sent while the item is out of the trash and the mentioned user
+// can still reach it.
+func (r *CommentPostgres) ClaimMentions(limit, maxAttempts int, lease time.Duration) ([]todo.MentionDelivery, error) {
+	if err := failAbandoned(r.db, commentMentionsTable, maxAttempts); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`WITH due AS (
+		SELECT m.id FROM %[1]s m
+		         JOIN %[2]s c ON c.id = m.comment_id
+		         JOIN %[3]s ti ON ti.id = c.item_id
+		WHERE (m.status = '%[4]s' AND ti.deleted_at IS NULL AND coalesce(m.next_attempt_at, now()) <= now()
+			AND EXISTS (SELECT 1 FROM %[5]s li JOIN %[6]s ul ON ul.list_id = li.list_id
+				WHERE li.item_id = c.item_id AND ul.user_id = m.user_id))
+		   OR (m.status = '%[7]s' AND m.locked_until < now() AND m.attempts < $3)
+		ORDER BY m.id
+		LIMIT $1
+		FOR UPDATE OF m SKIP LOCKED
+	), claimed AS (
+		%[9]s
+	)
+	SELECT cm.id, cm.attempts, cm.user_id, u.username,
+		CASE WHEN u.email_verified_at IS NOT NULL THEN u.email END AS email,
+		cm.comment_id, a.username AS author, c.body, ti.id AS item_id, li.list_id, ti.title
+	FROM claimed cm
+	         JOIN %[8]s u ON u.id = cm.user_id
+	         JOIN %[2]s c ON c.id = cm.comment_id
+	         LEFT JOIN %[8]s a ON a.id = c.user_id
+	         JOIN %[3]s ti ON ti.id = c.item_id
+	         JOIN %[5]s li ON li.item_id = ti.id
+	ORDER BY cm.id`, commentMentionsTable, itemCommentsTable, todoItemsTable, todo.ReminderPending,
+		listsItemsTable, listAccessView, todo.ReminderSending, usersTable,
+		claimDue(commentMentionsTable, "m", "m.id, m.attempts, m.user_id, m.comment_id"))
+
+	deliveries := make([]todo.MentionDelivery, 0)
+	err := r.db.Select(&deliveries, query, limit, lease.Seconds(), maxAttempts)
+	return deliveries, err
+}
+
+func (r *CommentPostgres) MarkMentionSent(id int) error {
+	return markSent(r.db, commentMentionsTable, id)
+}
+
+// MarkMentionFailed records a failed attempt. The mention is tried again at
+// retryAt or, without one, given up on.
+func (r *CommentPostgres) MarkMentionFailed(id int, reason string, retryAt *time.Time) error {
+	return markFailed(r.db, commentMentionsTable, id, reason, retryAt)
+}
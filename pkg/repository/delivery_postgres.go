@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// Notifications sent by a background worker, reminders and mentions, are
+// rows of a table with the status, attempts, next_attempt_at, locked_until,
+// last_error and sent_at columns. A worker claims rows by setting them to
+// sending for the length of a lease, these helpers take care of the rest of
+// their life cycle.
+
+// failAbandoned marks the notifications of table as failed whose lease ran
+// out while they were sent for the last of maxAttempts times.
+func failAbandoned(db *sqlx.DB, table string, maxAttempts int) error {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, locked_until = NULL,
+		last_error = coalesce(last_error, 'the lease ran out while sending')
+	WHERE status = $2 AND locked_until < now() AND attempts >= $3`, table)
+	_, err := db.Exec(query, todo.ReminderFailed, todo.ReminderSending, maxAttempts)
+	return err
+}
+
+// claimDue is the part of a claim query that takes over the rows of table
+// selected by the due CTE. It sets them to sending for a lease of $2 seconds
+// and returns the columns of the rows named by returning, prefixed with
+// alias.
+func claimDue(table, alias, returning string) string {
+	return fmt.Sprintf(`UPDATE %[1]s %[2]s SET status = '%[3]s', attempts = %[2]s.attempts + 1,
+		locked_until = now() + $2 * interval '1 second'
+		FROM due WHERE %[2]s.id = due.id
+		RETURNING %[4]s`, table, alias, todo.ReminderSending, returning)
+}
+
+// markSent records that the notification was sent.
+func markSent(db *sqlx.DB, table string, id int) error {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, sent_at = now(), locked_until = NULL, last_error = NULL
+	WHERE id = $2 AND status = $3`, table)
+	_, err := db.Exec(query, todo.ReminderSent, id, todo.ReminderSending)
+	return err
+}
+
+// markFailed records a failed attempt. The notification is tried again at
+// retryAt or, without one, given up on.
+func markFailed(db *sqlx.DB, table string, id int, reason string, retryAt *time.Time) error {
+	status := todo.ReminderFailed
+	if retryAt != nil {
+		status = todo.ReminderPending
+	}
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, next_attempt_at = $2, last_error = $3, locked_until = NULL
+	WHERE id = $4 AND status = $5`, table)
+	_, err := db.Exec(query, status, retryAt, reason, id, todo.ReminderSending)
+	return err
+}
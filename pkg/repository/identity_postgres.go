@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+)
+
+type IdentityPostgres struct {
+	db *sqlx.DB
+}
+
+func NewIdentityPostgres(db *sqlx.DB) *IdentityPostgres {
+	return &IdentityPostgres{db: db}
+}
+
+func (r *IdentityPostgres) CreateLoginState(state todo.OIDCLoginState) error {
+	query := fmt.Sprintf(`INSERT INTO %s (state, provider, nonce, code_verifier, link_user_id, reauth, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`, oidcLoginStatesTable)
+	_, err := r.db.Exec(query, state.State, state.Provider, state.Nonce, state.CodeVerifier, state.LinkUserId,
+		state.Reauth, state.ExpiresAt)
+	return err
+}
+
+// UseLoginState deletes and returns a pending login, so every state value
+// can complete at most one callback.
+func (r *IdentityPostgres) UseLoginState(state, provider string) (todo.OIDCLoginState, error) {
+	var loginState todo.OIDCLoginState
+	query := fmt.Sprintf(`DELETE FROM %s WHERE state = $1 AND provider = $2 AND expires_at > now()
+	RETURNING state, provider, nonce, code_verifier, link_user_id, reauth, expires_at`, oidcLoginStatesTable)
+	if err := r.db.Get(&loginState, query, state, provider); err != nil {
+		return loginState, err
+	}
+
+	cleanupQuery := fmt.Sprintf("DELETE FROM %s WHERE expires_at < now()", oidcLoginStatesTable)
+	_, err := r.db.Exec(cleanupQuery)
+	return loginState, err
+}
+
+func (r *IdentityPostgres) GetByProviderSubject(provider, subject string) (todo.UserIdentity, error) {
+	var identity todo.UserIdentity
+	query := fmt.Sprintf(`SELECT id, user_id, provider, subject, email, created_at
+	FROM %s WHERE provider = $1 AND subject = $2`, userIdentitiesTable)
+	err := r.db.Get(&identity, query, provider, subject)
+	return identity, err
+}
+
+func (r *IdentityPostgres) GetAll(userId int) ([]todo.UserIdentity, error) {
+	var identities []todo.UserIdentity
+	query := fmt.Sprintf(`SELECT id, user_id, provider, subject, email, created_at
+	FROM %s WHERE user_id = $1 ORDER BY created_at`, userIdentitiesTable)
+	err := r.db.Select(&identities, query, userId)
+	return identities, err
+}
+
+func (r *IdentityPostgres) Create(identity todo.UserIdentity) error {
+	query := fmt.Sprintf("INSERT INTO %s (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)", userIdentitiesTable)
+	_, err := r.db.Exec(query, identity.UserId, identity.Provider, identity.Subject, identity.Email)
+	return err
+}
+
+// CreateUser provisions a user for an external identity. The user gets no
+// usable password, an email confirmed by the provider is stored as verified.
+func (r *IdentityPostgres) CreateUser(user todo.User, identity todo.UserIdentity) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	createUserQuery := fmt.Sprintf(`INSERT INTO %s (name, username, email, password_hash, email_verified_at)
+	VALUES ($1, $2, $3, '', CASE WHEN $3::varchar IS NULL THEN NULL ELSE now() END) RETURNING id`, usersTable)
+	row := tx.QueryRow(createUserQuery, user.Name, user.Username, user.Email)
+	if err := row.Scan(&id); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	createIdentityQuery := fmt.Sprintf("INSERT INTO %s (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)",
+		userIdentitiesTable)
+	_, err = tx.Exec(createIdentityQuery, id, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := createPersonalWorkspace(tx, id); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (r *IdentityPostgres) Delete(userId, id int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND user_id = $2", userIdentitiesTable)
+	res, err := r.db.Exec(query, id, userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+)
+
+type InvitationPostgres struct {
+	db *sqlx.DB
+}
+
+func NewInvitationPostgres(db *sqlx.DB) *InvitationPostgres {
+	return &InvitationPostgres{db: db}
+}
+
+// invitationSelect reads invitations aliased i, pending ones past their
+// expiry are reported as expired.
+var invitationSelect = fmt.Sprintf(`SELECT i.id, i.list_id, tl.title AS list_title, i.inviter_id, u.username AS inviter,
+       i.invitee_id, i.email, i.role,
+       CASE WHEN i.status = '%s' AND i.expires_at <= now() THEN '%s' ELSE i.status END AS status,
+       i.created_at, i.expires_at, i.responded_at
+FROM %s i
+         JOIN %s tl ON tl.id = i.list_id
+         JOIN %s u ON u.id = i.inviter_id`,
+	todo.InvitationPending, todo.InvitationExpired, listInvitationsTable, todoListsTable, usersTable)
+
+// openInvitation matches invitations that can still be answered.
+var openInvitation = fmt.Sprintf("status = '%s' AND expires_at > now()", todo.InvitationPending)
+
+func (r *InvitationPostgres) Create(invitation todo.Invitation) (int, error) {
+	var id int
+	query := fmt.Sprintf(`INSERT INTO %s (list_id, inviter_id, invitee_id, email, role, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`, listInvitationsTable)
+	row := r.db.QueryRow(query, invitation.ListId, invitation.InviterId, invitation.InviteeId, invitation.Email,
+		invitation.Role, invitation.ExpiresAt)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *InvitationPostgres) GetById(id int) (todo.Invitation, error) {
+	var invitation todo.Invitation
+	query := invitationSelect + " WHERE i.id = $1"
+	err := r.db.Get(&invitation, query, id)
+	return invitation, err
+}
+
+func (r *InvitationPostgres) GetAllByList(listId int) ([]todo.Invitation, error) {
+	var invitations []todo.Invitation
+	query := invitationSelect + " WHERE i.list_id = $1 ORDER BY i.id DESC"
+	err := r.db.Select(&invitations, query, listId)
+	return invitations, err
+}
+
+// GetPendingForUser returns the open invitations addressed to the user or,
+// if given, to their verified email address.
+func (r *InvitationPostgres) GetPendingForUser(userId int, email *string) ([]todo.Invitation, error) {
+	var invitations []todo.Invitation
+	query := invitationSelect + fmt.Sprintf(` WHERE i.status = '%s' AND i.expires_at > now()
+	AND (i.invitee_id = $1 OR (i.invitee_id IS NULL AND i.email = $2))
+	ORDER BY i.id DESC`, todo.InvitationPending)
+	err := r.db.Select(&invitations, query, userId, email)
+	return invitations, err
+}
+
+// Accept adds the user to the list with the invited role. It fails with
+// sql.ErrNoRows if the invitation is no longer open.
+func (r *InvitationPostgres) Accept(id, userId int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	var accepted struct {
+		ListId int    `db:"list_id"`
+		Role   string `db:"role"`
+	}
+	acceptQuery := fmt.Sprintf(`UPDATE %s SET status = '%s', invitee_id = $2, responded_at = now()
+	WHERE id = $1 AND %s RETURNING list_id, role`, listInvitationsTable, todo.InvitationAccepted, openInvitation)
+	if err := tx.Get(&accepted, acceptQuery, id, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// accepting while already being a member keeps the current role
+	memberQuery := fmt.Sprintf(`INSERT INTO %s (user_id, list_id, role) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, list_id) DO NOTHING`, usersListsTable)
+	if _, err := tx.Exec(memberQuery, userId, accepted.ListId, accepted.Role); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *InvitationPostgres) Decline(id, userId int) error {
+	query := fmt.Sprintf(`UPDATE %s SET status = '%s', invitee_id = $2, responded_at = now()
+	WHERE id = $1 AND %s`, listInvitationsTable, todo.InvitationDeclined, openInvitation)
+	res, err := r.db.Exec(query, id, userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (r *InvitationPostgres) Delete(listId, id int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE list_id = $1 AND id = $2", listInvitationsTable)
+	res, err := r.db.Exec(query, listId, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
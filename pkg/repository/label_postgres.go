@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type LabelPostgres struct {
+	db *sqlx.DB
+}
+
+func NewLabelPostgres(db *sqlx.DB) *LabelPostgres {
+	return &LabelPostgres{db: db}
+}
+
+// labels the user with id $1 can see and put on items, aliased l
+var visibleLabel = fmt.Sprintf("(l.user_id = $1 OR l.workspace_id IN (SELECT workspace_id FROM %s WHERE user_id = $1))",
+	workspaceMembersTable)
+
+// labels the user with id $1 can change, workspace labels need the editor
+// role in the workspace
+var editableLabel = fmt.Sprintf(`(l.user_id = $1 OR l.workspace_id IN (SELECT workspace_id FROM %s
+	WHERE user_id = $1 AND role IN ('owner', 'editor')))`, workspaceMembersTable)
+
+func (r *LabelPostgres) Create(userId int, input todo.CreateLabelInput) (int, error) {
+	var id int
+	var err error
+	if input.WorkspaceId == nil {
+		query := fmt.Sprintf("INSERT INTO %s (name, color, user_id) VALUES ($1, $2, $3) RETURNING id", labelsTable)
+		err = r.db.QueryRow(query, input.Name, input.Color, userId).Scan(&id)
+	} else {
+		query := fmt.Sprintf(`INSERT INTO %s (name, color, workspace_id)
+		SELECT $1, $2, $3 WHERE EXISTS (SELECT 1 FROM %s
+			WHERE workspace_id = $3 AND user_id = $4 AND role IN ('owner', 'editor'))
+		RETURNING id`, labelsTable, workspaceMembersTable)
+		err = r.db.QueryRow(query, input.Name, input.Color, *input.WorkspaceId, userId).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, workspaceAccessError(r.db, userId, *input.WorkspaceId)
+		}
+	}
+	if isUniqueViolation(err) {
+		return 0, ErrLabelExists
+	}
+	return id, err
+}
+
+func (r *LabelPostgres) GetAll(userId int) ([]todo.Label, error) {
+	labels := make([]todo.Label, 0)
+	query := fmt.Sprintf(`SELECT l.id, l.name, l.color, l.workspace_id FROM %s l WHERE %s
+	ORDER BY l.workspace_id NULLS FIRST, lower(l.name)`, labelsTable, visibleLabel)
+	err := r.db.Select(&labels, query, userId)
+	return labels, err
+}
+
+func (r *LabelPostgres) GetById(userId, id int) (todo.Label, error) {
+	var label todo.Label
+	query := fmt.Sprintf("SELECT l.id, l.name, l.color, l.workspace_id FROM %s l WHERE l.id = $2 AND %s",
+		labelsTable, visibleLabel)
+	err := r.db.Get(&label, query, userId, id)
+	return label, err
+}
+
+func (r *LabelPostgres) Update(userId, id int, input todo.UpdateLabelInput) error {
+	query := fmt.Sprintf(`UPDATE %s l SET name = coalesce($3, l.name), color = coalesce($4, l.color)
+	WHERE l.id = $2 AND %s`, labelsTable, editableLabel)
+	res, err := r.db.Exec(query, userId, id, input.Name, input.Color)
+	if isUniqueViolation(err) {
+		return ErrLabelExists
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		return r.accessError(userId, id)
+	}
+	return nil
+}
+
+// Delete removes the label from all items it is on.
+func (r *LabelPostgres) Delete(userId, id int) error {
+	query := fmt.Sprintf("DELETE FROM %s l WHERE l.id = $2 AND %s", labelsTable, editableLabel)
+	res, err := r.db.Exec(query, userId, id)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		return r.accessError(userId, id)
+	}
+	return nil
+}
+
+// AddToItem does not check access, putting a label on an item twice is not
+// an error.
+func (r *LabelPostgres) AddToItem(itemId, labelId int) error {
+	query := fmt.Sprintf("INSERT INTO %s (item_id, label_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", itemsLabelsTable)
+	_, err := r.db.Exec(query, itemId, labelId)
+	return err
+}
+
+func (r *LabelPostgres) RemoveFromItem(itemId, labelId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE item_id = $1 AND label_id = $2", itemsLabelsTable)
+	res, err := r.db.Exec(query, itemId, labelId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// accessError is listAccessError for labels.
+func (r *LabelPostgres) accessError(userId, id int) error {
+	if _, err := r.GetById(userId, id); err != nil {
+		return err
+	}
+	return ErrInsufficientRole
+}
+
+// loadItemLabels fills in the labels of the items the user can see.
+func loadItemLabels(db *sqlx.DB, userId int, items []todo.TodoItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	itemIds := make([]int64, len(items))
+	byId := make(map[int]*todo.TodoItem, len(items))
+	for i := range items {
+		itemIds[i] = int64(items[i].Id)
+		items[i].Labels = make([]todo.Label, 0)
+		byId[items[i].Id] = &items[i]
+	}
+
+	var rows []struct {
+		ItemId int `db:"item_id"`
+		todo.Label
+	}
+	query := fmt.Sprintf(`SELECT il.item_id, l.id, l.name, l.color, l.workspace_id
+	FROM %s il
+	         JOIN %s l ON l.id = il.label_id
+	WHERE il.item_id = ANY($2) AND %s
+	ORDER BY lower(l.name)`, itemsLabelsTable, labelsTable, visibleLabel)
+	if err := db.Select(&rows, query, userId, pq.Array(itemIds)); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		byId[row.ItemId].Labels = append(byId[row.ItemId].Labels, row.Label)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+)
+
+type ListMemberPostgres struct {
+	db *sqlx.DB
+}
+
+func NewListMemberPostgres(db *sqlx.DB) *ListMemberPostgres {
+	return &ListMemberPostgres{db: db}
+}
+
+// GetRole returns the effective role of the user, which may come from the
+// workspace of the list.
+func (r *ListMemberPostgres) GetRole(userId, listId int) (string, error) {
+	var role string
+	query := fmt.Sprintf("SELECT role FROM %s WHERE user_id = $1 AND list_id = $2", listAccessView)
+	err := r.db.Get(&role, query, userId, listId)
+	return role, err
+}
+
+func (r *ListMemberPostgres) GetAll(listId int) ([]todo.ListMember, error) {
+	var members []todo.ListMember
+	query := fmt.Sprintf(`SELECT u.id AS user_id, u.username, u.name, ul.role
+	FROM %s ul
+	         JOIN %s u ON u.id = ul.user_id
+	WHERE ul.list_id = $1
+	ORDER BY ul.id`, usersListsTable, usersTable)
+	err := r.db.Select(&members, query, listId)
+	return members, err
+}
+
+func (r *ListMemberPostgres) Add(listId, userId int, role string) error {
+	query := fmt.Sprintf("INSERT INTO %s (user_id, list_id, role) VALUES ($1, $2, $3)", usersListsTable)
+	_, err := r.db.Exec(query, userId, listId, role)
+	return err
+}
+
+func (r *ListMemberPostgres) UpdateRole(listId, userId int, role string) error {
+	query := fmt.Sprintf("UPDATE %s SET role = $1 WHERE list_id = $2 AND user_id = $3", usersListsTable)
+	res, err := r.db.Exec(query, role, listId, userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (r *ListMemberPostgres) Remove(listId, userId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE list_id = $1 AND user_id = $2", usersListsTable)
+	res, err := r.db.Exec(query, listId, userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// listAccessError explains why a statement guarded by a role condition
+// matched no rows: ErrInsufficientRole if the user is a member of the list,
+// sql.ErrNoRows if the list does not exist or is not shared with them.
+func listAccessError(db *sqlx.DB, userId, listId int) error {
+	var role string
+	query := fmt.Sprintf("SELECT role FROM %s WHERE user_id = $1 AND list_id = $2", listAccessView)
+	if err := db.Get(&role, query, userId, listId); err != nil {
+		return err
+	}
+	return ErrInsufficientRole
+}
+
+// itemAccessError is listAccessError for the list the item belongs to.
+func itemAccessError(db *sqlx.DB, userId, itemId int) error {
+	var listId int
+	query := fmt.Sprintf(`SELECT li.list_id FROM %s li JOIN %s ti ON ti.id = li.item_id
+	WHERE li.item_id = $1 AND ti.deleted_at IS NULL`, listsItemsTable, todoItemsTable)
+	if err := db.Get(&listId, query, itemId); err != nil {
+		return err
+	}
+	return listAccessError(db, userId, listId)
+}
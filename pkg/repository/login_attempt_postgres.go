@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type LoginAttemptPostgres struct {
+	db *sqlx.DB
+}
+
+func NewLoginAttemptPostgres(db *sqlx.DB) *LoginAttemptPostgres {
+	return &LoginAttemptPostgres{db: db}
+}
+
+func (r *LoginAttemptPostgres) GetLockedUntil(key string) (*time.Time, error) {
+	var lockedUntil sql.NullTime
+	query := fmt.Sprintf("SELECT locked_until FROM %s WHERE key = $1 AND locked_until > now()", loginAttemptsTable)
+	if err := r.db.Get(&lockedUntil, query, key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &lockedUntil.Time, nil
+}
+
+// RecordFailure counts a failed attempt for key and returns the number of
+// failures so far. Counting starts over when the previous failure is older
+// than window.
+func (r *LoginAttemptPostgres) RecordFailure(key string, window time.Duration) (int, error) {
+	var failures int
+	query := fmt.Sprintf(`INSERT INTO %[1]s (key, failures, last_failure_at) VALUES ($1, 1, now())
+	ON CONFLICT (key) DO UPDATE SET
+		failures = CASE WHEN %[1]s.last_failure_at < now() - make_interval(secs => $2) THEN 1
+		           ELSE %[1]s.failures + 1 END,
+		last_failure_at = now()
+	RETURNING failures`, loginAttemptsTable)
+	err := r.db.Get(&failures, query, key, window.Seconds())
+	return failures, err
+}
+
+func (r *LoginAttemptPostgres) Lock(key string, until time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET locked_until = $1 WHERE key = $2", loginAttemptsTable)
+	_, err := r.db.Exec(query, until, key)
+	return err
+}
+
+func (r *LoginAttemptPostgres) Reset(key string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE key = $1", loginAttemptsTable)
+	_, err := r.db.Exec(query, key)
+	return err
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+)
+
+type PersonalAccessTokenPostgres struct {
+	db *sqlx.DB
+}
+
+func NewPersonalAccessTokenPostgres(db *sqlx.DB) *PersonalAccessTokenPostgres {
+	return &PersonalAccessTokenPostgres{db: db}
+}
+
+func (r *PersonalAccessTokenPostgres) Create(token todo.PersonalAccessToken) (int, error) {
+	var id int
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, name, token_hash, scopes, expires_at)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id`, personalAccessTokensTable)
+	row := r.db.QueryRow(query, token.UserId, token.Name, token.TokenHash, token.Scopes, token.ExpiresAt)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *PersonalAccessTokenPostgres) GetAll(userId int) ([]todo.PersonalAccessToken, error) {
+	var tokens []todo.PersonalAccessToken
+	query := fmt.Sprintf(`
+	SELECT id, user_id, name, token_hash, scopes, expires_at, last_used_at, created_at
+	FROM %s
+	WHERE user_id = $1
+	ORDER BY created_at
+	`, personalAccessTokensTable)
+	err := r.db.Select(&tokens, query, userId)
+	return tokens, err
+}
+
+func (r *PersonalAccessTokenPostgres) GetByHash(tokenHash string) (todo.PersonalAccessToken, error) {
+	var token todo.PersonalAccessToken
+	query := fmt.Sprintf(`
+	SELECT id, user_id, name, token_hash, scopes, expires_at, last_used_at, created_at
+	FROM %s
+	WHERE token_hash = $1
+	`, personalAccessTokensTable)
+	err := r.db.Get(&token, query, tokenHash)
+	return token, err
+}
+
+// TouchLastUsed records a use of the token. The timestamp is only written
+// once a minute so busy scripts do not turn every request into a write.
+func (r *PersonalAccessTokenPostgres) TouchLastUsed(id int) error {
+	query := fmt.Sprintf(`UPDATE %s SET last_used_at = now()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`, personalAccessTokensTable)
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+func (r *PersonalAccessTokenPostgres) Delete(userId, id int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND user_id = $2", personalAccessTokensTable)
+	res, err := r.db.Exec(query, id, userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
@@ -1,8 +1,13 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/position"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 const (
@@ -11,6 +16,48 @@ const (
 	usersListsTable = "users_lists"
 	todoItemsTable  = "todo_items"
 	listsItemsTable = "lists_items"
+
+	listInvitationsTable = "list_invitations"
+	shareLinksTable      = "share_links"
+
+	labelsTable      = "labels"
+	itemsLabelsTable = "items_labels"
+
+	listPositionsTable = "list_positions"
+
+	itemCompletionsTable = "item_completions"
+	remindersTable       = "reminders"
+
+	itemCommentsTable    = "item_comments"
+	commentMentionsTable = "comment_mentions"
+	attachmentsTable     = "attachments"
+
+	workspacesTable       = "workspaces"
+	workspaceMembersTable = "workspace_members"
+	// listAccessView has the effective role of every user on every list they
+	// can reach, through the workspace of the list or a direct share. Use it
+	// instead of usersListsTable to check access.
+	listAccessView = "list_access"
+	// allListAccessView is listAccessView including the lists in the trash.
+	allListAccessView = "list_access_all"
+
+	// conditions on the list_access row of the requesting user, aliased ul
+	canEditList   = "ul.role IN ('owner', 'editor')"
+	canManageList = "ul.role = 'owner'"
+
+	refreshTokensTable   = "refresh_tokens"
+	revokedTokensTable   = "revoked_tokens"
+	revokedSessionsTable = "revoked_sessions"
+	userTokensTable      = "user_tokens"
+	recoveryCodesTable   = "recovery_codes"
+
+	personalAccessTokensTable = "personal_access_tokens"
+
+	userIdentitiesTable  = "user_identities"
+	oidcLoginStatesTable = "oidc_login_states"
+
+	loginAttemptsTable = "login_attempts"
+	auditLogTable      = "audit_log"
 )
 
 type Config struct {
@@ -36,3 +83,81 @@ func NewPostgresDB(cfg Config) (*sqlx.DB, error) {
 
 	return db, nil
 }
+
+// isUniqueViolation reports whether err comes from a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// movePosition returns the new position of the row id for the move. rows
+// selects the id and position of every row in the same order, with $1 bound
+// to scope.
+func movePosition(tx *sql.Tx, rows string, scope, id int, input todo.MoveInput) (string, error) {
+	anchor := func(anchorId int) (string, error) {
+		if anchorId == id {
+			return "", ErrInvalidAnchor
+		}
+		var key string
+		query := fmt.Sprintf("SELECT p.position FROM (%s) p WHERE p.id = $2", rows)
+		if err := tx.QueryRow(query, scope, anchorId).Scan(&key); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return "", ErrInvalidAnchor
+			}
+			return "", err
+		}
+		return key, nil
+	}
+	// neighbour finds the closest position matching condition, ignoring the
+	// row that is moved
+	neighbour := func(aggregate, condition string, args ...interface{}) (string, error) {
+		var key sql.NullString
+		query := fmt.Sprintf("SELECT %s(p.position) FROM (%s) p WHERE p.id <> $2 AND %s", aggregate, rows, condition)
+		err := tx.QueryRow(query, append([]interface{}{scope, id}, args...)...).Scan(&key)
+		return key.String, err
+	}
+
+	var before, after string
+	var err error
+	switch {
+	case input.AfterId != nil && input.BeforeId != nil:
+		if before, err = anchor(*input.AfterId); err != nil {
+			return "", err
+		}
+		if after, err = anchor(*input.BeforeId); err != nil {
+			return "", err
+		}
+		if before >= after {
+			return "", ErrInvalidAnchor
+		}
+	case input.AfterId != nil:
+		if before, err = anchor(*input.AfterId); err != nil {
+			return "", err
+		}
+		if after, err = neighbour("min", "p.position > $3", before); err != nil {
+			return "", err
+		}
+	case input.BeforeId != nil:
+		if after, err = anchor(*input.BeforeId); err != nil {
+			return "", err
+		}
+		if before, err = neighbour("max", "p.position < $3", after); err != nil {
+			return "", err
+		}
+	default:
+		if before, err = neighbour("max", "TRUE"); err != nil {
+			return "", err
+		}
+	}
+	return position.Between(before, after)
+}
+
+// lastPosition returns a position after every row selected by rows.
+func lastPosition(tx *sql.Tx, rows string, scope int) (string, error) {
+	var last sql.NullString
+	query := fmt.Sprintf("SELECT max(p.position) FROM (%s) p", rows)
+	if err := tx.QueryRow(query, scope).Scan(&last); err != nil {
+		return "", err
+	}
+	return position.Between(last.String, "")
+}
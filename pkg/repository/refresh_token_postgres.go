@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+)
+
+type RefreshTokenPostgres struct {
+	db *sqlx.DB
+}
+
+func NewRefreshTokenPostgres(db *sqlx.DB) *RefreshTokenPostgres {
+	return &RefreshTokenPostgres{db: db}
+}
+
+func (r *RefreshTokenPostgres) Create(token todo.RefreshToken) error {
+	query := fmt.Sprintf("INSERT INTO %s (user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)",
+		refreshTokensTable)
+	_, err := r.db.Exec(query, token.UserId, token.FamilyId, token.TokenHash, token.ExpiresAt)
+	return err
+}
+
+func (r *RefreshTokenPostgres) GetByHash(tokenHash string) (todo.RefreshToken, error) {
+	var token todo.RefreshToken
+	query := fmt.Sprintf(`
+	SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, created_at
+	FROM %s
+	WHERE token_hash = $1
+	`, refreshTokensTable)
+	err := r.db.Get(&token, query, tokenHash)
+	return token, err
+}
+
+// Rotate revokes the token with oldId and stores its successor in one
+// transaction. If oldId was already revoked, ErrRefreshTokenRevoked is
+// returned and nothing is stored, so a token can only ever be rotated once.
+func (r *RefreshTokenPostgres) Rotate(oldId int, newToken todo.RefreshToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	revokeQuery := fmt.Sprintf("UPDATE %s SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL", refreshTokensTable)
+	res, err := tx.Exec(revokeQuery, oldId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if affected == 0 {
+		tx.Rollback()
+		return ErrRefreshTokenRevoked
+	}
+
+	createQuery := fmt.Sprintf("INSERT INTO %s (user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)",
+		refreshTokensTable)
+	_, err = tx.Exec(createQuery, newToken.UserId, newToken.FamilyId, newToken.TokenHash, newToken.ExpiresAt)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *RefreshTokenPostgres) RevokeFamily(familyId string) error {
+	query := fmt.Sprintf("UPDATE %s SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL", refreshTokensTable)
+	_, err := r.db.Exec(query, familyId)
+	return err
+}
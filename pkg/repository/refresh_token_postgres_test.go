@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Olmosbek510/todo-app"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenPostgresRotate(t *testing.T) {
+	successor := todo.RefreshToken{UserId: testUserId, FamilyId: "family", TokenHash: "hash", ExpiresAt: time.Now()}
+
+	tests := []struct {
+		name    string
+		revoked int64
+		wantErr error
+	}{
+		{name: "open token", revoked: 1},
+		{name: "replayed token", revoked: 0, wantErr: ErrRefreshTokenRevoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = now\(\) WHERE id = \$1 AND revoked_at IS NULL`).
+				WithArgs(3).
+				WillReturnResult(sqlmock.NewResult(0, tt.revoked))
+			if tt.wantErr != nil {
+				// the successor is never stored
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec(`INSERT INTO refresh_tokens \(user_id, family_id, token_hash, expires_at\)`).
+					WithArgs(successor.UserId, successor.FamilyId, successor.TokenHash, successor.ExpiresAt).
+					WillReturnResult(sqlmock.NewResult(4, 1))
+				mock.ExpectCommit()
+			}
+
+			err := NewRefreshTokenPostgres(db).Rotate(3, successor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rotate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
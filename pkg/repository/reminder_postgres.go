@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type ReminderPostgres struct {
+	db *sqlx.DB
+}
+
+func NewReminderPostgres(db *sqlx.DB) *ReminderPostgres {
+	return &ReminderPostgres{db: db}
+}
+
+const reminderColumns = "r.id, r.item_id, r.remind_at, r.offset_minutes, r.channel, r.status, r.attempts, r.last_error, " +
+	"r.sent_at, r.created_at"
+
+// reminderFireTime is when the reminder r of the item ti is due, null for
+// offsets on items without a due date.
+const reminderFireTime = "coalesce(r.remind_at, ti.due_at - r.offset_minutes * interval '1 minute')"
+
+// Create adds a reminder for the user on an item they can read.
+func (r *ReminderPostgres) Create(userId, itemId int, input todo.CreateReminderInput) (int, error) {
+	var id int
+	query := fmt.Sprintf(`INSERT INTO %s (item_id, user_id, remind_at, offset_minutes, channel)
+	SELECT li.item_id, ul.user_id, $3, $4, $5 FROM %s li
+	         JOIN %s ul ON ul.list_id = li.list_id
+	WHERE li.item_id = $1 AND ul.user_id = $2
+	RETURNING id`, remindersTable, listsItemsTable, listAccessView)
+	err := r.db.QueryRow(query, itemId, userId, input.RemindAt, input.OffsetMinutes, input.Channel).Scan(&id)
+	return id, err
+}
+
+// GetAllByItem returns the reminders of the user on the item.
+func (r *ReminderPostgres) GetAllByItem(userId, itemId int) ([]todo.Reminder, error) {
+	reminders := make([]todo.Reminder, 0)
+	query := fmt.Sprintf(`SELECT %s FROM %s r
+	         JOIN %s li ON li.item_id = r.item_id
+	         JOIN %s ul ON ul.list_id = li.list_id AND ul.user_id = r.user_id
+	WHERE r.item_id = $1 AND r.user_id = $2
+	ORDER BY r.id`, reminderColumns, remindersTable, listsItemsTable, listAccessView)
+	err := r.db.Select(&reminders, query, itemId, userId)
+	return reminders, err
+}
+
+func (r *ReminderPostgres) Delete(userId, id int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND user_id = $2", remindersTable)
+	res, err := r.db.Exec(query, id, userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// Claim marks up to limit due reminders as being sent by the caller for the
+// length of lease and returns them. Rows claimed by other instances are
+// skipped, reminders whose lease ran out are claimed again unless they used
+// up maxAttempts, then they are marked failed. Reminders are only sent while
+// the item is open, out of the trash and the user can still reach it.
+func (r *ReminderPostgres) Claim(limit, maxAttempts int, lease time.Duration) ([]todo.ReminderDelivery, error) {
+	if err := failAbandoned(r.db, remindersTable, maxAttempts); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`WITH due AS (
+		SELECT r.id FROM %[1]s r
+		         JOIN %[2]s ti ON ti.id = r.item_id
+		WHERE (r.status = '%[3]s' AND NOT ti.done AND ti.deleted_at IS NULL AND coalesce(r.next_attempt_at, %[4]s) <= now()
+			AND EXISTS (SELECT 1 FROM %[5]s li JOIN %[6]s ul ON ul.list_id = li.list_id
+				WHERE li.item_id = r.item_id AND ul.user_id = r.user_id))
+		   OR (r.status = '%[7]s' AND r.locked_until < now() AND r.attempts < $3)
+		ORDER BY r.id
+		LIMIT $1
+		FOR UPDATE OF r SKIP LOCKED
+	), claimed AS (
+		%[9]s
+	)
+	SELECT c.id, c.channel, c.attempts, c.user_id, u.username,
+		CASE WHEN u.email_verified_at IS NOT NULL THEN u.email END AS email, u.time_zone,
+		ti.id AS item_id, li.list_id, ti.title, ti.due_at, ti.all_day
+	FROM claimed c
+	         JOIN %[8]s u ON u.id = c.user_id
+	         JOIN %[2]s ti ON ti.id = c.item_id
+	         JOIN %[5]s li ON li.item_id = ti.id
+	ORDER BY c.id`, remindersTable, todoItemsTable, todo.ReminderPending, reminderFireTime, listsItemsTable,
+		listAccessView, todo.ReminderSending, usersTable,
+		claimDue(remindersTable, "r", "r.id, r.channel, r.attempts, r.user_id, r.item_id"))
+
+	deliveries := make([]todo.ReminderDelivery, 0)
+	err := r.db.Select(&deliveries, query, limit, lease.Seconds(), maxAttempts)
+	return deliveries, err
+}
+
+func (r *ReminderPostgres) MarkSent(id int) error {
+	return markSent(r.db, remindersTable, id)
+}
+
+// MarkFailed records a failed attempt. The reminder is tried again at
+// retryAt or, without one, given up on.
+func (r *ReminderPostgres) MarkFailed(id int, reason string, retryAt *time.Time) error {
+	return markFailed(r.db, remindersTable, id, reason, retryAt)
+}
+
+// rearmReminders sends the reminders relative to the due date of the item
+// again after the date moved, as long as they are still ahead.
+func rearmReminders(tx *sql.Tx, itemId int) error {
+	query := fmt.Sprintf(`UPDATE %s r SET status = $2, attempts = 0, next_attempt_at = NULL, last_error = NULL, sent_at = NULL
+	FROM %s ti
+	WHERE ti.id = r.item_id AND r.item_id = $1 AND r.offset_minutes IS NOT NULL AND r.status <> $3
+		AND %s > now()`, remindersTable, todoItemsTable, reminderFireTime)
+	_, err := tx.Exec(query, itemId, todo.ReminderPending, todo.ReminderSending)
+	return err
+}
@@ -1,43 +1,263 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"github.com/Olmosbek510/todo-app"
 	"github.com/jmoiron/sqlx"
+	"time"
 )
 
+var (
+	ErrRefreshTokenRevoked = errors.New("refresh token has already been revoked")
+	// ErrInsufficientRole is returned when the user can see a list but their
+	// role does not allow the change.
+	ErrInsufficientRole = errors.New("your role on this list does not allow this")
+	ErrLastOwner        = errors.New("a workspace needs at least one owner")
+	ErrLabelExists      = errors.New("a label with this name already exists")
+	ErrInvalidParent    = errors.New("the parent must be an item of the same list")
+	ErrItemCycle        = errors.New("an item cannot become a subtask of itself or of its own subtasks")
+	ErrMaxDepth         = errors.New("subtasks are nested too deeply")
+	ErrInvalidAnchor    = errors.New("after_id and before_id must be other entries of the same order, after_id first")
+	ErrQuotaExceeded    = errors.New("the upload would exceed your storage quota")
+)
+
+// checkAffected turns a statement that matched no rows into sql.ErrNoRows,
+// the same error a lookup of a missing row returns.
+func checkAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 type Authorization interface {
 	CreateUser(user todo.User) (int, error)
-	GetUser(username, password string) (todo.User, error)
+	GetUser(username string) (todo.User, error)
+	GetUserById(id int) (todo.User, error)
+	GetUserByEmail(email string) (todo.User, error)
+	UpdatePasswordHash(userId int, passwordHash string) error
+	MarkEmailVerified(userId int, email string) error
+	SetTOTPSecret(userId int, secret string) error
+	EnableTOTP(userId int, lastStep int64, recoveryCodeHashes []string) error
+	DisableTOTP(userId int) error
+	UseTOTPStep(userId int, step int64) error
+	UseRecoveryCode(userId int, codeHash string) error
+	UpdateProfile(userId int, input todo.UpdateProfileInput) error
+	DeleteUser(userId int) error
+}
+
+type RefreshToken interface {
+	Create(token todo.RefreshToken) error
+	GetByHash(tokenHash string) (todo.RefreshToken, error)
+	Rotate(oldId int, newToken todo.RefreshToken) error
+	RevokeFamily(familyId string) error
+}
+
+type Revocation interface {
+	RevokeToken(jti string, userId int, expiresAt time.Time) error
+	IsTokenRevoked(jti string) (bool, error)
+	RevokeAllTokens(userId int, accessExpiresAt time.Time) (time.Time, []string, error)
+	GetTokensValidAfter(userId int) (*time.Time, error)
+	IsSessionRevoked(familyId string) (bool, error)
+}
+
+type UserToken interface {
+	Create(jti string, userId int, purpose string, expiresAt time.Time) error
+	Use(jti string, purpose string) (int, error)
+	IsActive(jti string, purpose string) (bool, error)
+	InvalidateAll(userId int, purpose string) error
+	RecordFailure(jti string) (int, error)
+}
+
+type Identity interface {
+	CreateLoginState(state todo.OIDCLoginState) error
+	UseLoginState(state, provider string) (todo.OIDCLoginState, error)
+	GetByProviderSubject(provider, subject string) (todo.UserIdentity, error)
+	GetAll(userId int) ([]todo.UserIdentity, error)
+	Create(identity todo.UserIdentity) error
+	CreateUser(user todo.User, identity todo.UserIdentity) (int, error)
+	Delete(userId, id int) error
+}
+
+type LoginAttempt interface {
+	GetLockedUntil(key string) (*time.Time, error)
+	RecordFailure(key string, window time.Duration) (int, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+type Audit interface {
+	Record(entry todo.AuditEntry) error
+}
+
+type PersonalAccessToken interface {
+	Create(token todo.PersonalAccessToken) (int, error)
+	GetAll(userId int) ([]todo.PersonalAccessToken, error)
+	GetByHash(tokenHash string) (todo.PersonalAccessToken, error)
+	TouchLastUsed(id int) error
+	Delete(userId, id int) error
 }
 
 type (
 	TodoList interface {
 		Create(id int, list todo.TodoList) (int, error)
 		GetAll(id int) ([]todo.TodoList, error)
+		GetAllInWorkspace(userId, workspaceId int) ([]todo.TodoList, error)
 		GetById(userId, listId int) (todo.TodoList, error)
 		DeleteById(userId, listId int) error
 		Update(userId, listId int, newListBody todo.UpdateListInput) error
+		Move(userId, listId int, input todo.MoveInput) error
 	}
 )
 
+type Workspace interface {
+	Create(userId int, name string) (int, error)
+	GetAll(userId int) ([]todo.Workspace, error)
+	GetById(userId, id int) (todo.Workspace, error)
+	GetPersonalId(userId int) (int, error)
+	Update(id int, name string) error
+	Delete(id int) error
+	GetMembers(id int) ([]todo.WorkspaceMember, error)
+	AddMember(id, userId int, role string) error
+	UpdateMemberRole(id, userId int, role string) error
+	RemoveMember(id, userId int) error
+}
+
+type ListMember interface {
+	GetRole(userId, listId int) (string, error)
+	GetAll(listId int) ([]todo.ListMember, error)
+	Add(listId, userId int, role string) error
+	UpdateRole(listId, userId int, role string) error
+	Remove(listId, userId int) error
+}
+
+type Invitation interface {
+	Create(invitation todo.Invitation) (int, error)
+	GetById(id int) (todo.Invitation, error)
+	GetAllByList(listId int) ([]todo.Invitation, error)
+	GetPendingForUser(userId int, email *string) ([]todo.Invitation, error)
+	Accept(id, userId int) error
+	Decline(id, userId int) error
+	Delete(listId, id int) error
+}
+
+type ShareLink interface {
+	Create(link todo.ShareLink) (int, error)
+	GetAll(listId int) ([]todo.ShareLink, error)
+	GetByHash(tokenHash string) (todo.ShareLink, error)
+	Delete(listId, id int) error
+	GetSharedList(listId int) (todo.SharedList, error)
+}
+
+type Label interface {
+	Create(userId int, input todo.CreateLabelInput) (int, error)
+	GetAll(userId int) ([]todo.Label, error)
+	GetById(userId, id int) (todo.Label, error)
+	Update(userId, id int, input todo.UpdateLabelInput) error
+	Delete(userId, id int) error
+	AddToItem(itemId, labelId int) error
+	RemoveFromItem(itemId, labelId int) error
+}
+
 type TodoItem interface {
-	Create(listId int, todoItem todo.TodoItem) (int, error)
-	GetAll(userId, lisId int) ([]todo.TodoItem, error)
+	Create(userId, listId int, todoItem todo.TodoItem, maxDepth int) (int, error)
+	GetAll(userId, lisId int, filter todo.ItemFilter) ([]todo.TodoItem, error)
 	GetById(userId, itemId int) (todo.TodoItem, error)
 	Delete(userId, itemId int) error
-	Update(userId int, itemId int, itemInput todo.UpdateItemInput) error
+	Apply(userId int, op todo.ItemOperation, maxDepth int) error
+	Move(userId, itemId int, input todo.MoveItemInput) error
+	Copy(userId, itemId int, input todo.MoveItemInput) (int, error)
+	Bulk(userId int, ops []todo.ItemOperation, atomic bool, maxDepth int) ([]error, error)
+	GetDue(userId int, due string) ([]todo.TodoItem, error)
+	GetCompletions(userId, itemId int) ([]todo.ItemCompletion, error)
+}
+
+type Reminder interface {
+	Create(userId, itemId int, input todo.CreateReminderInput) (int, error)
+	GetAllByItem(userId, itemId int) ([]todo.Reminder, error)
+	Delete(userId, id int) error
+	Claim(limit, maxAttempts int, lease time.Duration) ([]todo.ReminderDelivery, error)
+	MarkSent(id int) error
+	MarkFailed(id int, reason string, retryAt *time.Time) error
+}
+
+type Comment interface {
+	Create(comment todo.Comment, mentions []string) (int, error)
+	GetById(userId, id int) (todo.Comment, error)
+	GetThreads(itemId, after, limit int) ([]todo.Comment, error)
+	Update(id int, body string, mentions []string) error
+	Delete(id int) error
+	ClaimMentions(limit, maxAttempts int, lease time.Duration) ([]todo.MentionDelivery, error)
+	MarkMentionSent(id int) error
+	MarkMentionFailed(id int, reason string, retryAt *time.Time) error
+}
+
+type Attachment interface {
+	Create(attachment todo.Attachment, quota int64) (int, error)
+	GetById(userId, id int) (todo.Attachment, error)
+	GetStored(id int) (todo.Attachment, error)
+	GetAllByItem(itemId int) ([]todo.Attachment, error)
+	Delete(id int) error
+	GetUsage(userId int) (int64, error)
+	GetDetached(limit int) ([]todo.Attachment, error)
+}
+
+type Trash interface {
+	GetAll(userId int) (todo.Trash, error)
+	RestoreList(userId, listId int) error
+	RestoreItem(userId, itemId int) error
+	DeleteList(userId, listId int) error
+	DeleteItem(userId, itemId int) error
+	Purge(before time.Time) error
 }
 
 type Repository struct {
 	Authorization
+	RefreshToken
+	Revocation
+	UserToken
+	Identity
+	LoginAttempt
+	Audit
+	PersonalAccessToken
+	Workspace
 	TodoList
+	ListMember
+	Invitation
+	ShareLink
 	TodoItem
+	Label
+	Reminder
+	Comment
+	Attachment
+	Trash
 }
 
 func NewRepository(db *sqlx.DB) *Repository {
 	return &Repository{
-		Authorization: NewAuthPostgres(db),
-		TodoList:      NewTodoListPostgres(db),
-		TodoItem:      NewTodoItemPostgres(db),
+		Authorization:       NewAuthPostgres(db),
+		RefreshToken:        NewRefreshTokenPostgres(db),
+		Revocation:          NewRevocationPostgres(db),
+		UserToken:           NewUserTokenPostgres(db),
+		Identity:            NewIdentityPostgres(db),
+		LoginAttempt:        NewLoginAttemptPostgres(db),
+		Audit:               NewAuditPostgres(db),
+		PersonalAccessToken: NewPersonalAccessTokenPostgres(db),
+		Workspace:           NewWorkspacePostgres(db),
+		TodoList:            NewTodoListPostgres(db),
+		ListMember:          NewListMemberPostgres(db),
+		Invitation:          NewInvitationPostgres(db),
+		ShareLink:           NewShareLinkPostgres(db),
+		TodoItem:            NewTodoItemPostgres(db),
+		Label:               NewLabelPostgres(db),
+		Reminder:            NewReminderPostgres(db),
+		Comment:             NewCommentPostgres(db),
+		Attachment:          NewAttachmentPostgres(db),
+		Trash:               NewTrashPostgres(db),
 	}
 }
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type RevocationPostgres struct {
+	db *sqlx.DB
+}
+
+func NewRevocationPostgres(db *sqlx.DB) *RevocationPostgres {
+	return &RevocationPostgres{db: db}
+}
+
+func (r *RevocationPostgres) RevokeToken(jti string, userId int, expiresAt time.Time) error {
+	query := fmt.Sprintf(`INSERT INTO %s (jti, user_id, expires_at) VALUES ($1, $2, $3)
+	ON CONFLICT (jti) DO NOTHING`, revokedTokensTable)
+	if _, err := r.db.Exec(query, jti, userId, expiresAt); err != nil {
+		return err
+	}
+
+	// rows of tokens that expired on their own are no longer needed
+	cleanupQuery := fmt.Sprintf("DELETE FROM %s WHERE expires_at < now()", revokedTokensTable)
+	_, err := r.db.Exec(cleanupQuery)
+	return err
+}
+
+func (r *RevocationPostgres) IsTokenRevoked(jti string) (bool, error) {
+	var revoked bool
+	query := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE jti = $1)", revokedTokensTable)
+	err := r.db.Get(&revoked, query, jti)
+	return revoked, err
+}
+
+// RevokeAllTokens invalidates every access token issued to the user so far
+// and revokes all of their refresh tokens. The token families still open are
+// recorded as revoked sessions until accessExpiresAt, by when the access
+// tokens issued for them have expired. It returns the new cut-off time and
+// the revoked families.
+func (r *RevocationPostgres) RevokeAllTokens(userId int, accessExpiresAt time.Time) (time.Time, []string, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	var validAfter time.Time
+	usersQuery := fmt.Sprintf("UPDATE %s SET tokens_valid_after = now() WHERE id = $1 RETURNING tokens_valid_after", usersTable)
+	if err := tx.Get(&validAfter, usersQuery, userId); err != nil {
+		tx.Rollback()
+		return time.Time{}, nil, err
+	}
+
+	var families []string
+	sessionsQuery := fmt.Sprintf(`INSERT INTO %s (family_id, user_id, expires_at)
+	SELECT DISTINCT family_id, user_id, $2 FROM %s WHERE user_id = $1 AND revoked_at IS NULL
+	ON CONFLICT (family_id) DO UPDATE SET expires_at = excluded.expires_at
+	RETURNING family_id`, revokedSessionsTable, refreshTokensTable)
+	if err := tx.Select(&families, sessionsQuery, userId, accessExpiresAt); err != nil {
+		tx.Rollback()
+		return time.Time{}, nil, err
+	}
+
+	refreshQuery := fmt.Sprintf("UPDATE %s SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL", refreshTokensTable)
+	if _, err := tx.Exec(refreshQuery, userId); err != nil {
+		tx.Rollback()
+		return time.Time{}, nil, err
+	}
+
+	cleanupQuery := fmt.Sprintf("DELETE FROM %s WHERE expires_at < now()", revokedSessionsTable)
+	if _, err := tx.Exec(cleanupQuery); err != nil {
+		tx.Rollback()
+		return time.Time{}, nil, err
+	}
+
+	return validAfter, families, tx.Commit()
+}
+
+func (r *RevocationPostgres) GetTokensValidAfter(userId int) (*time.Time, error) {
+	var validAfter sql.NullTime
+	query := fmt.Sprintf("SELECT tokens_valid_after FROM %s WHERE id = $1", usersTable)
+	if err := r.db.Get(&validAfter, query, userId); err != nil {
+		return nil, err
+	}
+	if !validAfter.Valid {
+		return nil, nil
+	}
+	return &validAfter.Time, nil
+}
+
+func (r *RevocationPostgres) IsSessionRevoked(familyId string) (bool, error) {
+	var revoked bool
+	query := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE family_id = $1)", revokedSessionsTable)
+	err := r.db.Get(&revoked, query, familyId)
+	return revoked, err
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRevocationPostgresRevokeAllTokens(t *testing.T) {
+	db, mock := newMockDB(t)
+	validAfter := time.Now()
+	accessExpiresAt := validAfter.Add(15 * time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE users SET tokens_valid_after = now\(\) WHERE id = \$1 RETURNING tokens_valid_after`).
+		WithArgs(testUserId).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens_valid_after"}).AddRow(validAfter))
+	// the open sessions are revoked before their refresh tokens are
+	mock.ExpectQuery(`(?s)INSERT INTO revoked_sessions \(family_id, user_id, expires_at\)\s+SELECT DISTINCT family_id, user_id, \$2 FROM refresh_tokens WHERE user_id = \$1 AND revoked_at IS NULL`).
+		WithArgs(testUserId, accessExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"family_id"}).AddRow("laptop").AddRow("phone"))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = now\(\) WHERE user_id = \$1 AND revoked_at IS NULL`).
+		WithArgs(testUserId).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`DELETE FROM revoked_sessions WHERE expires_at < now\(\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	gotValidAfter, families, err := NewRevocationPostgres(db).RevokeAllTokens(testUserId, accessExpiresAt)
+	if err != nil {
+		t.Fatalf("RevokeAllTokens() error = %v", err)
+	}
+	if !gotValidAfter.Equal(validAfter) {
+		t.Errorf("RevokeAllTokens() valid after = %v, want %v", gotValidAfter, validAfter)
+	}
+	if want := []string{"laptop", "phone"}; !reflect.DeepEqual(families, want) {
+		t.Errorf("RevokeAllTokens() families = %v, want %v", families, want)
+	}
+}
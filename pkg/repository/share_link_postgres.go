@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+)
+
+type ShareLinkPostgres struct {
+	db *sqlx.DB
+}
+
+func NewShareLinkPostgres(db *sqlx.DB) *ShareLinkPostgres {
+	return &ShareLinkPostgres{db: db}
+}
+
+const shareLinkColumns = "id, list_id, created_by, token_hash, password_hash, password_hash IS NOT NULL AS has_password, expires_at, created_at"
+
+func (r *ShareLinkPostgres) Create(link todo.ShareLink) (int, error) {
+	var id int
+	query := fmt.Sprintf(`INSERT INTO %s (list_id, created_by, token_hash, password_hash, expires_at)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id`, shareLinksTable)
+	row := r.db.QueryRow(query, link.ListId, link.CreatedBy, link.TokenHash, link.PasswordHash, link.ExpiresAt)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *ShareLinkPostgres) GetAll(listId int) ([]todo.ShareLink, error) {
+	var links []todo.ShareLink
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE list_id = $1 ORDER BY created_at", shareLinkColumns, shareLinksTable)
+	err := r.db.Select(&links, query, listId)
+	return links, err
+}
+
+// GetByHash returns a link that has not expired, to a list that is not in
+// the trash.
+func (r *ShareLinkPostgres) GetByHash(tokenHash string) (todo.ShareLink, error) {
+	var link todo.ShareLink
+	query := fmt.Sprintf(`SELECT %s FROM %s
+	WHERE token_hash = $1 AND (expires_at IS NULL OR expires_at > now())
+	  AND list_id IN (SELECT id FROM %s WHERE deleted_at IS NULL)`, shareLinkColumns, shareLinksTable, todoListsTable)
+	err := r.db.Get(&link, query, tokenHash)
+	return link, err
+}
+
+func (r *ShareLinkPostgres) Delete(listId, id int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE list_id = $1 AND id = $2", shareLinksTable)
+	res, err := r.db.Exec(query, listId, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// GetSharedList reads a list without checking who is asking, the caller has
+// to have verified a share link for it. Lists and items in the trash are
+// not shared. Subtasks are nested under their parents.
+func (r *ShareLinkPostgres) GetSharedList(listId int) (todo.SharedList, error) {
+	list := todo.SharedList{Items: make([]todo.SharedItem, 0)}
+	listQuery := fmt.Sprintf("SELECT title, coalesce(description, '') FROM %s WHERE id = $1 AND deleted_at IS NULL",
+		todoListsTable)
+	if err := r.db.QueryRow(listQuery, listId).Scan(&list.Title, &list.Description); err != nil {
+		return list, err
+	}
+
+	itemsQuery := fmt.Sprintf(`SELECT %s
+	FROM %s ti
+	         JOIN %s li ON li.item_id = ti.id
+	WHERE li.list_id = $1 AND ti.deleted_at IS NULL
+	ORDER BY ti.position, ti.id`, itemColumns, todoItemsTable, listsItemsTable)
+	var items []todo.TodoItem
+	if err := r.db.Select(&items, itemsQuery, listId); err != nil {
+		return list, err
+	}
+	list.Items = todo.NewSharedItems(todo.ItemTree(items))
+	return list, nil
+}
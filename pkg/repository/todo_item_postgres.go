@@ -1,10 +1,15 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/position"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
+	"sort"
 	"strings"
 )
 
@@ -12,7 +17,61 @@ type TodoItemPostgres struct {
 	db *sqlx.DB
 }
 
-func (t *TodoItemPostgres) Update(userId int, itemId int, input todo.UpdateItemInput) error {
+const itemColumns = "ti.id, ti.title, ti.description, ti.done, li.list_id, ti.parent_id, ti.start_at, ti.due_at, ti.all_day, ti.priority, " +
+	"ti.recurrence, ti.recurrence_time_zone, ti.occurrence"
+
+const dueDate = `(CASE WHEN ti.all_day THEN (ti.due_at AT TIME ZONE 'UTC')::date
+		ELSE (ti.due_at AT TIME ZONE u.time_zone)::date END)`
+
+// listItemPositions are the positions of the items in the list $1, leaving
+// out those in the trash.
+var listItemPositions = fmt.Sprintf(`SELECT ti.id, ti.position FROM %s ti JOIN %s li ON li.item_id = ti.id
+	WHERE li.list_id = $1 AND ti.deleted_at IS NULL`, todoItemsTable, listsItemsTable)
+
+// dueConditions select the items of GetDue. The dates of all-day items are
+// stored as midnight UTC, every other due date is turned into a day in the
+// time zone of the user u.
+var dueConditions = map[string]string{
+	todo.DueOverdue: `CASE WHEN ti.all_day THEN (ti.due_at AT TIME ZONE 'UTC')::date < (now() AT TIME ZONE u.time_zone)::date
+		ELSE ti.due_at < now() END`,
+	todo.DueToday:    fmt.Sprintf("%s = (now() AT TIME ZONE u.time_zone)::date", dueDate),
+	todo.DueThisWeek: fmt.Sprintf("date_trunc('week', %s) = date_trunc('week', now() AT TIME ZONE u.time_zone)", dueDate),
+}
+
+// Apply makes the change prepared by the service in one transaction, so an
+// update that also completes an occurrence either happens as a whole or not
+// at all.
+func (t *TodoItemPostgres) Apply(userId int, op todo.ItemOperation, maxDepth int) error {
+	return inTx(t.db, func(tx *sqlx.Tx) error {
+		return t.apply(tx, userId, op, maxDepth, make(map[int]bool))
+	})
+}
+
+// update changes the item and applies the completion settings of its list.
+// Moving it under another parent fails with ErrInvalidParent, ErrItemCycle
+// or ErrMaxDepth when the new place is not allowed, a maxDepth of 0 allows
+// any depth.
+func (t *TodoItemPostgres) update(tx *sqlx.Tx, userId int, itemId int, input todo.UpdateItemInput, maxDepth int) error {
+	var oldParentId *int
+	if input.ParentId.Set {
+		listId, err := editableItemList(tx.Tx, t.db, userId, itemId)
+		if err != nil {
+			return err
+		}
+		if err := lockList(tx.Tx, listId); err != nil {
+			return err
+		}
+		parentQuery := fmt.Sprintf("SELECT parent_id FROM %s WHERE id = $1", todoItemsTable)
+		if err := tx.QueryRow(parentQuery, itemId).Scan(&oldParentId); err != nil {
+			return err
+		}
+		if input.ParentId.Value != nil {
+			if err := checkParent(tx.Tx, listId, itemId, *input.ParentId.Value, maxDepth); err != nil {
+				return err
+			}
+		}
+	}
+
 	setValues := make([]string, 0)
 	args := make([]interface{}, 0)
 	argId := 1
@@ -35,88 +94,831 @@ func (t *TodoItemPostgres) Update(userId int, itemId int, input todo.UpdateItemI
 		argId++
 	}
 
+	if input.StartAt.Set {
+		setValues = append(setValues, fmt.Sprintf("start_at=$%d", argId))
+		args = append(args, input.StartAt.Time)
+		argId++
+	}
+
+	if input.DueAt.Set {
+		setValues = append(setValues, fmt.Sprintf("due_at=$%d", argId))
+		args = append(args, input.DueAt.Time)
+		argId++
+	}
+
+	if input.AllDay != nil {
+		setValues = append(setValues, fmt.Sprintf("all_day=$%d", argId))
+		args = append(args, *input.AllDay)
+		argId++
+	}
+
+	if input.Priority != nil {
+		setValues = append(setValues, fmt.Sprintf("priority=$%d", argId))
+		args = append(args, *input.Priority)
+		argId++
+	}
+
+	if input.ParentId.Set {
+		setValues = append(setValues, fmt.Sprintf("parent_id=$%d", argId))
+		args = append(args, input.ParentId.Value)
+		argId++
+	}
+
+	// a new rule starts counting its occurrences again
+	if input.Recurrence != nil {
+		setValues = append(setValues, fmt.Sprintf("recurrence=NULLIF($%d, ''), occurrence=1", argId),
+			fmt.Sprintf("recurrence_time_zone=$%d", argId+1))
+		args = append(args, *input.Recurrence, input.RecurrenceTimeZone)
+		argId += 2
+	}
+
 	setQuery := strings.Join(setValues, ", ")
 
 	query := fmt.Sprintf(`update %s ti set %s from %s li, %s ul
-									where ti.id = li.item_id and li.list_id = ul.list_id and ul.user_id = $%d and ti.id = $%d
-    `, todoItemsTable, setQuery, listsItemsTable, usersListsTable, argId, argId+1)
+									where ti.id = li.item_id and li.list_id = ul.list_id and ul.user_id = $%d and ti.id = $%d and %s
+									and ti.deleted_at is null
+									returning ti.parent_id
+    `, todoItemsTable, setQuery, listsItemsTable, listAccessView, argId, argId+1, canEditList)
 
 	args = append(args, userId, itemId)
 
 	logrus.Debug("updateQuery:", query)
 	logrus.Debug("args", args)
 
-	_, err := t.db.Exec(query, args...)
-	return err
+	var parentId *int
+	if err := tx.QueryRow(query, args...).Scan(&parentId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return itemAccessError(t.db, userId, itemId)
+		}
+		return err
+	}
+
+	if input.DueAt.Set {
+		if err := rearmReminders(tx.Tx, itemId); err != nil {
+			return err
+		}
+	}
+
+	if input.Done != nil || input.ParentId.Set {
+		completeSubtasks, autoCompleteParent, err := completionSettings(tx.Tx, itemId)
+		if err != nil {
+			return err
+		}
+		if completeSubtasks && input.Done != nil && *input.Done {
+			if err := markDescendants(tx.Tx, itemId, true); err != nil {
+				return err
+			}
+		}
+		if autoCompleteParent {
+			for _, id := range []*int{parentId, oldParentId} {
+				if err := updateParents(tx.Tx, id); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
 }
 
+// Delete moves the item together with its subtasks to the trash. They all
+// get the same deleted_at, which tells them apart from subtasks that were
+// deleted on their own before.
 func (t *TodoItemPostgres) Delete(userId, itemId int) error {
-	query := fmt.Sprintf(`
-	DELETE FROM %s ti
-	USING %s li, %s ul
-	WHERE ti.id = li.item_id
-  		AND li.list_id = ul.list_id
-  		AND ul.user_id = $1
-  		AND ti.id = $2;
-    `, todoItemsTable, listsItemsTable, usersListsTable)
-	logrus.Printf("Generated query: %s", query)
-	logrus.Printf("Args: userId=%d, itemId=%d", userId, itemId)
-	_, err := t.db.Exec(query, userId, itemId)
-	return err
+	return inTx(t.db, func(tx *sqlx.Tx) error {
+		return t.delete(tx, userId, itemId)
+	})
+}
+
+func (t *TodoItemPostgres) delete(tx *sqlx.Tx, userId, itemId int) error {
+	if _, err := editableItemList(tx.Tx, t.db, userId, itemId); err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`WITH RECURSIVE subtree (id) AS (
+		SELECT id FROM %[1]s WHERE id = $1
+		UNION ALL
+		SELECT ti.id FROM %[1]s ti JOIN subtree s ON ti.parent_id = s.id WHERE ti.deleted_at IS NULL
+	)
+	UPDATE %[1]s SET deleted_at = now() WHERE id IN (SELECT id FROM subtree)`, todoItemsTable)
+	if _, err := tx.Exec(query, itemId); err != nil {
+		return err
+	}
+
+	var parentId *int
+	parentQuery := fmt.Sprintf("SELECT parent_id FROM %s WHERE id = $1", todoItemsTable)
+	if err := tx.Get(&parentId, parentQuery, itemId); err != nil {
+		return err
+	}
+
+	// the remaining subtasks of the parent may all be done now
+	if parentId != nil {
+		_, autoCompleteParent, err := completionSettings(tx.Tx, *parentId)
+		if err != nil {
+			return err
+		}
+		if autoCompleteParent {
+			if err := updateParents(tx.Tx, parentId); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func (t *TodoItemPostgres) GetById(userId, itemId int) (todo.TodoItem, error) {
 	todoItemQuery := fmt.Sprintf(`
-	SELECT ti.id, ti.title, ti.description, ti.done
+	SELECT %s
 	FROM %s ti
          JOIN %s li on ti.id = li.item_id
          JOIN %s ul on ul.list_id = li.list_id AND ti.id = $1 AND ul.user_id = $2
-`, todoItemsTable, listsItemsTable, usersListsTable)
+	WHERE ti.deleted_at IS NULL
+`, itemColumns, todoItemsTable, listsItemsTable, listAccessView)
 	var item todo.TodoItem
 	if err := t.db.Get(&item, todoItemQuery, itemId, userId); err != nil {
 		return item, err
 	}
-	return item, nil
+	items := []todo.TodoItem{item}
+	if err := loadItemLabels(t.db, userId, items); err != nil {
+		return item, err
+	}
+	return items[0], nil
 }
 
-func (t *TodoItemPostgres) GetAll(userId int, listId int) ([]todo.TodoItem, error) {
+func (t *TodoItemPostgres) GetAll(userId int, listId int, filter todo.ItemFilter) ([]todo.TodoItem, error) {
+	conditions := []string{"ti.deleted_at IS NULL"}
+	args := []interface{}{userId, listId}
+
+	if filter.LabelId != nil {
+		args = append(args, *filter.LabelId)
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM %s il WHERE il.item_id = ti.id AND il.label_id = $%d)", itemsLabelsTable, len(args)))
+	}
+
+	if filter.Priority != nil {
+		args = append(args, *filter.Priority)
+		conditions = append(conditions, fmt.Sprintf("ti.priority = $%d", len(args)))
+	}
+
 	todoItemsQuery := fmt.Sprintf(`
-	SELECT ti.id, ti.title, ti.description, ti.done
+	SELECT %s
 	FROM %s ti
          JOIN %s li on ti.id = li.item_id
          JOIN %s ul on li.list_id = ul.list_id AND ul.user_id = $1 AND ul.list_id = $2
-	`, todoItemsTable, listsItemsTable, usersListsTable)
+	WHERE %s
+	ORDER BY ti.position, ti.id
+	`, itemColumns, todoItemsTable, listsItemsTable, listAccessView, strings.Join(conditions, " AND "))
 	var items []todo.TodoItem
-	if err := t.db.Select(&items, todoItemsQuery, userId, listId); err != nil {
+	if err := t.db.Select(&items, todoItemsQuery, args...); err != nil {
 		return items, err
 	}
-	return items, nil
+	return items, loadItemLabels(t.db, userId, items)
 }
 
-func (t *TodoItemPostgres) Create(listId int, todoItem todo.TodoItem) (int, error) {
+// GetDue returns the open items with a due date matching the filter from all
+// lists the user can read, the earliest due first.
+func (t *TodoItemPostgres) GetDue(userId int, due string) ([]todo.TodoItem, error) {
+	condition, ok := dueConditions[due]
+	if !ok {
+		return nil, fmt.Errorf("unknown due filter %q", due)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM %s ti
+         JOIN %s li on ti.id = li.item_id
+         JOIN %s ul on li.list_id = ul.list_id AND ul.user_id = $1
+         JOIN %s u on u.id = ul.user_id
+	WHERE NOT ti.done AND ti.due_at IS NOT NULL AND ti.deleted_at IS NULL AND %s
+	ORDER BY ti.due_at, ti.id`, itemColumns, todoItemsTable, listsItemsTable, listAccessView, usersTable, condition)
+	items := make([]todo.TodoItem, 0)
+	if err := t.db.Select(&items, query, userId); err != nil {
+		return nil, err
+	}
+	return items, loadItemLabels(t.db, userId, items)
+}
+
+// Create adds the item to the list, as a subtask if todoItem.ParentId is set.
+// The parent is checked like in update.
+func (t *TodoItemPostgres) Create(userId, listId int, todoItem todo.TodoItem, maxDepth int) (int, error) {
 	tx, err := t.db.Begin()
 	if err != nil {
 		return 0, err
 	}
 
-	var itemId int
+	if err := requireListEditor(tx, t.db, userId, listId); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	// new items go to the end, the lock keeps them from sharing a position
+	if err := lockList(tx, listId); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	itemPosition, err := lastPosition(tx, listItemPositions, listId)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
 
-	createItemQuery := fmt.Sprintf(`INSERT INTO %s (title, description) VALUES ($1, $2) RETURNING id`, todoItemsTable)
-	row := t.db.QueryRow(createItemQuery, todoItem.Title, todoItem.Description)
+	var itemId int
+	createItemQuery := fmt.Sprintf(`INSERT INTO %s (title, description, start_at, due_at, all_day, priority, parent_id, position, recurrence,
+		recurrence_time_zone)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING id`, todoItemsTable)
+	row := tx.QueryRow(createItemQuery, todoItem.Title, todoItem.Description, todoItem.StartAt, todoItem.DueAt, todoItem.AllDay,
+		todoItem.Priority, todoItem.ParentId, itemPosition, todoItem.Recurrence, todoItem.RecurrenceTimeZone)
 	if err := row.Scan(&itemId); err != nil {
 		tx.Rollback()
 		return 0, err
 	}
 
 	createListsItemsQuery := fmt.Sprintf(`INSERT INTO %s (item_id, list_id) VALUES ($1, $2)`, listsItemsTable)
-	_, err = t.db.Exec(createListsItemsQuery, itemId, listId)
+	_, err = tx.Exec(createListsItemsQuery, itemId, listId)
 	if err != nil {
 		tx.Rollback()
 		return 0, err
 	}
+
+	if todoItem.ParentId != nil {
+		if err := checkParent(tx, listId, itemId, *todoItem.ParentId, maxDepth); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		// a new open subtask reopens an auto-completed parent
+		_, autoCompleteParent, err := completionSettings(tx, itemId)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		if autoCompleteParent {
+			if err := updateParents(tx, todoItem.ParentId); err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+		}
+	}
 	return itemId, tx.Commit()
 }
 
+// subtreeItem is an item that is moved or copied along with its parent.
+type subtreeItem struct {
+	Id       int  `db:"id"`
+	ParentId *int `db:"parent_id"`
+	Level    int  `db:"level"`
+	// Deleted subtasks move with their parent, so they can be restored in
+	// place, but are not copied.
+	Deleted bool `db:"deleted"`
+}
+
+// Move changes the position of the item within its list or takes it with
+// its subtasks to another list. There it becomes a top-level item and loses
+// the labels of other workspaces.
+func (t *TodoItemPostgres) Move(userId, itemId int, input todo.MoveItemInput) error {
+	return inTx(t.db, func(tx *sqlx.Tx) error {
+		return t.move(tx, userId, itemId, input)
+	})
+}
+
+func (t *TodoItemPostgres) move(tx *sqlx.Tx, userId, itemId int, input todo.MoveItemInput) error {
+	listId, err := editableItemList(tx.Tx, t.db, userId, itemId)
+	if err != nil {
+		return err
+	}
+	if input.ListId == nil || *input.ListId == listId {
+		if err := lockList(tx.Tx, listId); err != nil {
+			return err
+		}
+		itemPosition, err := movePosition(tx.Tx, listItemPositions, listId, itemId, input.MoveInput)
+		if err != nil {
+			return err
+		}
+		query := fmt.Sprintf("UPDATE %s SET position = $1 WHERE id = $2", todoItemsTable)
+		if _, err := tx.Exec(query, itemPosition, itemId); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	targetId := *input.ListId
+	if err := requireListEditor(tx.Tx, t.db, userId, targetId); err != nil {
+		return err
+	}
+	if err := lockLists(tx.Tx, listId, targetId); err != nil {
+		return err
+	}
+	subtree, err := itemSubtree(tx, itemId)
+	if err != nil {
+		return err
+	}
+	positions, err := subtreePositions(tx.Tx, targetId, itemId, len(subtree), input.MoveInput)
+	if err != nil {
+		return err
+	}
+
+	var oldParentId *int
+	parentQuery := fmt.Sprintf("SELECT parent_id FROM %s WHERE id = $1", todoItemsTable)
+	if err := tx.Get(&oldParentId, parentQuery, itemId); err != nil {
+		return err
+	}
+	detachQuery := fmt.Sprintf("UPDATE %s SET parent_id = NULL WHERE id = $1", todoItemsTable)
+	if _, err := tx.Exec(detachQuery, itemId); err != nil {
+		return err
+	}
+	ids := make([]int64, len(subtree))
+	positionQuery := fmt.Sprintf("UPDATE %s SET position = $1 WHERE id = $2", todoItemsTable)
+	for i, item := range subtree {
+		ids[i] = int64(item.Id)
+		if _, err := tx.Exec(positionQuery, positions[i], item.Id); err != nil {
+			return err
+		}
+	}
+	listQuery := fmt.Sprintf("UPDATE %s SET list_id = $1 WHERE item_id = ANY($2)", listsItemsTable)
+	if _, err := tx.Exec(listQuery, targetId, pq.Array(ids)); err != nil {
+		return err
+	}
+	labelQuery := fmt.Sprintf(`DELETE FROM %s il USING %s l
+	WHERE l.id = il.label_id AND il.item_id = ANY($1) AND l.workspace_id IS NOT NULL
+		AND l.workspace_id <> (SELECT workspace_id FROM %s WHERE id = $2)`, itemsLabelsTable, labelsTable, todoListsTable)
+	if _, err := tx.Exec(labelQuery, pq.Array(ids), targetId); err != nil {
+		return err
+	}
+
+	// the subtasks left behind may all be done now
+	if oldParentId != nil {
+		_, autoCompleteParent, err := completionSettings(tx.Tx, *oldParentId)
+		if err != nil {
+			return err
+		}
+		if autoCompleteParent {
+			if err := updateParents(tx.Tx, oldParentId); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Copy copies the item with its subtasks into the list input.ListId, its
+// own list by default, and returns the id of the copy. A copy within the
+// list keeps the parent of the item, in another list it becomes a top-level
+// item. Labels of other workspaces, reminders and the completion history
+// are not copied.
+func (t *TodoItemPostgres) Copy(userId, itemId int, input todo.MoveItemInput) (int, error) {
+	tx, err := t.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	listId, err := editableItemList(tx.Tx, t.db, userId, itemId)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	targetId := listId
+	if input.ListId != nil && *input.ListId != listId {
+		targetId = *input.ListId
+		if err := requireListEditor(tx.Tx, t.db, userId, targetId); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := lockLists(tx.Tx, listId, targetId); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	all, err := itemSubtree(tx, itemId)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	subtree := make([]subtreeItem, 0, len(all))
+	for _, item := range all {
+		if !item.Deleted {
+			subtree = append(subtree, item)
+		}
+	}
+	// the original stays in place, so it can serve as an anchor
+	positions, err := subtreePositions(tx.Tx, targetId, 0, len(subtree), input.MoveInput)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	itemPositions := make(map[int]string, len(subtree))
+	for i, item := range subtree {
+		itemPositions[item.Id] = positions[i]
+	}
+
+	// parents are copied ahead of their subtasks
+	sort.SliceStable(subtree, func(i, j int) bool { return subtree[i].Level < subtree[j].Level })
+	copies := make(map[int]int, len(subtree))
+	copyQuery := fmt.Sprintf(`INSERT INTO %[1]s (title, description, done, start_at, due_at, all_day, priority, recurrence,
+		recurrence_time_zone, occurrence, parent_id, position)
+	SELECT title, description, done, start_at, due_at, all_day, priority, recurrence, recurrence_time_zone, occurrence, $2, $3
+	FROM %[1]s WHERE id = $1
+	RETURNING id`, todoItemsTable)
+	listQuery := fmt.Sprintf("INSERT INTO %s (item_id, list_id) VALUES ($1, $2)", listsItemsTable)
+	labelQuery := fmt.Sprintf(`INSERT INTO %[1]s (item_id, label_id)
+	SELECT $2, il.label_id FROM %[1]s il
+	         JOIN %[2]s l ON l.id = il.label_id
+	WHERE il.item_id = $1 AND (l.workspace_id IS NULL OR l.workspace_id = (SELECT workspace_id FROM %[3]s WHERE id = $3))`,
+		itemsLabelsTable, labelsTable, todoListsTable)
+	for _, item := range subtree {
+		var parentId *int
+		if item.Level > 0 {
+			parentCopy := copies[*item.ParentId]
+			parentId = &parentCopy
+		} else if targetId == listId {
+			parentId = item.ParentId
+		}
+
+		var copyId int
+		if err := tx.QueryRow(copyQuery, item.Id, parentId, itemPositions[item.Id]).Scan(&copyId); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		copies[item.Id] = copyId
+		if _, err := tx.Exec(listQuery, copyId, targetId); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		if _, err := tx.Exec(labelQuery, item.Id, copyId, targetId); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	return copies[itemId], tx.Commit()
+}
+
+// itemSubtree returns the item followed by its subtasks in the order of the
+// list.
+func itemSubtree(tx *sqlx.Tx, itemId int) ([]subtreeItem, error) {
+	var subtree []subtreeItem
+	query := fmt.Sprintf(`WITH RECURSIVE subtree (id, parent_id, level) AS (
+		SELECT id, parent_id, 0 FROM %[1]s WHERE id = $1
+		UNION ALL
+		SELECT ti.id, ti.parent_id, s.level + 1 FROM %[1]s ti JOIN subtree s ON ti.parent_id = s.id
+	)
+	SELECT s.id, s.parent_id, s.level, ti.deleted_at IS NOT NULL AS deleted FROM subtree s
+	         JOIN %[1]s ti ON ti.id = s.id
+	ORDER BY s.level > 0, ti.position, ti.id`, todoItemsTable)
+	err := tx.Select(&subtree, query, itemId)
+	return subtree, err
+}
+
+// subtreePositions places count items in a row in the list, the first one
+// where the move puts the item itemId and the rest right after it.
+func subtreePositions(tx *sql.Tx, listId, itemId, count int, input todo.MoveInput) ([]string, error) {
+	first, err := movePosition(tx, listItemPositions, listId, itemId, input)
+	if err != nil {
+		return nil, err
+	}
+	var next sql.NullString
+	query := fmt.Sprintf("SELECT min(p.position) FROM (%s) p WHERE p.position > $2", listItemPositions)
+	if err := tx.QueryRow(query, listId, first).Scan(&next); err != nil {
+		return nil, err
+	}
+
+	positions := []string{first}
+	for len(positions) < count {
+		key, err := position.Between(positions[len(positions)-1], next.String)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, key)
+	}
+	return positions, nil
+}
+
+// lockLists locks two lists like lockList, in a fixed order to avoid
+// deadlocks with a transfer the other way.
+func lockLists(tx *sql.Tx, listId, otherId int) error {
+	if otherId < listId {
+		listId, otherId = otherId, listId
+	}
+	if err := lockList(tx, listId); err != nil {
+		return err
+	}
+	if otherId == listId {
+		return nil
+	}
+	return lockList(tx, otherId)
+}
+
+// completeOccurrence completes the given occurrence of a recurring item and
+// records it. With next the item stays open and moves on to that occurrence,
+// its subtasks and reminders are reopened for it. Without next the series is
+// over and the item is completed like in update. Nothing happens if the
+// occurrence was completed in the meantime.
+func (t *TodoItemPostgres) completeOccurrence(tx *sqlx.Tx, userId, itemId, occurrence int, next *todo.Occurrence) error {
+	if _, err := editableItemList(tx.Tx, t.db, userId, itemId); err != nil {
+		return err
+	}
+	var done bool
+	var current int
+	lockQuery := fmt.Sprintf("SELECT done, occurrence FROM %s WHERE id = $1 FOR UPDATE", todoItemsTable)
+	if err := tx.QueryRow(lockQuery, itemId).Scan(&done, &current); err != nil {
+		return err
+	}
+	if done || current != occurrence {
+		return nil
+	}
+
+	completionQuery := fmt.Sprintf(`INSERT INTO %s (item_id, user_id, due_at)
+	SELECT id, $2, due_at FROM %s WHERE id = $1`, itemCompletionsTable, todoItemsTable)
+	if _, err := tx.Exec(completionQuery, itemId, userId); err != nil {
+		return err
+	}
+
+	if next != nil {
+		query := fmt.Sprintf("UPDATE %s SET start_at = $1, due_at = $2, occurrence = occurrence + 1 WHERE id = $3",
+			todoItemsTable)
+		if _, err := tx.Exec(query, next.StartAt, next.DueAt, itemId); err != nil {
+			return err
+		}
+		if err := markDescendants(tx.Tx, itemId, false); err != nil {
+			return err
+		}
+		if err := rearmReminders(tx.Tx, itemId); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	var parentId *int
+	query := fmt.Sprintf("UPDATE %s SET done = true WHERE id = $1 RETURNING parent_id", todoItemsTable)
+	if err := tx.QueryRow(query, itemId).Scan(&parentId); err != nil {
+		return err
+	}
+	completeSubtasks, autoCompleteParent, err := completionSettings(tx.Tx, itemId)
+	if err != nil {
+		return err
+	}
+	if completeSubtasks {
+		if err := markDescendants(tx.Tx, itemId, true); err != nil {
+			return err
+		}
+	}
+	if autoCompleteParent {
+		if err := updateParents(tx.Tx, parentId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bulk applies the operations in one transaction and returns the error of
+// each of them, nil for those that succeeded. In atomic mode the first
+// failure rolls back everything and the operations after it are not tried,
+// otherwise a failure only undoes its own operation. Moved items are placed
+// one after another in every list they end up in: a move goes right after the
+// item moved last into the same list, so a failed move does not take the
+// anchor of the following ones with it, and the first one into a list is
+// placed as requested. The returned error is about the transaction itself.
+func (t *TodoItemPostgres) Bulk(userId int, ops []todo.ItemOperation, atomic bool, maxDepth int) ([]error, error) {
+	tx, err := t.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]error, len(ops))
+	// items removed along with a parent deleted before them
+	deleted := make(map[int]bool)
+	// list -> the item moved into it last
+	lastMoved := make(map[int]int)
+	for i, op := range ops {
+		// the list the item is moved to, 0 when its own list is not found
+		var movedTo int
+		if op.Move != nil {
+			if op.Move.ListId != nil {
+				movedTo = *op.Move.ListId
+			} else if listId, err := editableItemList(tx.Tx, t.db, userId, op.ItemId); err == nil {
+				movedTo = listId
+			}
+			if afterId, ok := lastMoved[movedTo]; ok {
+				move := *op.Move
+				move.AfterId = &afterId
+				op.Move = &move
+			}
+		}
+		if !atomic {
+			if _, err := tx.Exec("SAVEPOINT bulk_item"); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+
+		results[i] = t.apply(tx, userId, op, maxDepth, deleted)
+		if results[i] == nil && op.Move != nil && movedTo != 0 {
+			lastMoved[movedTo] = op.ItemId
+		}
+		switch {
+		case results[i] != nil && atomic:
+			return results, tx.Rollback()
+		case results[i] != nil:
+			_, err = tx.Exec("ROLLBACK TO SAVEPOINT bulk_item")
+		case !atomic:
+			_, err = tx.Exec("RELEASE SAVEPOINT bulk_item")
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return results, tx.Commit()
+}
+
+func (t *TodoItemPostgres) apply(tx *sqlx.Tx, userId int, op todo.ItemOperation, maxDepth int, deleted map[int]bool) error {
+	switch {
+	case op.Delete:
+		if deleted[op.ItemId] {
+			return nil
+		}
+		subtree, err := itemSubtree(tx, op.ItemId)
+		if err != nil {
+			return err
+		}
+		if err := t.delete(tx, userId, op.ItemId); err != nil {
+			return err
+		}
+		for _, item := range subtree {
+			deleted[item.Id] = true
+		}
+		return nil
+	case op.Move != nil:
+		return t.move(tx, userId, op.ItemId, *op.Move)
+	}
+
+	if op.Update != nil {
+		if err := t.update(tx, userId, op.ItemId, *op.Update, maxDepth); err != nil {
+			return err
+		}
+	}
+	if op.Complete != nil {
+		return t.completeOccurrence(tx, userId, op.ItemId, op.Complete.Occurrence, op.Complete.Next)
+	}
+	return nil
+}
+
+// GetCompletions returns the completed occurrences of the item, the latest
+// first.
+func (t *TodoItemPostgres) GetCompletions(userId, itemId int) ([]todo.ItemCompletion, error) {
+	if _, err := t.GetById(userId, itemId); err != nil {
+		return nil, err
+	}
+	completions := make([]todo.ItemCompletion, 0)
+	query := fmt.Sprintf(`SELECT id, user_id, due_at, completed_at FROM %s WHERE item_id = $1
+	ORDER BY completed_at DESC, id DESC`, itemCompletionsTable)
+	err := t.db.Select(&completions, query, itemId)
+	return completions, err
+}
+
+// inTx runs fn in a transaction that is rolled back when fn fails.
+func inTx(db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// requireListEditor fails like listAccessError unless the user can edit the
+// list.
+func requireListEditor(tx *sql.Tx, db *sqlx.DB, userId, listId int) error {
+	var role string
+	query := fmt.Sprintf("SELECT ul.role FROM %s ul WHERE ul.user_id = $1 AND ul.list_id = $2 AND %s", listAccessView, canEditList)
+	if err := tx.QueryRow(query, userId, listId).Scan(&role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return listAccessError(db, userId, listId)
+		}
+		return err
+	}
+	return nil
+}
+
+// editableItemList returns the list of the item if the user can edit it.
+// Items in the trash are not found.
+func editableItemList(tx *sql.Tx, db *sqlx.DB, userId, itemId int) (int, error) {
+	var listId int
+	query := fmt.Sprintf(`SELECT li.list_id FROM %s li
+	         JOIN %s ti ON ti.id = li.item_id
+	         JOIN %s ul ON ul.list_id = li.list_id
+	WHERE li.item_id = $1 AND ul.user_id = $2 AND ti.deleted_at IS NULL AND %s`,
+		listsItemsTable, todoItemsTable, listAccessView, canEditList)
+	if err := tx.QueryRow(query, itemId, userId).Scan(&listId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, itemAccessError(db, userId, itemId)
+		}
+		return 0, err
+	}
+	return listId, nil
+}
+
+// lockList serializes changes to the order and the item tree of a list.
+func lockList(tx *sql.Tx, listId int) error {
+	query := fmt.Sprintf("SELECT id FROM %s WHERE id = $1 FOR NO KEY UPDATE", todoListsTable)
+	return tx.QueryRow(query, listId).Scan(&listId)
+}
+
+// checkParent verifies that the item can be put under parentId: the parent
+// has to be in the same list, not in the trash, and must not be the item or
+// one of its subtasks, and the subtasks of the item must stay within
+// maxDepth levels.
+func checkParent(tx *sql.Tx, listId, itemId, parentId, maxDepth int) error {
+	var parentListId int
+	listQuery := fmt.Sprintf(`SELECT li.list_id FROM %s li JOIN %s ti ON ti.id = li.item_id
+	WHERE li.item_id = $1 AND ti.deleted_at IS NULL`, listsItemsTable, todoItemsTable)
+	if err := tx.QueryRow(listQuery, parentId).Scan(&parentListId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidParent
+		}
+		return err
+	}
+	if parentListId != listId {
+		return ErrInvalidParent
+	}
+
+	// the parent and its ancestors, the item among them would close a cycle
+	var depth int
+	var cycle bool
+	ancestorsQuery := fmt.Sprintf(`WITH RECURSIVE ancestors (id, parent_id) AS (
+		SELECT id, parent_id FROM %[1]s WHERE id = $1
+		UNION ALL
+		SELECT ti.id, ti.parent_id FROM %[1]s ti JOIN ancestors a ON ti.id = a.parent_id
+	)
+	SELECT count(*), coalesce(bool_or(id = $2), false) FROM ancestors`, todoItemsTable)
+	if err := tx.QueryRow(ancestorsQuery, parentId, itemId).Scan(&depth, &cycle); err != nil {
+		return err
+	}
+	if cycle {
+		return ErrItemCycle
+	}
+	if maxDepth <= 0 {
+		return nil
+	}
+
+	var height int
+	heightQuery := fmt.Sprintf(`WITH RECURSIVE subtree (id, level) AS (
+		SELECT id, 1 FROM %[1]s WHERE id = $1
+		UNION ALL
+		SELECT ti.id, s.level + 1 FROM %[1]s ti JOIN subtree s ON ti.parent_id = s.id
+	)
+	SELECT max(level) FROM subtree`, todoItemsTable)
+	if err := tx.QueryRow(heightQuery, itemId).Scan(&height); err != nil {
+		return err
+	}
+	if depth+height > maxDepth {
+		return ErrMaxDepth
+	}
+	return nil
+}
+
+// completionSettings reads the completion settings of the list of the item.
+func completionSettings(tx *sql.Tx, itemId int) (completeSubtasks, autoCompleteParent bool, err error) {
+	query := fmt.Sprintf(`SELECT tl.complete_subtasks, tl.auto_complete_parent FROM %s tl
+	         JOIN %s li ON li.list_id = tl.id
+	WHERE li.item_id = $1`, todoListsTable, listsItemsTable)
+	err = tx.QueryRow(query, itemId).Scan(&completeSubtasks, &autoCompleteParent)
+	return completeSubtasks, autoCompleteParent, err
+}
+
+// markDescendants completes or reopens all subtasks of the item.
+func markDescendants(tx *sql.Tx, itemId int, done bool) error {
+	query := fmt.Sprintf(`WITH RECURSIVE descendants (id) AS (
+		SELECT id FROM %[1]s WHERE parent_id = $1
+		UNION ALL
+		SELECT ti.id FROM %[1]s ti JOIN descendants d ON ti.parent_id = d.id
+	)
+	UPDATE %[1]s SET done = $2 WHERE id IN (SELECT id FROM descendants)`, todoItemsTable)
+	_, err := tx.Exec(query, itemId, done)
+	return err
+}
+
+// updateParents marks parentId done when all of its subtasks are and open
+// otherwise, and continues upwards for as long as that changes anything.
+// Subtasks in the trash do not count.
+func updateParents(tx *sql.Tx, parentId *int) error {
+	query := fmt.Sprintf(`WITH state AS (
+		SELECT bool_and(done) AS done FROM %[1]s WHERE parent_id = $1 AND deleted_at IS NULL
+	)
+	UPDATE %[1]s p SET done = state.done FROM state
+	WHERE p.id = $1 AND state.done IS NOT NULL AND p.done <> state.done
+	RETURNING p.parent_id`, todoItemsTable)
+	for parentId != nil {
+		var next *int
+		if err := tx.QueryRow(query, *parentId).Scan(&next); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		parentId = next
+	}
+	return nil
+}
+
 func NewTodoItemPostgres(db *sqlx.DB) *TodoItemPostgres {
 	return &TodoItemPostgres{db: db}
 }
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Olmosbek510/todo-app"
+	"testing"
+)
+
+const testUserId = 7
+
+// expectItemList expects the lookup of the list of an item the user can edit.
+func expectItemList(mock sqlmock.Sqlmock, itemId, listId int) {
+	mock.ExpectQuery(`SELECT li.list_id FROM lists_items li\s+JOIN todo_items ti ON ti.id = li.item_id\s+JOIN list_access ul`).
+		WithArgs(itemId, testUserId).
+		WillReturnRows(sqlmock.NewRows([]string{"list_id"}).AddRow(listId))
+}
+
+// expectItemNotFound expects the lookup of an item the user cannot reach.
+func expectItemNotFound(mock sqlmock.Sqlmock, itemId int) {
+	mock.ExpectQuery(`SELECT li.list_id FROM lists_items li\s+JOIN todo_items ti ON ti.id = li.item_id\s+JOIN list_access ul`).
+		WithArgs(itemId, testUserId).
+		WillReturnRows(sqlmock.NewRows([]string{"list_id"}))
+	mock.ExpectQuery(`SELECT li.list_id FROM lists_items li JOIN todo_items ti ON ti.id = li.item_id\s+WHERE li.item_id = \$1 AND ti.deleted_at IS NULL`).
+		WithArgs(itemId).
+		WillReturnRows(sqlmock.NewRows([]string{"list_id"}))
+}
+
+// expectMoveWithinList expects an item to be moved within its list, right
+// after afterId or to the end without one.
+func expectMoveWithinList(mock sqlmock.Sqlmock, itemId, listId int, afterId *int) {
+	expectItemList(mock, itemId, listId)
+	mock.ExpectQuery(`SELECT id FROM todo_lists WHERE id = \$1 FOR NO KEY UPDATE`).
+		WithArgs(listId).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(listId))
+	if afterId != nil {
+		mock.ExpectQuery(`(?s)SELECT p.position FROM \(.*\) p WHERE p.id = \$2`).
+			WithArgs(listId, *afterId).
+			WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow("a5"))
+		mock.ExpectQuery(`(?s)SELECT min\(p.position\) FROM \(.*\) p WHERE p.id <> \$2 AND p.position > \$3`).
+			WithArgs(listId, itemId, "a5").
+			WillReturnRows(sqlmock.NewRows([]string{"min"}).AddRow(nil))
+	} else {
+		mock.ExpectQuery(`(?s)SELECT max\(p.position\) FROM \(.*\) p WHERE p.id <> \$2 AND TRUE`).
+			WithArgs(listId, itemId).
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+	}
+	mock.ExpectExec(`UPDATE todo_items SET position = \$1 WHERE id = \$2`).
+		WithArgs(sqlmock.AnyArg(), itemId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectSavepoint(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`^SAVEPOINT bulk_item$`).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectRelease(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`^RELEASE SAVEPOINT bulk_item$`).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectRollbackTo(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`^ROLLBACK TO SAVEPOINT bulk_item$`).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func moveOps(ids []int, listId *int) []todo.ItemOperation {
+	ops := make([]todo.ItemOperation, len(ids))
+	for i, id := range ids {
+		ops[i] = todo.ItemOperation{ItemId: id, Move: &todo.MoveItemInput{ListId: listId}}
+	}
+	return ops
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestTodoItemPostgresBulkMoves(t *testing.T) {
+	tests := []struct {
+		name   string
+		ops    []todo.ItemOperation
+		atomic bool
+		expect func(mock sqlmock.Sqlmock)
+		want   []error
+	}{
+		{
+			name:   "items of two lists are chained within their own list",
+			ops:    moveOps([]int{1, 2, 3, 4}, nil),
+			atomic: true,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectItemList(mock, 1, 10)
+				expectMoveWithinList(mock, 1, 10, nil)
+				expectItemList(mock, 2, 20)
+				expectMoveWithinList(mock, 2, 20, nil)
+				expectItemList(mock, 3, 10)
+				expectMoveWithinList(mock, 3, 10, intPtr(1))
+				expectItemList(mock, 4, 20)
+				expectMoveWithinList(mock, 4, 20, intPtr(2))
+				mock.ExpectCommit()
+			},
+			want: []error{nil, nil, nil, nil},
+		},
+		{
+			name:   "moves into the list named by the input are chained",
+			ops:    moveOps([]int{5, 6}, intPtr(30)),
+			atomic: true,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectMoveWithinList(mock, 5, 30, nil)
+				expectMoveWithinList(mock, 6, 30, intPtr(5))
+				mock.ExpectCommit()
+			},
+			want: []error{nil, nil},
+		},
+		{
+			name: "a failed move in partial mode is undone and skipped as anchor",
+			ops:  moveOps([]int{1, 2, 3}, nil),
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectItemList(mock, 1, 10)
+				expectSavepoint(mock)
+				expectMoveWithinList(mock, 1, 10, nil)
+				expectRelease(mock)
+				expectItemNotFound(mock, 2)
+				expectSavepoint(mock)
+				expectItemNotFound(mock, 2)
+				expectRollbackTo(mock)
+				expectItemList(mock, 3, 10)
+				expectSavepoint(mock)
+				expectMoveWithinList(mock, 3, 10, intPtr(1))
+				expectRelease(mock)
+				mock.ExpectCommit()
+			},
+			want: []error{nil, sql.ErrNoRows, nil},
+		},
+		{
+			name:   "a failed move in atomic mode rolls back and stops",
+			ops:    moveOps([]int{1, 2, 3}, nil),
+			atomic: true,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectItemList(mock, 1, 10)
+				expectMoveWithinList(mock, 1, 10, nil)
+				expectItemNotFound(mock, 2)
+				expectItemNotFound(mock, 2)
+				mock.ExpectRollback()
+			},
+			want: []error{nil, sql.ErrNoRows, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			tt.expect(mock)
+
+			results, err := NewTodoItemPostgres(db).Bulk(testUserId, tt.ops, tt.atomic, 0)
+			if err != nil {
+				t.Fatalf("Bulk() error = %v", err)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("Bulk() returned %d results, want %d", len(results), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !errors.Is(results[i], want) {
+					t.Errorf("result %d = %v, want %v", i, results[i], want)
+				}
+			}
+		})
+	}
+}
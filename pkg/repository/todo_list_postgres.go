@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/Olmosbek510/todo-app"
 	"github.com/jmoiron/sqlx"
@@ -12,6 +14,17 @@ type TodoListPostgres struct {
 	db *sqlx.DB
 }
 
+const listColumns = "tl.id, tl.title, tl.description, tl.workspace_id, tl.complete_subtasks, tl.auto_complete_parent, ul.role"
+
+// lists are ordered by the positions of the user ul, the lists they have not
+// placed come last
+var listOrder = fmt.Sprintf(`LEFT JOIN %s lp ON lp.list_id = tl.id AND lp.user_id = ul.user_id`, listPositionsTable)
+
+// userListPositions are the positions of the lists the user $1 can reach.
+var userListPositions = fmt.Sprintf(`SELECT lp.list_id AS id, lp.position FROM %s lp
+	JOIN %s ul ON ul.list_id = lp.list_id AND ul.user_id = lp.user_id
+	WHERE lp.user_id = $1`, listPositionsTable, listAccessView)
+
 func (r *TodoListPostgres) Update(userId int, listId int, input todo.UpdateListInput) error {
 	setValues := make([]string, 0)
 	args := make([]interface{}, 0)
@@ -29,42 +42,69 @@ func (r *TodoListPostgres) Update(userId int, listId int, input todo.UpdateListI
 		argId++
 	}
 
+	if input.CompleteSubtasks != nil {
+		setValues = append(setValues, fmt.Sprintf("complete_subtasks=$%d", argId))
+		args = append(args, *input.CompleteSubtasks)
+		argId++
+	}
+
+	if input.AutoCompleteParent != nil {
+		setValues = append(setValues, fmt.Sprintf("auto_complete_parent=$%d", argId))
+		args = append(args, *input.AutoCompleteParent)
+		argId++
+	}
+
 	setQuery := strings.Join(setValues, ", ")
 
-	query := fmt.Sprintf("UPDATE %s tl SET %s FROM %s ul WHERE tl.id = ul.list_id AND ul.list_id=$%d AND ul.user_id=$%d",
-		todoListsTable, setQuery, usersListsTable, argId, argId+1)
+	query := fmt.Sprintf("UPDATE %s tl SET %s FROM %s ul WHERE tl.id = ul.list_id AND ul.list_id=$%d AND ul.user_id=$%d AND %s",
+		todoListsTable, setQuery, listAccessView, argId, argId+1, canEditList)
 
 	args = append(args, listId, userId)
 
 	logrus.Debug("updateQuery:", query)
 	logrus.Debug("args", args)
 
-	_, err := r.db.Exec(query, args...)
-	return err
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		return listAccessError(r.db, userId, listId)
+	}
+	return nil
 }
 
+// DeleteById moves the list to the trash, its items stay linked to it and
+// come back when it is restored.
 func (r *TodoListPostgres) DeleteById(userId, listId int) error {
 	query := fmt.Sprintf(`
-	DELETE
-	FROM %s tl USING %s ul
+	UPDATE %s tl SET deleted_at = now()
+	FROM %s ul
 	WHERE tl.id = ul.list_id
   	AND ul.user_id = $1
   	AND ul.list_id = $2
-	`, todoListsTable, usersListsTable)
-	_, err := r.db.Exec(query, userId, listId)
-	return err
+  	AND %s
+	`, todoListsTable, listAccessView, canManageList)
+	res, err := r.db.Exec(query, userId, listId)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		return listAccessError(r.db, userId, listId)
+	}
+	return nil
 }
 
 func (r *TodoListPostgres) GetById(userId int, listId int) (todo.TodoList, error) {
 	var list todo.TodoList
 
 	query := fmt.Sprintf(`
-	SELECT tl.id, tl.title, tl.description
+	SELECT %s
 	FROM %s tl
          join %s ul on tl.id = ul.list_id
 	WHERE tl.id = $1
   		AND ul.user_id = $2
-	`, todoListsTable, usersListsTable)
+	`, listColumns, todoListsTable, listAccessView)
 
 	err := r.db.Get(&list, query, listId, userId)
 	return list, err
@@ -72,16 +112,31 @@ func (r *TodoListPostgres) GetById(userId int, listId int) (todo.TodoList, error
 
 func (r *TodoListPostgres) GetAll(userId int) ([]todo.TodoList, error) {
 	var lists []todo.TodoList
-	query := fmt.Sprintf("SELECT tl.id, tl.title, tl.description FROM %s tl INNER JOIN %s ul ON tl.id = ul.list_id WHERE ul.user_id = $1",
-		todoListsTable, usersListsTable)
+	query := fmt.Sprintf(`SELECT %s FROM %s tl INNER JOIN %s ul ON tl.id = ul.list_id %s WHERE ul.user_id = $1
+	ORDER BY lp.position NULLS LAST, tl.id`, listColumns, todoListsTable, listAccessView, listOrder)
 	err := r.db.Select(&lists, query, userId)
 	return lists, err
 }
 
+func (r *TodoListPostgres) GetAllInWorkspace(userId, workspaceId int) ([]todo.TodoList, error) {
+	var lists []todo.TodoList
+	query := fmt.Sprintf(`SELECT %s
+	FROM %s tl
+	         INNER JOIN %s ul ON tl.id = ul.list_id
+	         %s
+	WHERE ul.user_id = $1 AND tl.workspace_id = $2
+	ORDER BY lp.position NULLS LAST, tl.id`, listColumns, todoListsTable, listAccessView, listOrder)
+	err := r.db.Select(&lists, query, userId, workspaceId)
+	return lists, err
+}
+
 func NewTodoListPostgres(db *sqlx.DB) *TodoListPostgres {
 	return &TodoListPostgres{db: db}
 }
 
+// Create adds the list to list.WorkspaceId and to the end of the lists of
+// the user. It fails with sql.ErrNoRows if the user is not a member of the
+// workspace and with ErrInsufficientRole if they are only a viewer there.
 func (r *TodoListPostgres) Create(userId int, list todo.TodoList) (int, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -89,19 +144,102 @@ func (r *TodoListPostgres) Create(userId int, list todo.TodoList) (int, error) {
 	}
 
 	var id int
-	createListQuery := fmt.Sprintf("INSERT INTO %s (title, description) VALUES ($1, $2) RETURNING id", todoListsTable)
-	row := r.db.QueryRow(createListQuery, list.Title, list.Description)
+	query := fmt.Sprintf(`INSERT INTO %s (title, description, workspace_id, complete_subtasks, auto_complete_parent)
+	SELECT $1, $2, wm.workspace_id, $3, $4 FROM %s wm
+	WHERE wm.workspace_id = $5 AND wm.user_id = $6 AND wm.role IN ('%s', '%s')
+	RETURNING id`, todoListsTable, workspaceMembersTable, todo.ListRoleOwner, todo.ListRoleEditor)
+	row := tx.QueryRow(query, list.Title, list.Description, list.CompleteSubtasks, list.AutoCompleteParent,
+		list.WorkspaceId, userId)
 	if err := row.Scan(&id); err != nil {
 		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, workspaceAccessError(r.db, userId, list.WorkspaceId)
+		}
 		return 0, err
 	}
 
-	createUserListsQuery := fmt.Sprintf("INSERT INTO %s (user_id, list_id) VALUES ($1, $2) ", usersListsTable)
-	_, err = tx.Exec(createUserListsQuery, userId, id)
-	if err != nil {
+	if err := lockUserLists(tx, userId); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := appendListPosition(tx, userId, id); err != nil {
 		tx.Rollback()
 		return 0, err
 	}
-
 	return id, tx.Commit()
 }
+
+// Move changes the position of the list among the lists of the user, which
+// does not affect the order other members see.
+func (r *TodoListPostgres) Move(userId, listId int, input todo.MoveInput) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	var role string
+	roleQuery := fmt.Sprintf("SELECT role FROM %s WHERE user_id = $1 AND list_id = $2", listAccessView)
+	if err := tx.Get(&role, roleQuery, userId, listId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := lockUserLists(tx.Tx, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// forget the positions of lists the user lost access to
+	staleQuery := fmt.Sprintf(`DELETE FROM %s lp WHERE lp.user_id = $1
+	AND NOT EXISTS (SELECT 1 FROM %s ul WHERE ul.user_id = lp.user_id AND ul.list_id = lp.list_id)`,
+		listPositionsTable, listAccessView)
+	if _, err := tx.Exec(staleQuery, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// lists shared with the user have no position yet, they get one in the
+	// order they are shown in
+	var unplaced []int
+	unplacedQuery := fmt.Sprintf(`SELECT ul.list_id FROM %s ul
+	         LEFT JOIN %s lp ON lp.list_id = ul.list_id AND lp.user_id = ul.user_id
+	WHERE ul.user_id = $1 AND lp.list_id IS NULL
+	ORDER BY ul.list_id`, listAccessView, listPositionsTable)
+	if err := tx.Select(&unplaced, unplacedQuery, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, id := range unplaced {
+		if err := appendListPosition(tx.Tx, userId, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	listPosition, err := movePosition(tx.Tx, userListPositions, userId, listId, input)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	query := fmt.Sprintf("UPDATE %s SET position = $1 WHERE user_id = $2 AND list_id = $3", listPositionsTable)
+	if _, err := tx.Exec(query, listPosition, userId, listId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockUserLists serializes changes to the order of the lists of the user.
+func lockUserLists(tx *sql.Tx, userId int) error {
+	query := fmt.Sprintf("SELECT id FROM %s WHERE id = $1 FOR NO KEY UPDATE", usersTable)
+	return tx.QueryRow(query, userId).Scan(&userId)
+}
+
+func appendListPosition(tx *sql.Tx, userId, listId int) error {
+	listPosition, err := lastPosition(tx, userListPositions, userId)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("INSERT INTO %s (user_id, list_id, position) VALUES ($1, $2, $3)", listPositionsTable)
+	_, err = tx.Exec(query, userId, listId, listPosition)
+	return err
+}
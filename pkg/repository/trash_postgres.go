@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+type TrashPostgres struct {
+	db *sqlx.DB
+}
+
+func NewTrashPostgres(db *sqlx.DB) *TrashPostgres {
+	return &TrashPostgres{db: db}
+}
+
+// trashedItemRoot matches items deleted on their own rather than along with
+// their parent, the parent of ti is aliased p.
+const trashedItemRoot = "ti.deleted_at IS NOT NULL AND (p.deleted_at IS NULL OR p.deleted_at <> ti.deleted_at)"
+
+// GetAll returns the lists in the trash the user owns and the items in the
+// trash of live lists they can edit.
+func (r *TrashPostgres) GetAll(userId int) (todo.Trash, error) {
+	trash := todo.Trash{Lists: make([]todo.TrashedList, 0), Items: make([]todo.TrashedItem, 0)}
+	listsQuery := fmt.Sprintf(`SELECT tl.id, tl.title, tl.workspace_id, tl.deleted_at
+	FROM %s tl
+	         JOIN %s ul ON ul.list_id = tl.id
+	WHERE ul.user_id = $1 AND tl.deleted_at IS NOT NULL AND %s
+	ORDER BY tl.deleted_at DESC, tl.id`, todoListsTable, allListAccessView, canManageList)
+	if err := r.db.Select(&trash.Lists, listsQuery, userId); err != nil {
+		return trash, err
+	}
+
+	itemsQuery := fmt.Sprintf(`SELECT ti.id, ti.title, li.list_id, tl.title AS list_title, ti.parent_id, ti.deleted_at
+	FROM %[1]s ti
+	         JOIN %[2]s li ON li.item_id = ti.id
+	         JOIN %[3]s tl ON tl.id = li.list_id
+	         JOIN %[4]s ul ON ul.list_id = li.list_id
+	         LEFT JOIN %[1]s p ON p.id = ti.parent_id
+	WHERE ul.user_id = $1 AND %[5]s AND %[6]s
+	ORDER BY ti.deleted_at DESC, ti.id`,
+		todoItemsTable, listsItemsTable, todoListsTable, listAccessView, trashedItemRoot, canEditList)
+	err := r.db.Select(&trash.Items, itemsQuery, userId)
+	return trash, err
+}
+
+// RestoreList takes a list the user owns out of the trash together with the
+// items that went with it. Items deleted on their own before stay in the
+// trash.
+func (r *TrashPostgres) RestoreList(userId, listId int) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		query := fmt.Sprintf(`UPDATE %s tl SET deleted_at = NULL
+		FROM %s ul
+		WHERE tl.id = ul.list_id AND ul.user_id = $1 AND tl.id = $2 AND tl.deleted_at IS NOT NULL AND %s`,
+			todoListsTable, allListAccessView, canManageList)
+		res, err := tx.Exec(query, userId, listId)
+		if err != nil {
+			return err
+		}
+		if err := checkAffected(res); err != nil {
+			return trashedListAccessError(r.db, userId, listId)
+		}
+
+		// lists created in the meantime may have taken the old place, the
+		// list is then placed again the next time the user orders their lists
+		positionsQuery := fmt.Sprintf(`DELETE FROM %[1]s lp WHERE lp.list_id = $1
+		AND EXISTS (SELECT 1 FROM %[1]s o WHERE o.user_id = lp.user_id AND o.list_id <> lp.list_id
+		                                    AND o.position = lp.position)`, listPositionsTable)
+		_, err = tx.Exec(positionsQuery, listId)
+		return err
+	})
+}
+
+// RestoreItem takes an item out of the trash of a list the user can edit,
+// together with the subtasks deleted along with it. An item whose parent is
+// still in the trash comes back at the top level.
+func (r *TrashPostgres) RestoreItem(userId, itemId int) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		listId, deletedAt, err := r.trashedItem(tx, userId, itemId)
+		if err != nil {
+			return err
+		}
+		if err := lockList(tx.Tx, listId); err != nil {
+			return err
+		}
+
+		var restored []int
+		query := fmt.Sprintf(`WITH RECURSIVE subtree (id) AS (
+			SELECT id FROM %[1]s WHERE id = $1
+			UNION ALL
+			SELECT ti.id FROM %[1]s ti JOIN subtree s ON ti.parent_id = s.id WHERE ti.deleted_at = $2
+		)
+		UPDATE %[1]s SET deleted_at = NULL WHERE id IN (SELECT id FROM subtree) RETURNING id`, todoItemsTable)
+		if err := tx.Select(&restored, query, itemId, deletedAt); err != nil {
+			return err
+		}
+
+		var parentId *int
+		parentQuery := fmt.Sprintf(`UPDATE %[1]s ti SET parent_id = NULL FROM %[1]s p
+		WHERE ti.id = $1 AND p.id = ti.parent_id AND p.deleted_at IS NOT NULL`, todoItemsTable)
+		if _, err := tx.Exec(parentQuery, itemId); err != nil {
+			return err
+		}
+		if err := tx.Get(&parentId, fmt.Sprintf("SELECT parent_id FROM %s WHERE id = $1", todoItemsTable), itemId); err != nil {
+			return err
+		}
+
+		if err := replaceTakenPositions(tx, listId, restored); err != nil {
+			return err
+		}
+
+		if parentId != nil {
+			_, autoCompleteParent, err := completionSettings(tx.Tx, *parentId)
+			if err != nil {
+				return err
+			}
+			if autoCompleteParent {
+				return updateParents(tx.Tx, parentId)
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteList removes a list the user owns from the trash for good, with
+// all of its items.
+func (r *TrashPostgres) DeleteList(userId, listId int) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		query := fmt.Sprintf(`SELECT tl.id FROM %s tl
+		         JOIN %s ul ON ul.list_id = tl.id
+		WHERE ul.user_id = $1 AND tl.id = $2 AND tl.deleted_at IS NOT NULL AND %s
+		FOR UPDATE OF tl`, todoListsTable, allListAccessView, canManageList)
+		if err := tx.QueryRow(query, userId, listId).Scan(&listId); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return trashedListAccessError(r.db, userId, listId)
+			}
+			return err
+		}
+
+		itemsQuery := fmt.Sprintf(`DELETE FROM %s ti USING %s li WHERE ti.id = li.item_id AND li.list_id = $1`,
+			todoItemsTable, listsItemsTable)
+		if _, err := tx.Exec(itemsQuery, listId); err != nil {
+			return err
+		}
+		_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = $1", todoListsTable), listId)
+		return err
+	})
+}
+
+// DeleteItem removes an item in the trash for good, its subtasks go with
+// the on delete cascade.
+func (r *TrashPostgres) DeleteItem(userId, itemId int) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		if _, _, err := r.trashedItem(tx, userId, itemId); err != nil {
+			return err
+		}
+		_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = $1", todoItemsTable), itemId)
+		return err
+	})
+}
+
+// Purge removes everything that went to the trash before the given time.
+func (r *TrashPostgres) Purge(before time.Time) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		itemsQuery := fmt.Sprintf(`DELETE FROM %s ti USING %s li, %s tl
+		WHERE ti.id = li.item_id AND tl.id = li.list_id AND tl.deleted_at < $1`,
+			todoItemsTable, listsItemsTable, todoListsTable)
+		if _, err := tx.Exec(itemsQuery, before); err != nil {
+			return err
+		}
+		listsQuery := fmt.Sprintf("DELETE FROM %s WHERE deleted_at < $1", todoListsTable)
+		if _, err := tx.Exec(listsQuery, before); err != nil {
+			return err
+		}
+		_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE deleted_at < $1", todoItemsTable), before)
+		return err
+	})
+}
+
+// trashedItem returns the list of an item in the trash and when it was
+// deleted, if the user can edit the list.
+func (r *TrashPostgres) trashedItem(tx *sqlx.Tx, userId, itemId int) (int, time.Time, error) {
+	var listId int
+	var deletedAt time.Time
+	query := fmt.Sprintf(`SELECT li.list_id, ti.deleted_at FROM %s ti
+	         JOIN %s li ON li.item_id = ti.id
+	         JOIN %s ul ON ul.list_id = li.list_id
+	WHERE ti.id = $1 AND ul.user_id = $2 AND ti.deleted_at IS NOT NULL AND %s`,
+		todoItemsTable, listsItemsTable, listAccessView, canEditList)
+	if err := tx.QueryRow(query, itemId, userId).Scan(&listId, &deletedAt); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, deletedAt, err
+		}
+		listQuery := fmt.Sprintf(`SELECT li.list_id FROM %s li JOIN %s ti ON ti.id = li.item_id
+		WHERE li.item_id = $1 AND ti.deleted_at IS NOT NULL`, listsItemsTable, todoItemsTable)
+		if err := r.db.Get(&listId, listQuery, itemId); err != nil {
+			return 0, deletedAt, err
+		}
+		return 0, deletedAt, listAccessError(r.db, userId, listId)
+	}
+	return listId, deletedAt, nil
+}
+
+// replaceTakenPositions moves restored items whose position was taken by
+// an item added in the meantime to the end of the list.
+func replaceTakenPositions(tx *sqlx.Tx, listId int, restored []int) error {
+	var taken []int
+	query := fmt.Sprintf(`SELECT r.id FROM %[1]s r
+	WHERE r.id = ANY($2) AND EXISTS (
+		SELECT 1 FROM %[1]s o JOIN %[2]s lo ON lo.item_id = o.id
+		WHERE lo.list_id = $1 AND o.position = r.position AND o.deleted_at IS NULL AND NOT o.id = ANY($2))
+	ORDER BY r.position, r.id`, todoItemsTable, listsItemsTable)
+	if err := tx.Select(&taken, query, listId, pq.Array(restored)); err != nil {
+		return err
+	}
+	for _, id := range taken {
+		itemPosition, err := lastPosition(tx.Tx, listItemPositions, listId)
+		if err != nil {
+			return err
+		}
+		query := fmt.Sprintf("UPDATE %s SET position = $1 WHERE id = $2", todoItemsTable)
+		if _, err := tx.Exec(query, itemPosition, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trashedListAccessError is listAccessError for a list in the trash.
+func trashedListAccessError(db *sqlx.DB, userId, listId int) error {
+	var role string
+	query := fmt.Sprintf(`SELECT ul.role FROM %s ul JOIN %s tl ON tl.id = ul.list_id
+	WHERE ul.user_id = $1 AND ul.list_id = $2 AND tl.deleted_at IS NOT NULL`, allListAccessView, todoListsTable)
+	if err := db.Get(&role, query, userId, listId); err != nil {
+		return err
+	}
+	return ErrInsufficientRole
+}
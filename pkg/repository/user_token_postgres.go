@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type UserTokenPostgres struct {
+	db *sqlx.DB
+}
+
+func NewUserTokenPostgres(db *sqlx.DB) *UserTokenPostgres {
+	return &UserTokenPostgres{db: db}
+}
+
+func (r *UserTokenPostgres) Create(jti string, userId int, purpose string, expiresAt time.Time) error {
+	query := fmt.Sprintf("INSERT INTO %s (jti, user_id, purpose, expires_at) VALUES ($1, $2, $3, $4)", userTokensTable)
+	_, err := r.db.Exec(query, jti, userId, purpose, expiresAt)
+	return err
+}
+
+// Use marks the token as used. It fails with sql.ErrNoRows when the token is
+// unknown, expired, issued for another purpose or was used before.
+func (r *UserTokenPostgres) Use(jti string, purpose string) (int, error) {
+	var userId int
+	query := fmt.Sprintf(`UPDATE %s SET used_at = now()
+	WHERE jti = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+	RETURNING user_id`, userTokensTable)
+	err := r.db.Get(&userId, query, jti, purpose)
+	return userId, err
+}
+
+func (r *UserTokenPostgres) IsActive(jti string, purpose string) (bool, error) {
+	var active bool
+	query := fmt.Sprintf(`SELECT EXISTS(SELECT 1 FROM %s
+	WHERE jti = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now())`, userTokensTable)
+	err := r.db.Get(&active, query, jti, purpose)
+	return active, err
+}
+
+// InvalidateAll marks every unused token of the user for purpose as used,
+// so only the most recently sent link keeps working.
+func (r *UserTokenPostgres) InvalidateAll(userId int, purpose string) error {
+	query := fmt.Sprintf("UPDATE %s SET used_at = now() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL",
+		userTokensTable)
+	_, err := r.db.Exec(query, userId, purpose)
+	return err
+}
+
+// RecordFailure counts a failed attempt to use the token, for tokens that
+// guard a second factor, and returns the number of failures so far.
+func (r *UserTokenPostgres) RecordFailure(jti string) (int, error) {
+	var attempts int
+	query := fmt.Sprintf("UPDATE %s SET failed_attempts = failed_attempts + 1 WHERE jti = $1 RETURNING failed_attempts",
+		userTokensTable)
+	err := r.db.Get(&attempts, query, jti)
+	return attempts, err
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/jmoiron/sqlx"
+)
+
+type WorkspacePostgres struct {
+	db *sqlx.DB
+}
+
+func NewWorkspacePostgres(db *sqlx.DB) *WorkspacePostgres {
+	return &WorkspacePostgres{db: db}
+}
+
+// workspaceSelect reads workspaces together with the role of the member
+// aliased wm.
+var workspaceSelect = fmt.Sprintf(`SELECT w.id, w.name, w.personal_user_id IS NOT NULL AS personal, wm.role, w.created_at
+FROM %s w
+         JOIN %s wm ON wm.workspace_id = w.id`, workspacesTable, workspaceMembersTable)
+
+func (r *WorkspacePostgres) Create(userId int, name string) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := createWorkspace(tx, userId, name, false)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// createWorkspace adds a workspace with the user as its owner. Every user
+// gets a personal one when the account is created.
+func createWorkspace(tx *sql.Tx, userId int, name string, personal bool) (int, error) {
+	var personalUserId *int
+	if personal {
+		personalUserId = &userId
+	}
+
+	var id int
+	workspaceQuery := fmt.Sprintf("INSERT INTO %s (name, personal_user_id) VALUES ($1, $2) RETURNING id", workspacesTable)
+	if err := tx.QueryRow(workspaceQuery, name, personalUserId).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	memberQuery := fmt.Sprintf("INSERT INTO %s (workspace_id, user_id, role) VALUES ($1, $2, $3)", workspaceMembersTable)
+	if _, err := tx.Exec(memberQuery, id, userId, todo.ListRoleOwner); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func createPersonalWorkspace(tx *sql.Tx, userId int) error {
+	_, err := createWorkspace(tx, userId, "Personal", true)
+	return err
+}
+
+func (r *WorkspacePostgres) GetAll(userId int) ([]todo.Workspace, error) {
+	var workspaces []todo.Workspace
+	query := workspaceSelect + " WHERE wm.user_id = $1 ORDER BY personal DESC, w.id"
+	err := r.db.Select(&workspaces, query, userId)
+	return workspaces, err
+}
+
+func (r *WorkspacePostgres) GetById(userId, id int) (todo.Workspace, error) {
+	var workspace todo.Workspace
+	query := workspaceSelect + " WHERE wm.user_id = $1 AND w.id = $2"
+	err := r.db.Get(&workspace, query, userId, id)
+	return workspace, err
+}
+
+func (r *WorkspacePostgres) GetPersonalId(userId int) (int, error) {
+	var id int
+	query := fmt.Sprintf("SELECT id FROM %s WHERE personal_user_id = $1", workspacesTable)
+	err := r.db.Get(&id, query, userId)
+	return id, err
+}
+
+func (r *WorkspacePostgres) Update(id int, name string) error {
+	query := fmt.Sprintf("UPDATE %s SET name = $1 WHERE id = $2", workspacesTable)
+	res, err := r.db.Exec(query, name, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// Delete removes the workspace with all of its lists and their items.
+func (r *WorkspacePostgres) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := deleteWorkspaceItems(tx, "$1", id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", workspacesTable)
+	res, err := tx.Exec(query, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteWorkspaceItems removes the items of every list in the workspaces
+// matched by workspaceIds, a placeholder or a subquery. The lists themselves
+// go with the on delete cascade of their workspace, items are only linked to
+// them.
+func deleteWorkspaceItems(tx *sql.Tx, workspaceIds string, args ...interface{}) error {
+	query := fmt.Sprintf(`DELETE FROM %s ti USING %s li, %s tl
+	WHERE ti.id = li.item_id AND tl.id = li.list_id AND tl.workspace_id IN (%s)`,
+		todoItemsTable, listsItemsTable, todoListsTable, workspaceIds)
+	_, err := tx.Exec(query, args...)
+	return err
+}
+
+func (r *WorkspacePostgres) GetMembers(id int) ([]todo.WorkspaceMember, error) {
+	var members []todo.WorkspaceMember
+	query := fmt.Sprintf(`SELECT u.id AS user_id, u.username, u.name, wm.role
+	FROM %s wm
+	         JOIN %s u ON u.id = wm.user_id
+	WHERE wm.workspace_id = $1
+	ORDER BY wm.id`, workspaceMembersTable, usersTable)
+	err := r.db.Select(&members, query, id)
+	return members, err
+}
+
+func (r *WorkspacePostgres) AddMember(id, userId int, role string) error {
+	query := fmt.Sprintf("INSERT INTO %s (workspace_id, user_id, role) VALUES ($1, $2, $3)", workspaceMembersTable)
+	_, err := r.db.Exec(query, id, userId, role)
+	return err
+}
+
+func (r *WorkspacePostgres) UpdateMemberRole(id, userId int, role string) error {
+	return r.changeMember(id, userId, role == todo.ListRoleOwner, func(tx *sql.Tx) (sql.Result, error) {
+		query := fmt.Sprintf("UPDATE %s SET role = $1 WHERE workspace_id = $2 AND user_id = $3", workspaceMembersTable)
+		return tx.Exec(query, role, id, userId)
+	})
+}
+
+// RemoveMember takes the user out of the workspace, they lose access to all
+// of its lists at once.
+func (r *WorkspacePostgres) RemoveMember(id, userId int) error {
+	return r.changeMember(id, userId, false, func(tx *sql.Tx) (sql.Result, error) {
+		query := fmt.Sprintf("DELETE FROM %s WHERE workspace_id = $1 AND user_id = $2", workspaceMembersTable)
+		return tx.Exec(query, id, userId)
+	})
+}
+
+// changeMember runs change with the workspace locked. It fails with
+// ErrLastOwner if the user is the only owner and does not stay one.
+func (r *WorkspacePostgres) changeMember(id, userId int, staysOwner bool, change func(tx *sql.Tx) (sql.Result, error)) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	lockQuery := fmt.Sprintf("SELECT id FROM %s WHERE id = $1 FOR UPDATE", workspacesTable)
+	if err := tx.QueryRow(lockQuery, id).Scan(&id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var owners int
+	var isOwner bool
+	ownersQuery := fmt.Sprintf(`SELECT count(*), coalesce(bool_or(user_id = $2), false) FROM %s
+	WHERE workspace_id = $1 AND role = '%s'`, workspaceMembersTable, todo.ListRoleOwner)
+	if err := tx.QueryRow(ownersQuery, id, userId).Scan(&owners, &isOwner); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if isOwner && owners == 1 && !staysOwner {
+		tx.Rollback()
+		return ErrLastOwner
+	}
+
+	res, err := change(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// workspaceAccessError is listAccessError for workspaces.
+func workspaceAccessError(db *sqlx.DB, userId, workspaceId int) error {
+	var role string
+	query := fmt.Sprintf("SELECT role FROM %s WHERE user_id = $1 AND workspace_id = $2", workspaceMembersTable)
+	if err := db.Get(&role, query, userId, workspaceId); err != nil {
+		return err
+	}
+	return ErrInsufficientRole
+}
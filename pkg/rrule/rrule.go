@@ -0,0 +1,355 @@
+// Package rrule implements the part of RFC 5545 recurrence rules that items
+// use: FREQ (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL, BYDAY, UNTIL and
+// COUNT. Weeks start on Monday.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencyNames = map[Frequency]string{
+	Daily:   "DAILY",
+	Weekly:  "WEEKLY",
+	Monthly: "MONTHLY",
+	Yearly:  "YEARLY",
+}
+
+var weekdayNames = map[time.Weekday]string{
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+	time.Sunday:    "SU",
+}
+
+// Day is a BYDAY entry. N picks the nth such weekday of the month or year,
+// counting from the end when negative, 0 means every one of them.
+type Day struct {
+	N       int
+	Weekday time.Weekday
+}
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []Day
+	// Until is inclusive. Without UntilTime only its date counts, in the
+	// time zone the occurrences are computed in.
+	Until     *time.Time
+	UntilTime bool
+	Count     int
+}
+
+var ErrInvalid = errors.New("invalid recurrence rule")
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", with or
+// without the "RRULE:" prefix.
+func Parse(value string) (Rule, error) {
+	rule := Rule{Interval: 1}
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+
+	var hasFreq bool
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return rule, invalid("%q is not a NAME=VALUE pair", part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			freq, ok := parseFrequency(strings.ToUpper(val))
+			if !ok {
+				return rule, invalid("FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY")
+			}
+			rule.Freq, hasFreq = freq, true
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil || interval < 1 {
+				return rule, invalid("INTERVAL must be a positive number")
+			}
+			rule.Interval = interval
+		case "BYDAY":
+			for _, day := range strings.Split(strings.ToUpper(val), ",") {
+				parsed, err := parseDay(day)
+				if err != nil {
+					return rule, err
+				}
+				rule.ByDay = append(rule.ByDay, parsed)
+			}
+		case "UNTIL":
+			until, withTime, err := parseUntil(strings.ToUpper(val))
+			if err != nil {
+				return rule, err
+			}
+			rule.Until, rule.UntilTime = &until, withTime
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil || count < 1 {
+				return rule, invalid("COUNT must be a positive number")
+			}
+			rule.Count = count
+		default:
+			return rule, invalid("%s is not supported", key)
+		}
+	}
+
+	if !hasFreq {
+		return rule, invalid("FREQ is required")
+	}
+	if rule.Until != nil && rule.Count > 0 {
+		return rule, invalid("UNTIL and COUNT cannot be combined")
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return rule, invalid("numbered BYDAY entries need FREQ=MONTHLY or FREQ=YEARLY")
+		}
+	}
+	return rule, nil
+}
+
+func parseFrequency(value string) (Frequency, bool) {
+	for freq, name := range frequencyNames {
+		if name == value {
+			return freq, true
+		}
+	}
+	return 0, false
+}
+
+func parseDay(value string) (Day, error) {
+	if len(value) < 2 {
+		return Day{}, invalid("%q is not a weekday", value)
+	}
+	var day Day
+	found := false
+	for weekday, name := range weekdayNames {
+		if strings.HasSuffix(value, name) {
+			day.Weekday, found = weekday, true
+		}
+	}
+	if !found {
+		return Day{}, invalid("%q is not a weekday", value)
+	}
+	if prefix := value[:len(value)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return Day{}, invalid("%q has an invalid number", value)
+		}
+		day.N = n
+	}
+	return day, nil
+}
+
+func parseUntil(value string) (time.Time, bool, error) {
+	if until, err := time.Parse("20060102T150405Z", value); err == nil {
+		return until, true, nil
+	}
+	if until, err := time.Parse("20060102", value); err == nil {
+		return until, false, nil
+	}
+	return time.Time{}, false, invalid("UNTIL must look like 20261231 or 20261231T235959Z")
+}
+
+// String formats the rule the way Parse reads it, without the prefix.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + frequencyNames[r.Freq]}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = weekdayNames[day.Weekday]
+			if day.N != 0 {
+				days[i] = strconv.Itoa(day.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Until != nil {
+		if r.UntilTime {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+		}
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+// maxPeriods bounds the search for the next occurrence, rules like
+// FREQ=MONTHLY;BYDAY=5MO skip months without a match.
+const maxPeriods = 1000
+
+// Next returns the occurrence after current, which is the index-th
+// occurrence of the series counting from 1. Occurrences keep the time of
+// day of current in its location. It returns false once the series is over.
+func (r Rule) Next(current time.Time, index int) (time.Time, bool) {
+	if r.Count > 0 && index >= r.Count {
+		return time.Time{}, false
+	}
+
+	period := r.periodStart(current)
+	for i := 0; i < maxPeriods; i++ {
+		for _, candidate := range r.occurrences(period, current) {
+			if !candidate.After(current) {
+				continue
+			}
+			if r.afterUntil(candidate) {
+				return time.Time{}, false
+			}
+			return candidate, true
+		}
+		period = r.advance(period)
+	}
+	return time.Time{}, false
+}
+
+func (r Rule) afterUntil(t time.Time) bool {
+	if r.Until == nil {
+		return false
+	}
+	if r.UntilTime {
+		return t.After(*r.Until)
+	}
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).After(*r.Until)
+}
+
+// periodStart is the first day of the day, week, month or year of t.
+func (r Rule) periodStart(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch r.Freq {
+	case Weekly:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location())
+	case Monthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	case Yearly:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func (r Rule) advance(period time.Time) time.Time {
+	switch r.Freq {
+	case Weekly:
+		return period.AddDate(0, 0, 7*r.Interval)
+	case Monthly:
+		return period.AddDate(0, r.Interval, 0)
+	case Yearly:
+		return period.AddDate(r.Interval, 0, 0)
+	}
+	return period.AddDate(0, 0, r.Interval)
+}
+
+// occurrences lists the occurrences within the period in order, at the time
+// of day of current.
+func (r Rule) occurrences(period, current time.Time) []time.Time {
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		if len(r.ByDay) == 0 || r.matchesWeekday(period.Weekday()) {
+			days = append(days, period)
+		}
+	case Weekly:
+		for i := 0; i < 7; i++ {
+			day := period.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && day.Weekday() == current.Weekday() || r.matchesWeekday(day.Weekday()) {
+				days = append(days, day)
+			}
+		}
+	case Monthly:
+		end := period.AddDate(0, 1, 0)
+		if len(r.ByDay) == 0 {
+			days = sameDate(days, period.Year(), period.Month(), current.Day(), period.Location())
+		} else {
+			days = r.weekdaysBetween(period, end)
+		}
+	case Yearly:
+		end := period.AddDate(1, 0, 0)
+		if len(r.ByDay) == 0 {
+			days = sameDate(days, period.Year(), current.Month(), current.Day(), period.Location())
+		} else {
+			days = r.weekdaysBetween(period, end)
+		}
+	}
+
+	occurrences := make([]time.Time, len(days))
+	for i, day := range days {
+		occurrences[i] = time.Date(day.Year(), day.Month(), day.Day(),
+			current.Hour(), current.Minute(), current.Second(), current.Nanosecond(), current.Location())
+	}
+	return occurrences
+}
+
+// sameDate appends the date unless it does not exist, like February 30th.
+func sameDate(days []time.Time, year int, month time.Month, day int, loc *time.Location) []time.Time {
+	date := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	if date.Month() != month {
+		return days
+	}
+	return append(days, date)
+}
+
+func (r Rule) matchesWeekday(weekday time.Weekday) bool {
+	for _, day := range r.ByDay {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// weekdaysBetween expands BYDAY within [start, end), numbered entries count
+// within that range.
+func (r Rule) weekdaysBetween(start, end time.Time) []time.Time {
+	byWeekday := make(map[time.Weekday][]time.Time)
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		byWeekday[day.Weekday()] = append(byWeekday[day.Weekday()], day)
+	}
+
+	seen := make(map[time.Time]bool)
+	var days []time.Time
+	for _, entry := range r.ByDay {
+		matches := byWeekday[entry.Weekday]
+		switch {
+		case entry.N == 0:
+		case entry.N > 0 && entry.N <= len(matches):
+			matches = matches[entry.N-1 : entry.N]
+		case entry.N < 0 && -entry.N <= len(matches):
+			matches = matches[len(matches)+entry.N : len(matches)+entry.N+1]
+		default:
+			matches = nil
+		}
+		for _, day := range matches {
+			if !seen[day] {
+				seen[day] = true
+				days = append(days, day)
+			}
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
@@ -0,0 +1,247 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestNext(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	newYork := mustLoad(t, "America/New_York")
+	at := func(loc *time.Location, year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		// index is the occurrence start is, counting from 1
+		index int
+		want  []time.Time
+		// ends is set when the series is over after want
+		ends bool
+	}{
+		{
+			name:  "last friday of the month",
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR",
+			start: at(time.UTC, 2026, time.January, 30, 9, 0),
+			want: []time.Time{
+				at(time.UTC, 2026, time.February, 27, 9, 0),
+				at(time.UTC, 2026, time.March, 27, 9, 0),
+				at(time.UTC, 2026, time.April, 24, 9, 0),
+			},
+		},
+		{
+			name:  "second tuesday and last monday",
+			rule:  "FREQ=MONTHLY;BYDAY=2TU,-1MO",
+			start: at(time.UTC, 2026, time.January, 13, 18, 0),
+			want: []time.Time{
+				at(time.UTC, 2026, time.January, 26, 18, 0),
+				at(time.UTC, 2026, time.February, 10, 18, 0),
+				at(time.UTC, 2026, time.February, 23, 18, 0),
+			},
+		},
+		{
+			name:  "monthly on the 30th skips february",
+			rule:  "FREQ=MONTHLY",
+			start: at(time.UTC, 2026, time.January, 30, 10, 0),
+			want: []time.Time{
+				at(time.UTC, 2026, time.March, 30, 10, 0),
+				at(time.UTC, 2026, time.April, 30, 10, 0),
+			},
+		},
+		{
+			name:  "monthly on the 31st skips short months",
+			rule:  "FREQ=MONTHLY",
+			start: at(time.UTC, 2026, time.March, 31, 10, 0),
+			want: []time.Time{
+				at(time.UTC, 2026, time.May, 31, 10, 0),
+				at(time.UTC, 2026, time.July, 31, 10, 0),
+				at(time.UTC, 2026, time.August, 31, 10, 0),
+			},
+		},
+		{
+			name:  "yearly on february 29th waits for leap years",
+			rule:  "FREQ=YEARLY",
+			start: at(time.UTC, 2024, time.February, 29, 0, 0),
+			want: []time.Time{
+				at(time.UTC, 2028, time.February, 29, 0, 0),
+			},
+		},
+		{
+			name:  "date-only until counts the local date",
+			rule:  "FREQ=DAILY;UNTIL=20260105",
+			start: at(newYork, 2026, time.January, 3, 23, 30),
+			want: []time.Time{
+				at(newYork, 2026, time.January, 4, 23, 30),
+				at(newYork, 2026, time.January, 5, 23, 30),
+			},
+			ends: true,
+		},
+		{
+			name:  "until with a time is an instant",
+			rule:  "FREQ=DAILY;UNTIL=20260105T120000Z",
+			start: at(berlin, 2026, time.January, 3, 13, 30),
+			want: []time.Time{
+				at(berlin, 2026, time.January, 4, 13, 30),
+			},
+			ends: true,
+		},
+		{
+			name:  "count includes the first occurrence",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: at(time.UTC, 2026, time.January, 1, 8, 0),
+			index: 1,
+			want: []time.Time{
+				at(time.UTC, 2026, time.January, 2, 8, 0),
+				at(time.UTC, 2026, time.January, 3, 8, 0),
+			},
+			ends: true,
+		},
+		{
+			name:  "count already used up",
+			rule:  "FREQ=WEEKLY;COUNT=2",
+			start: at(time.UTC, 2026, time.January, 1, 8, 0),
+			index: 2,
+			ends:  true,
+		},
+		{
+			name:  "every other week on monday and thursday",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH",
+			start: at(time.UTC, 2026, time.January, 5, 8, 0),
+			want: []time.Time{
+				at(time.UTC, 2026, time.January, 8, 8, 0),
+				at(time.UTC, 2026, time.January, 19, 8, 0),
+				at(time.UTC, 2026, time.January, 22, 8, 0),
+				at(time.UTC, 2026, time.February, 2, 8, 0),
+			},
+		},
+		{
+			name:  "every third week on the weekday of the start",
+			rule:  "FREQ=WEEKLY;INTERVAL=3",
+			start: at(time.UTC, 2026, time.January, 7, 8, 0),
+			want: []time.Time{
+				at(time.UTC, 2026, time.January, 28, 8, 0),
+				at(time.UTC, 2026, time.February, 18, 8, 0),
+			},
+		},
+		{
+			name:  "weekly starting on sunday belongs to the week of monday",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,SU",
+			start: at(time.UTC, 2026, time.January, 11, 8, 0),
+			want: []time.Time{
+				at(time.UTC, 2026, time.January, 19, 8, 0),
+				at(time.UTC, 2026, time.January, 25, 8, 0),
+				at(time.UTC, 2026, time.February, 2, 8, 0),
+			},
+		},
+		{
+			name:  "weekdays only",
+			rule:  "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+			start: at(time.UTC, 2026, time.January, 9, 8, 0),
+			want: []time.Time{
+				at(time.UTC, 2026, time.January, 12, 8, 0),
+				at(time.UTC, 2026, time.January, 13, 8, 0),
+			},
+		},
+		{
+			name:  "time of day is kept across daylight saving",
+			rule:  "FREQ=DAILY",
+			start: at(berlin, 2026, time.March, 28, 9, 0),
+			want: []time.Time{
+				at(berlin, 2026, time.March, 29, 9, 0),
+				at(berlin, 2026, time.March, 30, 9, 0),
+			},
+		},
+		{
+			name:  "fifth monday skips months without one",
+			rule:  "FREQ=MONTHLY;BYDAY=5MO",
+			start: at(time.UTC, 2026, time.March, 30, 8, 0),
+			want: []time.Time{
+				at(time.UTC, 2026, time.June, 29, 8, 0),
+				at(time.UTC, 2026, time.August, 31, 8, 0),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+			current, index := tt.start, tt.index
+			if index == 0 {
+				index = 1
+			}
+			for i, want := range tt.want {
+				got, ok := rule.Next(current, index)
+				if !ok {
+					t.Fatalf("occurrence %d: series ended, want %s", i+1, want)
+				}
+				if !got.Equal(want) || got.Location() != want.Location() {
+					t.Fatalf("occurrence %d: got %s, want %s", i+1, got, want)
+				}
+				current, index = got, index+1
+			}
+			if got, ok := rule.Next(current, index); ok == tt.ends {
+				t.Errorf("after %s: got %s, %v, want the series to end: %v", current, got, ok, tt.ends)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value string
+		// want is the formatted rule, empty for invalid ones
+		want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:freq=weekly;byday=mo,th;interval=1", "FREQ=WEEKLY;BYDAY=MO,TH"},
+		{"FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR", "FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR"},
+		{"FREQ=YEARLY;UNTIL=20301231", "FREQ=YEARLY;UNTIL=20301231"},
+		{"FREQ=DAILY;UNTIL=20301231T235959Z;INTERVAL=3", "FREQ=DAILY;INTERVAL=3;UNTIL=20301231T235959Z"},
+		{"FREQ=WEEKLY;COUNT=10", "FREQ=WEEKLY;COUNT=10"},
+		{"", ""},
+		{"INTERVAL=2", ""},
+		{"FREQ=HOURLY", ""},
+		{"FREQ=DAILY;INTERVAL=0", ""},
+		{"FREQ=DAILY;COUNT=-1", ""},
+		{"FREQ=DAILY;COUNT=2;UNTIL=20301231", ""},
+		{"FREQ=WEEKLY;BYDAY=1MO", ""},
+		{"FREQ=MONTHLY;BYDAY=0MO", ""},
+		{"FREQ=MONTHLY;BYDAY=54MO", ""},
+		{"FREQ=MONTHLY;BYDAY=XX", ""},
+		{"FREQ=DAILY;UNTIL=2030-12-31", ""},
+		{"FREQ=DAILY;BYMONTH=1", ""},
+		{"FREQ=DAILY;COUNT", ""},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.value)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("Parse(%q) = %v, want ErrInvalid", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.value, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/mailer"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrEmailRequired    = errors.New("email is required")
+	ErrEmailNotVerified = errors.New("email address is not verified")
+)
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// SendVerificationEmail mails a fresh verification link to the address of
+// the user with the given email. Unknown and already verified addresses are
+// ignored without an error, so the endpoint does not reveal which addresses
+// are registered.
+func (s *AuthService) SendVerificationEmail(email string) error {
+	user, err := s.repo.GetUserByEmail(normalizeEmail(email))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	return s.sendVerificationEmail(user)
+}
+
+func (s *AuthService) sendVerificationEmail(user todo.User) error {
+	if user.Email == nil {
+		return nil
+	}
+	token, err := s.issueUserToken(user.Id, purposeVerifyEmail, *user.Email, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/auth/verify-email?token=%s", s.publicURL, url.QueryEscape(token))
+	return s.mailer.Send(mailer.Message{
+		To:      *user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nplease confirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link is valid for %d hours.\n", user.Name, link, int(verifyEmailTokenTTL.Hours())),
+	})
+}
+
+func (s *AuthService) VerifyEmail(token string) error {
+	userId, claims, err := s.useUserToken(token, purposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.MarkEmailVerified(userId, claims.Email); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// the address was changed after the link was sent
+			return ErrInvalidUserToken
+		}
+		return err
+	}
+	return nil
+}
+
+// RequestPasswordReset mails a reset token if an account with the given
+// email exists. Like SendVerificationEmail it does not report unknown
+// addresses.
+func (s *AuthService) RequestPasswordReset(email string) error {
+	user, err := s.repo.GetUserByEmail(normalizeEmail(email))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := s.issueUserToken(user.Id, purposeResetPassword, "", resetPasswordTokenTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(mailer.Message{
+		To:      *user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nsomeone asked to reset the password of your account. "+
+			"Use the token below to choose a new one:\n\n%s\n\n"+
+			"The token is valid for one hour. If you did not ask for this, you can ignore this email.\n",
+			user.Name, token),
+	})
+}
+
+// ResetPassword sets a new password and signs the user out everywhere.
+func (s *AuthService) ResetPassword(token, password string) error {
+	userId, _, err := s.useUserToken(token, purposeResetPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.rehashPassword(userId, password); err != nil {
+		return err
+	}
+	return s.revocations.revokeAll(userId)
+}
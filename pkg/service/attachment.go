@@ -0,0 +1,280 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/blob"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const purposeAttachmentDownload = "attachment_download"
+
+// sniffLength is how much of an upload is looked at to tell its type.
+const sniffLength = 512
+
+var (
+	ErrQuotaExceeded      = repository.ErrQuotaExceeded
+	ErrAttachmentTooLarge = errors.New("the file is larger than allowed")
+)
+
+// AttachmentConfig tunes files uploaded to items.
+type AttachmentConfig struct {
+	Store blob.BlobStore
+	// MaxSize is the largest file that can be uploaded, in bytes.
+	MaxSize int64
+	// Quota is how many bytes of files every user can upload, 0 for no
+	// limit.
+	Quota int64
+	// URLTTL is how long download URLs work.
+	URLTTL time.Duration
+	// SweepInterval is how often the files of deleted items are removed.
+	SweepInterval time.Duration
+}
+
+type AttachmentService struct {
+	repo       repository.Attachment
+	itemRepo   repository.TodoItem
+	memberRepo repository.ListMember
+	keys       *KeySet
+	publicURL  string
+	cfg        AttachmentConfig
+}
+
+func NewAttachmentService(repos *repository.Repository, cfg Config) *AttachmentService {
+	return &AttachmentService{
+		repo:       repos.Attachment,
+		itemRepo:   repos.TodoItem,
+		memberRepo: repos.ListMember,
+		keys:       cfg.Keys,
+		publicURL:  cfg.PublicURL,
+		cfg:        cfg.Attachments,
+	}
+}
+
+// MaxSize is the largest file that can be uploaded.
+func (s *AttachmentService) MaxSize() int64 {
+	return s.cfg.MaxSize
+}
+
+// Create stores a file of size bytes on an item, editors and owners of its
+// list can upload. The file counts against the quota of the uploader.
+func (s *AttachmentService) Create(ctx context.Context, userId, itemId int, filename string, size int64,
+	content io.Reader) (todo.Attachment, error) {
+	item, err := s.itemRepo.GetById(userId, itemId)
+	if err != nil {
+		return todo.Attachment{}, err
+	}
+	if err := requireListEditorRole(s.memberRepo, userId, item.ListId); err != nil {
+		return todo.Attachment{}, err
+	}
+	if size > s.cfg.MaxSize {
+		return todo.Attachment{}, ErrAttachmentTooLarge
+	}
+	// fail early, the exact check happens once the file is stored
+	if s.cfg.Quota > 0 {
+		used, err := s.repo.GetUsage(userId)
+		if err != nil {
+			return todo.Attachment{}, err
+		}
+		if used+size > s.cfg.Quota {
+			return todo.Attachment{}, ErrQuotaExceeded
+		}
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return todo.Attachment{}, err
+	}
+	head = head[:n]
+
+	key, err := randomToken(24)
+	if err != nil {
+		return todo.Attachment{}, err
+	}
+	attachment := todo.Attachment{
+		ItemId:      itemId,
+		UserId:      &userId,
+		Filename:    todo.CleanFilename(filename),
+		ContentType: http.DetectContentType(head),
+		Size:        size,
+		StorageKey:  fmt.Sprintf("items/%d/%s", itemId, key),
+	}
+	err = s.cfg.Store.Put(ctx, attachment.StorageKey, io.MultiReader(bytes.NewReader(head), content),
+		size, attachment.ContentType)
+	if err != nil {
+		return todo.Attachment{}, err
+	}
+
+	id, err := s.repo.Create(attachment, s.cfg.Quota)
+	if err != nil {
+		s.deleteBlob(attachment)
+		return todo.Attachment{}, err
+	}
+	return s.GetById(userId, id)
+}
+
+// GetAll returns the attachments of an item the user can read, with fresh
+// download URLs.
+func (s *AttachmentService) GetAll(userId, itemId int) ([]todo.Attachment, error) {
+	if _, err := s.itemRepo.GetById(userId, itemId); err != nil {
+		return nil, err
+	}
+	attachments, err := s.repo.GetAllByItem(itemId)
+	if err != nil {
+		return nil, err
+	}
+	for i := range attachments {
+		if err := s.addDownloadURL(&attachments[i]); err != nil {
+			return nil, err
+		}
+	}
+	return attachments, nil
+}
+
+func (s *AttachmentService) GetById(userId, id int) (todo.Attachment, error) {
+	attachment, err := s.repo.GetById(userId, id)
+	if err != nil {
+		return attachment, err
+	}
+	return attachment, s.addDownloadURL(&attachment)
+}
+
+// Delete removes an attachment and its file. Uploaders can delete their
+// own files, owners of the list any file.
+func (s *AttachmentService) Delete(userId, id int) error {
+	attachment, err := s.repo.GetById(userId, id)
+	if err != nil {
+		return err
+	}
+	if attachment.UserId == nil || *attachment.UserId != userId {
+		if err := requireListOwner(s.memberRepo, userId, attachment.ListId); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.deleteBlob(attachment)
+	return nil
+}
+
+// GetUsage returns how much of their quota the files of the user take up.
+func (s *AttachmentService) GetUsage(userId int) (todo.StorageUsage, error) {
+	used, err := s.repo.GetUsage(userId)
+	return todo.StorageUsage{Used: used, Quota: s.cfg.Quota}, err
+}
+
+// Open checks a download token and opens the file it grants access to.
+// The caller closes the content.
+func (s *AttachmentService) Open(ctx context.Context, token string) (todo.Attachment, io.ReadCloser, error) {
+	var claims userTokenClaims
+	if _, err := jwt.ParseWithClaims(token, &claims, s.keys.keyFunc); err != nil {
+		return todo.Attachment{}, nil, ErrInvalidUserToken
+	}
+	id, err := strconv.Atoi(claims.Id)
+	if claims.Purpose != purposeAttachmentDownload || err != nil {
+		return todo.Attachment{}, nil, ErrInvalidUserToken
+	}
+
+	attachment, err := s.repo.GetStored(id)
+	if err != nil {
+		return attachment, nil, err
+	}
+	content, err := s.cfg.Store.Get(ctx, attachment.StorageKey)
+	if errors.Is(err, blob.ErrNotFound) {
+		return attachment, nil, sql.ErrNoRows
+	}
+	return attachment, content, err
+}
+
+// addDownloadURL signs a URL that downloads the attachment without
+// authentication until it expires.
+func (s *AttachmentService) addDownloadURL(attachment *todo.Attachment) error {
+	expiresAt := time.Now().Add(s.cfg.URLTTL)
+	token, err := s.keys.sign(&userTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        strconv.Itoa(attachment.Id),
+			ExpiresAt: expiresAt.Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+		Purpose: purposeAttachmentDownload,
+	})
+	if err != nil {
+		return err
+	}
+	attachment.DownloadURL = s.publicURL + "/files/" + token
+	attachment.URLExpiresAt = &expiresAt
+	return nil
+}
+
+// deleteBlob removes the file of an attachment that is gone from the
+// database. A file left behind only takes up space, so failures are logged.
+func (s *AttachmentService) deleteBlob(attachment todo.Attachment) {
+	if err := s.cfg.Store.Delete(context.Background(), attachment.StorageKey); err != nil {
+		logrus.Errorf("failed to delete file %s: %s", attachment.StorageKey, err.Error())
+	}
+}
+
+// sweepBatch is how many files of deleted items are removed at once.
+const sweepBatch = 100
+
+// AttachmentSweeper removes the files of deleted items in the background.
+type AttachmentSweeper struct {
+	repo     repository.Attachment
+	store    blob.BlobStore
+	interval time.Duration
+}
+
+func NewAttachmentSweeper(repo repository.Attachment, cfg Config) *AttachmentSweeper {
+	return &AttachmentSweeper{repo: repo, store: cfg.Attachments.Store, interval: cfg.Attachments.SweepInterval}
+}
+
+// Run removes files until ctx is cancelled.
+func (w *AttachmentSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if err := w.RunOnce(ctx); err != nil {
+			logrus.Errorf("failed to remove files of deleted items: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce removes the files of all items deleted so far. The file goes
+// first, so a failure leaves the row to be tried again.
+func (w *AttachmentSweeper) RunOnce(ctx context.Context) error {
+	for ctx.Err() == nil {
+		attachments, err := w.repo.GetDetached(sweepBatch)
+		if err != nil {
+			return err
+		}
+		for _, attachment := range attachments {
+			if err := w.store.Delete(ctx, attachment.StorageKey); err != nil {
+				return err
+			}
+			if err := w.repo.Delete(attachment.Id); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+		if len(attachments) < sweepBatch {
+			return nil
+		}
+	}
+	return nil
+}
@@ -1,76 +1,453 @@
 package service
 
 import (
-	"crypto/sha1"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"fmt"
 	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/mailer"
+	"github.com/Olmosbek510/todo-app/pkg/oidc"
 	"github.com/Olmosbek510/todo-app/pkg/repository"
 	"github.com/dgrijalva/jwt-go"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	salt       = "ufhuihdfihdsuf"
-	signingKey = "lksdjklsjldjslijijdjfojsfjo9989382"
-	tokenTTL   = 12 * time.Hour
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidCredentials         = errors.New("invalid username or password")
+	ErrTokenRevoked               = errors.New("token has been revoked")
+	ErrInvalidPersonalAccessToken = errors.New("invalid personal access token")
+	ErrInvalidRefreshToken        = errors.New("invalid refresh token")
+	ErrRefreshTokenReused         = errors.New("refresh token reuse detected, all sessions of this sign-in were revoked")
 )
 
 type tokenClaims struct {
 	jwt.StandardClaims
-	UserId int `json:"user_id"`
+	UserId    int    `json:"user_id"`
+	SessionId string `json:"sid"`
+	// Purpose is never set on access tokens, it is read to reject other
+	// tokens signed with the same keys.
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// Identity describes the caller behind a verified access token or personal
+// access token.
+type Identity struct {
+	UserId    int
+	TokenId   string
+	SessionId string
+	ExpiresAt time.Time
+
+	// PersonalAccessTokenId and Scopes are only set for personal access
+	// tokens, sessions are not restricted by scopes.
+	PersonalAccessTokenId int
+	Scopes                todo.Scopes
+}
+
+func (i Identity) IsSession() bool {
+	return i.PersonalAccessTokenId == 0
+}
+
+func (i Identity) Allows(scope string) bool {
+	return i.IsSession() || i.Scopes.Allows(scope)
 }
 
 type AuthService struct {
-	repo repository.Authorization
+	repo          repository.Authorization
+	refreshRepo   repository.RefreshToken
+	patRepo       repository.PersonalAccessToken
+	userTokenRepo repository.UserToken
+	identityRepo  repository.Identity
+	listRepo      repository.TodoList
+	itemRepo      repository.TodoItem
+
+	throttle  *loginThrottle
+	auditRepo repository.Audit
+
+	revocations *revocationStore
+	keys        *KeySet
+	legacySalt  string
+	mailer      mailer.Mailer
+	publicURL   string
+	totpIssuer  string
+
+	oidcProviders map[string]*oidc.Provider
+
+	requireVerifiedEmail bool
 }
 
-func (s *AuthService) ParseToken(accessToken string) (int, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &tokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return []byte(signingKey), nil
-	})
+func (s *AuthService) ParseToken(accessToken string) (Identity, error) {
+	if isPersonalAccessToken(accessToken) {
+		return s.parsePersonalAccessToken(accessToken)
+	}
+
+	token, err := jwt.ParseWithClaims(accessToken, &tokenClaims{}, s.keys.keyFunc)
 
 	if err != nil {
-		return 0, err
+		return Identity{}, err
 	}
 
 	claims, ok := token.Claims.(*tokenClaims)
 	if !ok {
-		return 0, errors.New("token claims are not type *tokenClaims")
+		return Identity{}, errors.New("token claims are not type *tokenClaims")
 	}
-	return claims.UserId, nil
+	if claims.Id == "" || claims.UserId == 0 || claims.Purpose != "" {
+		return Identity{}, errors.New("token is not an access token")
+	}
+
+	revoked, err := s.revocations.isRevoked(claims.Id)
+	if err != nil {
+		return Identity{}, err
+	}
+	if revoked {
+		return Identity{}, ErrTokenRevoked
+	}
+	if claims.SessionId != "" {
+		revoked, err := s.revocations.isSessionRevoked(claims.SessionId)
+		if err != nil {
+			return Identity{}, err
+		}
+		if revoked {
+			return Identity{}, ErrTokenRevoked
+		}
+	}
+
+	validAfter, err := s.revocations.tokensValidAfter(claims.UserId)
+	if err != nil {
+		return Identity{}, err
+	}
+	if validAfter != nil && claims.IssuedAt < validAfter.Unix() {
+		return Identity{}, ErrTokenRevoked
+	}
+
+	return Identity{
+		UserId:    claims.UserId,
+		TokenId:   claims.Id,
+		SessionId: claims.SessionId,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
 }
 
-func (s *AuthService) GenerateToken(username string, password string) (string, error) {
-	user, err := s.repo.GetUser(username, s.generatePasswordHash(password))
+func (s *AuthService) parsePersonalAccessToken(raw string) (Identity, error) {
+	token, err := s.patRepo.GetByHash(hashToken(raw))
 	if err != nil {
-		return "", err
+		if errors.Is(err, sql.ErrNoRows) {
+			return Identity{}, ErrInvalidPersonalAccessToken
+		}
+		return Identity{}, err
+	}
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return Identity{}, ErrInvalidPersonalAccessToken
+	}
+	if err := s.patRepo.TouchLastUsed(token.Id); err != nil {
+		return Identity{}, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &tokenClaims{
-		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(12 * time.Hour).Unix(),
+	identity := Identity{
+		UserId:                token.UserId,
+		PersonalAccessTokenId: token.Id,
+		Scopes:                token.Scopes,
+	}
+	if token.ExpiresAt != nil {
+		identity.ExpiresAt = *token.ExpiresAt
+	}
+	return identity, nil
+}
+
+// SignOut revokes the access token behind identity together with the
+// refresh tokens of the same sign-in.
+func (s *AuthService) SignOut(identity Identity) error {
+	if err := s.revocations.revoke(identity.TokenId, identity.UserId, identity.ExpiresAt); err != nil {
+		return err
+	}
+	if identity.SessionId == "" {
+		return nil
+	}
+	return s.refreshRepo.RevokeFamily(identity.SessionId)
+}
+
+// SignOutAll revokes every access and refresh token issued to the user.
+func (s *AuthService) SignOutAll(userId int) error {
+	return s.revocations.revokeAll(userId)
+}
+
+// SignIn checks the credentials and starts a session. Users with two-factor
+// authentication get a challenge token instead, to be exchanged for the
+// session tokens with VerifyTwoFactor. Failed attempts are counted per
+// username and per client address, see LockoutConfig. For users with
+// two-factor authentication, they are only cleared once the second factor
+// passed as well.
+func (s *AuthService) SignIn(username, password, clientIP string) (todo.SignInResult, error) {
+	if err := s.checkLockout(username, clientIP); err != nil {
+		return todo.SignInResult{}, err
+	}
+
+	user, err := s.authenticate(username, password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			if err := s.recordFailedSignIn(username, clientIP); err != nil {
+				return todo.SignInResult{}, err
+			}
+		}
+		return todo.SignInResult{}, err
+	}
+	if user.TOTPEnabled {
+		challenge, err := s.issueUserToken(user.Id, purposeTwoFactorChallenge, "", twoFactorChallengeTTL)
+		if err != nil {
+			return todo.SignInResult{}, err
+		}
+		return todo.SignInResult{ChallengeToken: challenge}, nil
+	}
+
+	if err := s.resetFailedSignIns(username); err != nil {
+		return todo.SignInResult{}, err
+	}
+	tokens, err := s.startSession(user.Id)
+	if err != nil {
+		return todo.SignInResult{}, err
+	}
+	return todo.SignInResult{Tokens: &tokens}, nil
+}
+
+// startSession issues an access token and the first refresh token of a new
+// token family.
+func (s *AuthService) startSession(userId int) (todo.Tokens, error) {
+	familyId, err := randomToken(16)
+	if err != nil {
+		return todo.Tokens{}, err
+	}
+	issued, err := s.newRefreshToken(userId, familyId)
+	if err != nil {
+		return todo.Tokens{}, err
+	}
+	if err := s.refreshRepo.Create(issued.stored); err != nil {
+		return todo.Tokens{}, err
+	}
+	return s.newTokens(userId, familyId, issued.raw)
+}
+
+// RefreshTokens exchanges a refresh token for a new access token and a new
+// refresh token. Every refresh token is single use: presenting one that was
+// already rotated is treated as theft and revokes the whole token family.
+func (s *AuthService) RefreshTokens(refreshToken string) (todo.Tokens, error) {
+	stored, err := s.refreshRepo.GetByHash(hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return todo.Tokens{}, ErrInvalidRefreshToken
+		}
+		return todo.Tokens{}, err
+	}
+
+	if stored.RevokedAt != nil {
+		return todo.Tokens{}, s.revokeFamily(stored.FamilyId)
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return todo.Tokens{}, ErrInvalidRefreshToken
+	}
+
+	next, err := s.newRefreshToken(stored.UserId, stored.FamilyId)
+	if err != nil {
+		return todo.Tokens{}, err
+	}
+	if err := s.refreshRepo.Rotate(stored.Id, next.stored); err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenRevoked) {
+			// another request rotated this token first
+			return todo.Tokens{}, s.revokeFamily(stored.FamilyId)
+		}
+		return todo.Tokens{}, err
+	}
+	return s.newTokens(stored.UserId, stored.FamilyId, next.raw)
+}
+
+func (s *AuthService) revokeFamily(familyId string) error {
+	if err := s.refreshRepo.RevokeFamily(familyId); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+func (s *AuthService) newTokens(userId int, sessionId, refreshToken string) (todo.Tokens, error) {
+	accessToken, err := s.generateAccessToken(userId, sessionId)
+	if err != nil {
+		return todo.Tokens{}, err
+	}
+	return todo.Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    accessTokenTTL,
+	}, nil
+}
+
+func (s *AuthService) generateAccessToken(userId int, sessionId string) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	return s.keys.sign(&tokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			ExpiresAt: time.Now().Add(accessTokenTTL).Unix(),
 			IssuedAt:  time.Now().Unix(),
 		},
-		user.Id,
+		UserId:    userId,
+		SessionId: sessionId,
 	})
-	return token.SignedString([]byte(signingKey))
 }
 
-func NewAuthService(repo repository.Authorization) *AuthService {
-	return &AuthService{repo: repo}
+type issuedRefreshToken struct {
+	raw    string
+	stored todo.RefreshToken
+}
+
+func (s *AuthService) newRefreshToken(userId int, familyId string) (issuedRefreshToken, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return issuedRefreshToken{}, err
+	}
+	return issuedRefreshToken{
+		raw: raw,
+		stored: todo.RefreshToken{
+			UserId:    userId,
+			FamilyId:  familyId,
+			TokenHash: hashToken(raw),
+			ExpiresAt: time.Now().Add(refreshTokenTTL),
+		},
+	}, nil
+}
+
+func NewAuthService(repos *repository.Repository, cfg Config) *AuthService {
+	return &AuthService{
+		repo:          repos.Authorization,
+		refreshRepo:   repos.RefreshToken,
+		patRepo:       repos.PersonalAccessToken,
+		userTokenRepo: repos.UserToken,
+		identityRepo:  repos.Identity,
+		listRepo:      repos.TodoList,
+		itemRepo:      repos.TodoItem,
+
+		throttle:  newLoginThrottle(repos.LoginAttempt, cfg.Lockout),
+		auditRepo: repos.Audit,
+
+		revocations:   newRevocationStore(repos.Revocation),
+		keys:          cfg.Keys,
+		legacySalt:    cfg.LegacyPasswordSalt,
+		mailer:        cfg.Mailer,
+		publicURL:     strings.TrimSuffix(cfg.PublicURL, "/"),
+		totpIssuer:    cfg.TOTPIssuer,
+		oidcProviders: cfg.OIDCProviders,
+
+		requireVerifiedEmail: cfg.RequireVerifiedEmail,
+	}
+}
+
+// JWKS returns the public keys other services can verify access tokens with.
+func (s *AuthService) JWKS() JSONWebKeySet {
+	return s.keys.JWKS()
 }
 
 func (s *AuthService) CreateUser(user todo.User) (int, error) {
-	user.Password = s.generatePasswordHash(user.Password)
-	return s.repo.CreateUser(user)
+	if user.Email != nil {
+		email := normalizeEmail(*user.Email)
+		user.Email = &email
+	}
+	if s.requireVerifiedEmail && (user.Email == nil || *user.Email == "") {
+		return 0, ErrEmailRequired
+	}
+
+	passwordHash, err := hashPassword(user.Password)
+	if err != nil {
+		return 0, err
+	}
+	user.PasswordHash = passwordHash
+
+	id, err := s.repo.CreateUser(user)
+	if err != nil {
+		return 0, err
+	}
+
+	user.Id = id
+	if err := s.sendVerificationEmail(user); err != nil {
+		// another link can be requested, the sign-up itself succeeded
+		logrus.Errorf("failed to send verification email to user %d: %s", id, err.Error())
+	}
+	return id, nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// authenticate checks the credentials and transparently upgrades hashes
+// that were produced by an outdated scheme.
+func (s *AuthService) authenticate(username, password string) (todo.User, error) {
+	user, err := s.repo.GetUser(username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// do the same amount of work as for a real account, so response
+			// times do not reveal which usernames exist
+			dummyHashOnce.Do(func() { dummyHash, _ = hashPassword("") })
+			verifyPassword(password, dummyHash, s.legacySalt)
+			return todo.User{}, ErrInvalidCredentials
+		}
+		return todo.User{}, err
+	}
+
+	if user.PasswordHash == "" {
+		// provisioned through OpenID Connect, there is no password to check
+		return todo.User{}, ErrInvalidCredentials
+	}
+
+	ok, needsRehash, err := verifyPassword(password, user.PasswordHash, s.legacySalt)
+	if err != nil {
+		return todo.User{}, err
+	}
+	if !ok {
+		return todo.User{}, ErrInvalidCredentials
+	}
+	if s.requireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return todo.User{}, ErrEmailNotVerified
+	}
+
+	if needsRehash {
+		if err := s.rehashPassword(user.Id, password); err != nil {
+			// the old hash keeps working, so the sign-in does not fail
+			logrus.Warnf("failed to upgrade password hash of user %d: %s", user.Id, err.Error())
+		}
+	}
+	return user, nil
+}
+
+func (s *AuthService) rehashPassword(userId int, password string) error {
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.repo.UpdatePasswordHash(userId, passwordHash)
+}
+
+// randomToken returns n random bytes encoded as unpadded url-safe base64.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func (s *AuthService) generatePasswordHash(password string) string {
-	hash := sha1.New()
-	hash.Write([]byte(password))
-	return fmt.Sprintf("%x", hash.Sum([]byte(salt)))
+// hashToken is used for opaque tokens that are stored server side, so a
+// database leak does not hand out usable credentials.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"testing"
+	"time"
+)
+
+// fakeTokenStore keeps refresh tokens and revocations in memory like
+// RefreshTokenPostgres and RevocationPostgres.
+type fakeTokenStore struct {
+	refresh         []todo.RefreshToken
+	revokedTokens   map[string]bool
+	revokedSessions map[string]bool
+	validAfter      map[int]time.Time
+	// beforeRotate runs at the start of Rotate, to act as a request racing
+	// the caller.
+	beforeRotate func()
+}
+
+func (s *fakeTokenStore) Create(token todo.RefreshToken) error {
+	token.Id = len(s.refresh) + 1
+	token.CreatedAt = time.Now()
+	s.refresh = append(s.refresh, token)
+	return nil
+}
+
+func (s *fakeTokenStore) GetByHash(tokenHash string) (todo.RefreshToken, error) {
+	for _, token := range s.refresh {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return todo.RefreshToken{}, sql.ErrNoRows
+}
+
+func (s *fakeTokenStore) Rotate(oldId int, newToken todo.RefreshToken) error {
+	if s.beforeRotate != nil {
+		s.beforeRotate()
+	}
+	old := &s.refresh[oldId-1]
+	if old.RevokedAt != nil {
+		return repository.ErrRefreshTokenRevoked
+	}
+	now := time.Now()
+	old.RevokedAt = &now
+	return s.Create(newToken)
+}
+
+func (s *fakeTokenStore) RevokeFamily(familyId string) error {
+	now := time.Now()
+	for i := range s.refresh {
+		if s.refresh[i].FamilyId == familyId && s.refresh[i].RevokedAt == nil {
+			s.refresh[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// openTokens counts the refresh tokens of the family that are not revoked.
+func (s *fakeTokenStore) openTokens(familyId string) int {
+	open := 0
+	for _, token := range s.refresh {
+		if token.FamilyId == familyId && token.RevokedAt == nil {
+			open++
+		}
+	}
+	return open
+}
+
+func (s *fakeTokenStore) RevokeToken(jti string, userId int, expiresAt time.Time) error {
+	s.revokedTokens[jti] = true
+	return nil
+}
+
+func (s *fakeTokenStore) IsTokenRevoked(jti string) (bool, error) {
+	return s.revokedTokens[jti], nil
+}
+
+func (s *fakeTokenStore) RevokeAllTokens(userId int, accessExpiresAt time.Time) (time.Time, []string, error) {
+	now := time.Now()
+	s.validAfter[userId] = now
+	var families []string
+	for i := range s.refresh {
+		token := &s.refresh[i]
+		if token.UserId != userId || token.RevokedAt != nil {
+			continue
+		}
+		if !s.revokedSessions[token.FamilyId] {
+			s.revokedSessions[token.FamilyId] = true
+			families = append(families, token.FamilyId)
+		}
+		token.RevokedAt = &now
+	}
+	return now, families, nil
+}
+
+func (s *fakeTokenStore) GetTokensValidAfter(userId int) (*time.Time, error) {
+	validAfter, ok := s.validAfter[userId]
+	if !ok {
+		return nil, nil
+	}
+	return &validAfter, nil
+}
+
+func (s *fakeTokenStore) IsSessionRevoked(familyId string) (bool, error) {
+	return s.revokedSessions[familyId], nil
+}
+
+func newFakeTokenStore() *fakeTokenStore {
+	return &fakeTokenStore{
+		revokedTokens:   make(map[string]bool),
+		revokedSessions: make(map[string]bool),
+		validAfter:      make(map[int]time.Time),
+	}
+}
+
+func newTestAuthService(t *testing.T, store *fakeTokenStore) *AuthService {
+	t.Helper()
+	keys, err := NewKeySet(KeyConfig{ActiveKid: "test", HMACKid: "test", HMACSecret: "an-hs256-test-secret-of-32-bytes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &AuthService{refreshRepo: store, revocations: newRevocationStore(store), keys: keys}
+}
+
+// familyOf returns the token family the refresh token belongs to.
+func familyOf(t *testing.T, store *fakeTokenStore, refreshToken string) string {
+	t.Helper()
+	token, err := store.GetByHash(hashToken(refreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token.FamilyId
+}
+
+func TestRefreshTokensRotates(t *testing.T) {
+	store := newFakeTokenStore()
+	s := newTestAuthService(t, store)
+	first, err := s.startSession(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.RefreshTokens(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshTokens() error = %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("RefreshTokens() handed out the same refresh token again")
+	}
+	family := familyOf(t, store, first.RefreshToken)
+	if got := familyOf(t, store, second.RefreshToken); got != family {
+		t.Errorf("rotated token is in family %q, want %q", got, family)
+	}
+	if open := store.openTokens(family); open != 1 {
+		t.Errorf("%d open refresh tokens after a rotation, want 1", open)
+	}
+}
+
+func TestRefreshTokensReuseRevokesFamily(t *testing.T) {
+	tests := []struct {
+		name string
+		// reuse presents a refresh token that was already used.
+		reuse func(t *testing.T, s *AuthService, store *fakeTokenStore, first todo.Tokens) error
+	}{
+		{
+			name: "replayed after the rotation",
+			reuse: func(t *testing.T, s *AuthService, store *fakeTokenStore, first todo.Tokens) error {
+				if _, err := s.RefreshTokens(first.RefreshToken); err != nil {
+					t.Fatal(err)
+				}
+				_, err := s.RefreshTokens(first.RefreshToken)
+				return err
+			},
+		},
+		{
+			name: "rotated by another request in the meantime",
+			reuse: func(t *testing.T, s *AuthService, store *fakeTokenStore, first todo.Tokens) error {
+				store.beforeRotate = func() {
+					store.beforeRotate = nil
+					if _, err := s.RefreshTokens(first.RefreshToken); err != nil {
+						t.Fatal(err)
+					}
+				}
+				_, err := s.RefreshTokens(first.RefreshToken)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeTokenStore()
+			s := newTestAuthService(t, store)
+			first, err := s.startSession(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := s.startSession(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.reuse(t, s, store, first); !errors.Is(err, ErrRefreshTokenReused) {
+				t.Fatalf("reusing a refresh token: error = %v, want ErrRefreshTokenReused", err)
+			}
+			// the successor handed out by the rotation is revoked with it
+			if open := store.openTokens(familyOf(t, store, first.RefreshToken)); open != 0 {
+				t.Errorf("%d refresh tokens of the family still open, want 0", open)
+			}
+			// other sign-ins are not affected
+			if _, err := s.RefreshTokens(other.RefreshToken); err != nil {
+				t.Errorf("refresh token of another sign-in: error = %v", err)
+			}
+		})
+	}
+}
+
+func TestSignOutAllWithinTheSameSecond(t *testing.T) {
+	store := newFakeTokenStore()
+	s := newTestAuthService(t, store)
+
+	// the token has to be issued in the second of the sign-out, where the
+	// cut-off on its iat does not reject it
+	var tokens todo.Tokens
+	for {
+		second := time.Now().Unix()
+		var err error
+		tokens, err = s.startSession(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SignOutAll(1); err != nil {
+			t.Fatal(err)
+		}
+		if time.Now().Unix() == second {
+			break
+		}
+	}
+
+	// another instance does not have the sign-out in its cache
+	other := newTestAuthService(t, store)
+	for name, s := range map[string]*AuthService{"same instance": s, "other instance": other} {
+		if _, err := s.ParseToken(tokens.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("%s: ParseToken() error = %v, want ErrTokenRevoked", name, err)
+		}
+	}
+	if _, err := s.RefreshTokens(tokens.RefreshToken); err == nil {
+		t.Error("RefreshTokens() accepted a refresh token revoked by the sign-out")
+	}
+
+	// signing in again right after works
+	fresh, err := s.startSession(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ParseToken(fresh.AccessToken); err != nil {
+		t.Errorf("ParseToken() of a session started after the sign-out: error = %v", err)
+	}
+}
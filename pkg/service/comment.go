@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/notifier"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"time"
+)
+
+var (
+	ErrInvalidCommentParent = errors.New("replies must be to a comment on the same item")
+	ErrCommentEditClosed    = errors.New("the comment can no longer be edited")
+)
+
+// CommentConfig tunes comments on items.
+type CommentConfig struct {
+	// EditWindow is how long after posting a comment its author can edit it.
+	EditWindow time.Duration
+	// MentionChannel is the notification channel mentioned users are told
+	// through by the mention worker.
+	MentionChannel string
+	// Mentions tunes the mention worker.
+	Mentions DeliveryConfig
+}
+
+type CommentService struct {
+	repo       repository.Comment
+	itemRepo   repository.TodoItem
+	memberRepo repository.ListMember
+	editWindow time.Duration
+}
+
+func NewCommentService(repos *repository.Repository, cfg Config) *CommentService {
+	return &CommentService{
+		repo:       repos.Comment,
+		itemRepo:   repos.TodoItem,
+		memberRepo: repos.ListMember,
+		editWindow: cfg.Comments.EditWindow,
+	}
+}
+
+// Create adds a comment to an item, editors and owners of its list can
+// comment. Mentioned members of the list are notified in the background.
+func (s *CommentService) Create(userId, itemId int, input todo.CreateCommentInput) (int, error) {
+	item, err := s.itemRepo.GetById(userId, itemId)
+	if err != nil {
+		return 0, err
+	}
+	if err := requireListEditorRole(s.memberRepo, userId, item.ListId); err != nil {
+		return 0, err
+	}
+
+	comment := todo.Comment{ItemId: itemId, UserId: &userId, Body: input.Body}
+	if input.ParentId != nil {
+		parent, err := s.repo.GetById(userId, *input.ParentId)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && parent.ItemId != itemId) {
+			return 0, ErrInvalidCommentParent
+		}
+		if err != nil {
+			return 0, err
+		}
+		comment.ParentId = &parent.Id
+		if parent.ParentId != nil {
+			comment.ParentId = parent.ParentId
+		}
+	}
+
+	return s.repo.Create(comment, todo.Mentions(input.Body))
+}
+
+// GetAll returns a page of the threads on an item the user can read.
+func (s *CommentService) GetAll(userId, itemId, after, limit int) (todo.CommentPage, error) {
+	if _, err := s.itemRepo.GetById(userId, itemId); err != nil {
+		return todo.CommentPage{}, err
+	}
+	// one more to tell whether there is a next page
+	threads, err := s.repo.GetThreads(itemId, after, limit+1)
+	if err != nil {
+		return todo.CommentPage{}, err
+	}
+	page := todo.CommentPage{Data: threads}
+	if len(threads) > limit {
+		page.Data = threads[:limit]
+		page.NextAfter = &page.Data[limit-1].Id
+	}
+	return page, nil
+}
+
+// Update changes the body of a comment. Only its author can, within the
+// edit window and while they can still edit the list. Members mentioned for
+// the first time are notified.
+func (s *CommentService) Update(userId, id int, input todo.UpdateCommentInput) error {
+	comment, err := s.repo.GetById(userId, id)
+	if err != nil {
+		return err
+	}
+	if comment.UserId == nil || *comment.UserId != userId {
+		return ErrInsufficientRole
+	}
+	if err := requireListEditorRole(s.memberRepo, userId, comment.ListId); err != nil {
+		return err
+	}
+	if time.Since(comment.CreatedAt) > s.editWindow {
+		return ErrCommentEditClosed
+	}
+
+	return s.repo.Update(id, input.Body, todo.Mentions(input.Body))
+}
+
+// Delete removes a comment with its replies. Authors can delete their own
+// comments, owners of the list any comment.
+func (s *CommentService) Delete(userId, id int) error {
+	comment, err := s.repo.GetById(userId, id)
+	if err != nil {
+		return err
+	}
+	if comment.UserId == nil || *comment.UserId != userId {
+		if err := requireListOwner(s.memberRepo, userId, comment.ListId); err != nil {
+			return err
+		}
+	}
+	return s.repo.Delete(id)
+}
+
+// MentionWorker notifies mentioned members in the background.
+type MentionWorker struct {
+	*deliveryWorker[todo.MentionDelivery]
+}
+
+func NewMentionWorker(repo repository.Comment, cfg Config) *MentionWorker {
+	n, ok := cfg.Notifiers[cfg.Comments.MentionChannel]
+	return &MentionWorker{&deliveryWorker[todo.MentionDelivery]{
+		kind:       "mention",
+		cfg:        cfg.Comments.Mentions,
+		claim:      repo.ClaimMentions,
+		markSent:   repo.MarkMentionSent,
+		markFailed: repo.MarkMentionFailed,
+		attempt: func(delivery todo.MentionDelivery) (int, int) {
+			return delivery.Id, delivery.Attempts
+		},
+		send: func(ctx context.Context, delivery todo.MentionDelivery) error {
+			if !ok {
+				return ErrUnknownChannel
+			}
+			return n.Notify(ctx, mentionNotification(delivery))
+		},
+	}}
+}
+
+func mentionNotification(delivery todo.MentionDelivery) notifier.Notification {
+	author := "Someone"
+	if delivery.Author != nil {
+		author = *delivery.Author
+	}
+
+	n := notifier.Notification{
+		Event:   "mention",
+		UserId:  delivery.UserId,
+		Subject: fmt.Sprintf("%s mentioned you on \"%s\"", author, delivery.Title),
+		Body: fmt.Sprintf("Hi %s,\n\n%s mentioned you on \"%s\":\n\n%s", delivery.Username, author, delivery.Title,
+			delivery.Body),
+		Data: map[string]interface{}{
+			"comment_id": delivery.CommentId,
+			"item_id":    delivery.ItemId,
+			"list_id":    delivery.ListId,
+			"title":      delivery.Title,
+			"author":     author,
+			"body":       delivery.Body,
+		},
+	}
+	if delivery.Email != nil {
+		n.Email = *delivery.Email
+	}
+	return n
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/Olmosbek510/todo-app/pkg/notifier"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// DeliveryConfig tunes a background worker that sends notifications, like
+// reminders and mentions.
+type DeliveryConfig struct {
+	// PollInterval is how often every instance looks for notifications to
+	// send.
+	PollInterval time.Duration
+	// BatchSize is the most notifications claimed at once.
+	BatchSize int
+	// Lease is how long a claimed notification stays with the instance before
+	// others may take it over, in case the instance died while sending it.
+	Lease time.Duration
+	// MaxAttempts is how often a notification is tried before it is given up.
+	MaxAttempts int
+	// RetryDelay is the wait after the first failed attempt, it doubles with
+	// every further one.
+	RetryDelay time.Duration
+}
+
+// deliveryWorker claims notifications of type T from the repository and
+// sends them. Any number of app instances can run one, every notification is
+// claimed by a single instance.
+type deliveryWorker[T any] struct {
+	// kind names the notifications in log messages.
+	kind string
+	cfg  DeliveryConfig
+
+	claim      func(limit, maxAttempts int, lease time.Duration) ([]T, error)
+	markSent   func(id int) error
+	markFailed func(id int, reason string, retryAt *time.Time) error
+	// attempt returns the id of the claimed notification and the number of
+	// the attempt being made.
+	attempt func(delivery T) (id, attempts int)
+	send    func(ctx context.Context, delivery T) error
+}
+
+// Run sends notifications until ctx is cancelled.
+func (w *deliveryWorker[T]) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := w.RunOnce(ctx); err != nil {
+			logrus.Errorf("failed to send %s notifications: %s", w.kind, err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims the notifications due now and sends them, a full batch is
+// followed by the next one right away.
+func (w *deliveryWorker[T]) RunOnce(ctx context.Context) error {
+	for ctx.Err() == nil {
+		deliveries, err := w.claim(w.cfg.BatchSize, w.cfg.MaxAttempts, w.cfg.Lease)
+		if err != nil {
+			return err
+		}
+		for _, delivery := range deliveries {
+			if err := w.deliver(ctx, delivery); err != nil {
+				return err
+			}
+		}
+		if len(deliveries) < w.cfg.BatchSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+// deliver sends the notification and records the outcome, the error is
+// about recording it.
+func (w *deliveryWorker[T]) deliver(ctx context.Context, delivery T) error {
+	id, attempts := w.attempt(delivery)
+	err := w.send(ctx, delivery)
+	if err == nil {
+		return w.markSent(id)
+	}
+
+	logrus.Warnf("%s %d, attempt %d: %s", w.kind, id, attempts, err.Error())
+	var retryAt *time.Time
+	if !errors.Is(err, notifier.ErrPermanent) && !errors.Is(err, ErrUnknownChannel) && attempts < w.cfg.MaxAttempts {
+		at := time.Now().Add(w.cfg.RetryDelay << (attempts - 1))
+		retryAt = &at
+	}
+	return w.markFailed(id, err.Error(), retryAt)
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/mailer"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	purposeListInvite = "list_invite"
+
+	invitationTTL = 7 * 24 * time.Hour
+)
+
+var ErrInvitationClosed = errors.New("invitation was already answered or has expired")
+
+type InvitationService struct {
+	repo       repository.Invitation
+	memberRepo repository.ListMember
+	userRepo   repository.Authorization
+	keys       *KeySet
+	mailer     mailer.Mailer
+}
+
+func NewInvitationService(repos *repository.Repository, cfg Config) *InvitationService {
+	return &InvitationService{
+		repo:       repos.Invitation,
+		memberRepo: repos.ListMember,
+		userRepo:   repos.Authorization,
+		keys:       cfg.Keys,
+		mailer:     cfg.Mailer,
+	}
+}
+
+// Create invites a user by username or an email address to the list and
+// returns the invitation id together with a signed token that accepts it.
+// The token is mailed to the invitee when an address is known. Only owners
+// can invite.
+func (s *InvitationService) Create(userId, listId int, input todo.CreateInvitationInput) (int, string, error) {
+	if err := input.Validate(); err != nil {
+		return 0, "", err
+	}
+	if err := requireListOwner(s.memberRepo, userId, listId); err != nil {
+		return 0, "", err
+	}
+
+	invitation := todo.Invitation{
+		ListId:    listId,
+		InviterId: userId,
+		Role:      input.Role,
+		ExpiresAt: time.Now().Add(invitationTTL),
+	}
+	invitee, err := s.findInvitee(input)
+	if err != nil {
+		return 0, "", err
+	}
+	if invitee != nil {
+		if _, err := s.memberRepo.GetRole(invitee.Id, listId); err == nil {
+			return 0, "", ErrAlreadyMember
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return 0, "", err
+		}
+		invitation.InviteeId = &invitee.Id
+		if invitee.EmailVerifiedAt != nil {
+			invitation.Email = invitee.Email
+		}
+	} else {
+		email := normalizeEmail(input.Email)
+		invitation.Email = &email
+	}
+
+	id, err := s.repo.Create(invitation)
+	if err != nil {
+		return 0, "", err
+	}
+	token, err := s.keys.sign(&userTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        strconv.Itoa(id),
+			ExpiresAt: invitation.ExpiresAt.Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+		Purpose: purposeListInvite,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	if invitation.Email != nil {
+		if err := s.sendInvitation(id, *invitation.Email, token); err != nil {
+			// the token is also handed to the inviter, so they can pass it on
+			logrus.Errorf("failed to send invitation %d: %s", id, err.Error())
+		}
+	}
+	return id, token, nil
+}
+
+// findInvitee resolves the invited user. Email addresses only match verified
+// accounts, anyone else has to prove the address by receiving the token.
+func (s *InvitationService) findInvitee(input todo.CreateInvitationInput) (*todo.User, error) {
+	var user todo.User
+	var err error
+	if input.Username != "" {
+		user, err = s.userRepo.GetUser(input.Username)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+	} else {
+		user, err = s.userRepo.GetUserByEmail(normalizeEmail(input.Email))
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && user.EmailVerifiedAt == nil) {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *InvitationService) sendInvitation(id int, email, token string) error {
+	invitation, err := s.repo.GetById(id)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(mailer.Message{
+		To:      email,
+		Subject: fmt.Sprintf("%s shared \"%s\" with you", invitation.Inviter, invitation.ListTitle),
+		Body: fmt.Sprintf("Hi,\n\n%s invited you to the list \"%s\" as %s. "+
+			"Sign in or create an account, then accept the invitation with the token below:\n\n%s\n\n"+
+			"The invitation is valid for %d days.\n",
+			invitation.Inviter, invitation.ListTitle, invitation.Role, token, int(invitationTTL.Hours()/24)),
+	})
+}
+
+// GetAllByList returns every invitation of the list, only owners can see
+// them.
+func (s *InvitationService) GetAllByList(userId, listId int) ([]todo.Invitation, error) {
+	if err := requireListOwner(s.memberRepo, userId, listId); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAllByList(listId)
+}
+
+func (s *InvitationService) Revoke(userId, listId, id int) error {
+	if err := requireListOwner(s.memberRepo, userId, listId); err != nil {
+		return err
+	}
+	return s.repo.Delete(listId, id)
+}
+
+// GetPending returns the open invitations of the user.
+func (s *InvitationService) GetPending(userId int) ([]todo.Invitation, error) {
+	user, err := s.userRepo.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetPendingForUser(userId, verifiedUserEmail(user))
+}
+
+func (s *InvitationService) Accept(userId, id int) error {
+	if err := s.checkRecipient(userId, id); err != nil {
+		return err
+	}
+	return invitationResult(s.repo.Accept(id, userId))
+}
+
+func (s *InvitationService) Decline(userId, id int) error {
+	if err := s.checkRecipient(userId, id); err != nil {
+		return err
+	}
+	return invitationResult(s.repo.Decline(id, userId))
+}
+
+// AcceptToken accepts the invitation behind a token from an invitation
+// email. Invitations sent to an email address can be accepted by whoever
+// holds the token, e.g. right after signing up, invitations for a specific
+// user only by that user. It returns the id of the list.
+func (s *InvitationService) AcceptToken(userId int, token string) (int, error) {
+	var claims userTokenClaims
+	if _, err := jwt.ParseWithClaims(token, &claims, s.keys.keyFunc); err != nil {
+		return 0, ErrInvalidUserToken
+	}
+	id, err := strconv.Atoi(claims.Id)
+	if claims.Purpose != purposeListInvite || err != nil {
+		return 0, ErrInvalidUserToken
+	}
+
+	invitation, err := s.repo.GetById(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// revoked by an owner
+			return 0, ErrInvalidUserToken
+		}
+		return 0, err
+	}
+	if invitation.InviteeId != nil && *invitation.InviteeId != userId {
+		return 0, ErrInvalidUserToken
+	}
+	if err := invitationResult(s.repo.Accept(id, userId)); err != nil {
+		return 0, err
+	}
+	return invitation.ListId, nil
+}
+
+// checkRecipient makes sure the invitation is addressed to the user, either
+// directly or through their verified email. Other invitations are reported
+// as missing.
+func (s *InvitationService) checkRecipient(userId, id int) error {
+	invitation, err := s.repo.GetById(id)
+	if err != nil {
+		return err
+	}
+	if invitation.InviteeId != nil {
+		if *invitation.InviteeId != userId {
+			return sql.ErrNoRows
+		}
+		return nil
+	}
+
+	user, err := s.userRepo.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+	email := verifiedUserEmail(user)
+	if email == nil || invitation.Email == nil || !strings.EqualFold(*email, *invitation.Email) {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// invitationResult reports an invitation that could not be answered
+// because it is no longer pending as ErrInvitationClosed.
+func invitationResult(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvitationClosed
+	}
+	return err
+}
+
+func verifiedUserEmail(user todo.User) *string {
+	if user.EmailVerifiedAt == nil {
+		return nil
+	}
+	return user.Email
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const minHMACKeyLength = 32
+
+type KeyConfig struct {
+	// ActiveKid selects the key new tokens are signed with.
+	ActiveKid string
+	// HMACKid and HMACSecret register a HS256 key, usually from the environment.
+	HMACKid    string
+	HMACSecret string
+	// KeysDir holds one key per file, the file name without extension is the
+	// kid. *.pem files contain RSA or Ed25519 keys, a public key only makes the
+	// kid verify-only, which is how retired keys are kept around. *.key files
+	// contain raw HS256 secrets.
+	KeysDir string
+}
+
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// KeySet holds every key tokens may be verified with and the single active
+// key new tokens are signed with. Each token names its key in the kid header,
+// so keys can be rotated without invalidating tokens that are still in use.
+type KeySet struct {
+	keys   map[string]*signingKey
+	active *signingKey
+}
+
+func NewKeySet(cfg KeyConfig) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*signingKey)}
+
+	if cfg.HMACSecret != "" {
+		if err := ks.addHMAC(cfg.HMACKid, []byte(cfg.HMACSecret)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.KeysDir != "" {
+		if err := ks.loadDir(cfg.KeysDir); err != nil {
+			return nil, err
+		}
+	}
+
+	active, ok := ks.keys[cfg.ActiveKid]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q is not configured", cfg.ActiveKid)
+	}
+	if active.private == nil {
+		return nil, fmt.Errorf("active signing key %q has no private key", cfg.ActiveKid)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+func (ks *KeySet) loadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		kid := strings.TrimSuffix(entry.Name(), ext)
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		switch ext {
+		case ".pem":
+			err = ks.addPEM(kid, data)
+		case ".key":
+			err = ks.addHMAC(kid, []byte(strings.TrimSpace(string(data))))
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("key file %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (ks *KeySet) add(key *signingKey) error {
+	if key.kid == "" {
+		return errors.New("key id is empty")
+	}
+	if _, ok := ks.keys[key.kid]; ok {
+		return fmt.Errorf("duplicate key id %q", key.kid)
+	}
+	ks.keys[key.kid] = key
+	return nil
+}
+
+func (ks *KeySet) addHMAC(kid string, secret []byte) error {
+	if len(secret) < minHMACKeyLength {
+		return fmt.Errorf("HS256 key %q must be at least %d bytes long", kid, minHMACKeyLength)
+	}
+	return ks.add(&signingKey{kid: kid, method: jwt.SigningMethodHS256, private: secret, public: secret})
+}
+
+func (ks *KeySet) addPEM(kid string, data []byte) error {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return errors.New("no PEM data found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return ks.add(&signingKey{kid: kid, method: jwt.SigningMethodRS256, private: key, public: &key.PublicKey})
+	case *rsa.PublicKey:
+		return ks.add(&signingKey{kid: kid, method: jwt.SigningMethodRS256, public: key})
+	case ed25519.PrivateKey:
+		return ks.add(&signingKey{kid: kid, method: SigningMethodEdDSA, private: key, public: key.Public()})
+	case ed25519.PublicKey:
+		return ks.add(&signingKey{kid: kid, method: SigningMethodEdDSA, public: key})
+	default:
+		return fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	token.Header["kid"] = ks.active.kid
+	return token.SignedString(ks.active.private)
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	// the algorithm is bound to the key, never to what the token claims
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("invalid signing method")
+	}
+	return key.public, nil
+}
+
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public part of every asymmetric key. HS256 secrets are
+// never published, tokens signed with them can only be verified by this app.
+func (ks *KeySet) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JSONWebKey{
+				Kty: "RSA",
+				Kid: key.kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JSONWebKey{
+				Kty: "OKP",
+				Kid: key.kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// signingMethodEdDSA adds Ed25519 signatures, which jwt-go v3 does not
+// support out of the box.
+type signingMethodEdDSA struct{}
+
+var SigningMethodEdDSA jwt.SigningMethod = &signingMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+func (m *signingMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(public, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(private, []byte(signingString))), nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+)
+
+var (
+	ErrLabelExists = repository.ErrLabelExists
+	// ErrLabelWorkspace is returned when a workspace label is put on an item
+	// of a list in another workspace.
+	ErrLabelWorkspace = errors.New("the label belongs to another workspace")
+)
+
+type LabelService struct {
+	repo     repository.Label
+	itemRepo repository.TodoItem
+	listRepo repository.TodoList
+}
+
+func NewLabelService(repo repository.Label, itemRepo repository.TodoItem, listRepo repository.TodoList) *LabelService {
+	return &LabelService{repo: repo, itemRepo: itemRepo, listRepo: listRepo}
+}
+
+func (s *LabelService) Create(userId int, input todo.CreateLabelInput) (int, error) {
+	return s.repo.Create(userId, input)
+}
+
+func (s *LabelService) GetAll(userId int) ([]todo.Label, error) {
+	return s.repo.GetAll(userId)
+}
+
+func (s *LabelService) GetById(userId, id int) (todo.Label, error) {
+	return s.repo.GetById(userId, id)
+}
+
+func (s *LabelService) Update(userId, id int, input todo.UpdateLabelInput) error {
+	return s.repo.Update(userId, id, input)
+}
+
+func (s *LabelService) Delete(userId, id int) error {
+	return s.repo.Delete(userId, id)
+}
+
+// AddToItem puts a label the user can see on an item they can edit.
+func (s *LabelService) AddToItem(userId, itemId, labelId int) error {
+	list, err := s.requireItemEditor(userId, itemId)
+	if err != nil {
+		return err
+	}
+	label, err := s.repo.GetById(userId, labelId)
+	if err != nil {
+		return err
+	}
+	if label.WorkspaceId != nil && *label.WorkspaceId != list.WorkspaceId {
+		return ErrLabelWorkspace
+	}
+	return s.repo.AddToItem(itemId, labelId)
+}
+
+func (s *LabelService) RemoveFromItem(userId, itemId, labelId int) error {
+	if _, err := s.requireItemEditor(userId, itemId); err != nil {
+		return err
+	}
+	return s.repo.RemoveFromItem(itemId, labelId)
+}
+
+// requireItemEditor returns the list of the item if the user can change it.
+func (s *LabelService) requireItemEditor(userId, itemId int) (todo.TodoList, error) {
+	item, err := s.itemRepo.GetById(userId, itemId)
+	if err != nil {
+		return todo.TodoList{}, err
+	}
+	list, err := s.listRepo.GetById(userId, item.ListId)
+	if err != nil {
+		return todo.TodoList{}, err
+	}
+	if list.Role == todo.ListRoleViewer {
+		return todo.TodoList{}, ErrInsufficientRole
+	}
+	return list, nil
+}
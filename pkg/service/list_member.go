@@ -0,0 +1,103 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+)
+
+var (
+	ErrInsufficientRole = repository.ErrInsufficientRole
+	ErrUserNotFound     = errors.New("user not found")
+	ErrAlreadyMember    = errors.New("user is already a member of the list")
+)
+
+type ListMemberService struct {
+	repo     repository.ListMember
+	userRepo repository.Authorization
+}
+
+func NewListMemberService(repo repository.ListMember, userRepo repository.Authorization) *ListMemberService {
+	return &ListMemberService{repo: repo, userRepo: userRepo}
+}
+
+// GetAll lists the members of a list, any member may see them.
+func (s *ListMemberService) GetAll(userId, listId int) ([]todo.ListMember, error) {
+	if _, err := s.repo.GetRole(userId, listId); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAll(listId)
+}
+
+func (s *ListMemberService) Add(userId, listId int, input todo.AddMemberInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	if err := s.requireOwner(userId, listId); err != nil {
+		return err
+	}
+
+	member, err := s.userRepo.GetUser(input.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if _, err := s.repo.GetRole(member.Id, listId); err == nil {
+		return ErrAlreadyMember
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return s.repo.Add(listId, member.Id, input.Role)
+}
+
+func (s *ListMemberService) UpdateRole(userId, listId, memberId int, input todo.UpdateMemberInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	if err := s.requireOwner(userId, listId); err != nil {
+		return err
+	}
+	return s.repo.UpdateRole(listId, memberId, input.Role)
+}
+
+// Remove takes a member off the list. Owners can remove anyone, every other
+// member can only remove themselves.
+func (s *ListMemberService) Remove(userId, listId, memberId int) error {
+	if userId != memberId {
+		if err := s.requireOwner(userId, listId); err != nil {
+			return err
+		}
+	}
+	return s.repo.Remove(listId, memberId)
+}
+
+func (s *ListMemberService) requireOwner(userId, listId int) error {
+	return requireListOwner(s.repo, userId, listId)
+}
+
+func requireListOwner(repo repository.ListMember, userId, listId int) error {
+	role, err := repo.GetRole(userId, listId)
+	if err != nil {
+		return err
+	}
+	if role != todo.ListRoleOwner {
+		return ErrInsufficientRole
+	}
+	return nil
+}
+
+// requireListEditorRole fails unless the user is an editor or owner of the
+// list.
+func requireListEditorRole(repo repository.ListMember, userId, listId int) error {
+	role, err := repo.GetRole(userId, listId)
+	if err != nil {
+		return err
+	}
+	if role == todo.ListRoleViewer {
+		return ErrInsufficientRole
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"github.com/sirupsen/logrus"
+	"math"
+	"strings"
+	"time"
+)
+
+type LockoutConfig struct {
+	// UsernameThreshold and IPThreshold are the failed sign-ins allowed per
+	// username and per client address before the first lockout. Zero turns
+	// the respective check off. Share link passwords are limited per link
+	// with UsernameThreshold.
+	UsernameThreshold int
+	IPThreshold       int
+	// BaseDelay is the first lockout, it doubles with every further failure
+	// up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Window is how long failures are remembered.
+	Window time.Duration
+}
+
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("too many failed attempts, try again in %d seconds",
+		int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// usernameKey is the key of the exact username, usernames are case sensitive
+// so "Bob" and "bob" are locked out independently.
+func usernameKey(username string) string {
+	return "user:" + username
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginThrottle locks keys out after repeated failures, for a delay that
+// doubles with every further failure. Sign-ins and share link passwords go
+// through it.
+type loginThrottle struct {
+	repo repository.LoginAttempt
+	cfg  LockoutConfig
+}
+
+func newLoginThrottle(repo repository.LoginAttempt, cfg LockoutConfig) *loginThrottle {
+	return &loginThrottle{repo: repo, cfg: cfg}
+}
+
+// check returns a TooManyAttemptsError while one of the keys is locked out.
+func (t *loginThrottle) check(keys []string) error {
+	var lockedUntil time.Time
+	for _, key := range keys {
+		until, err := t.repo.GetLockedUntil(key)
+		if err != nil {
+			return err
+		}
+		if until != nil && until.After(lockedUntil) {
+			lockedUntil = *until
+		}
+	}
+	if lockedUntil.IsZero() {
+		return nil
+	}
+	return &TooManyAttemptsError{RetryAfter: time.Until(lockedUntil)}
+}
+
+// recordFailure counts a failure against the key and locks it out once
+// threshold is reached. It returns the number of failures and the lockout,
+// zero while the key is still below the threshold.
+func (t *loginThrottle) recordFailure(key string, threshold int) (int, time.Duration, error) {
+	failures, err := t.repo.RecordFailure(key, t.cfg.Window)
+	if err != nil || failures < threshold {
+		return failures, 0, err
+	}
+
+	delay := t.lockoutDelay(failures - threshold)
+	if err := t.repo.Lock(key, time.Now().Add(delay)); err != nil {
+		return failures, 0, err
+	}
+	return failures, delay, nil
+}
+
+func (t *loginThrottle) lockoutDelay(extraFailures int) time.Duration {
+	delay := t.cfg.BaseDelay
+	for i := 0; i < extraFailures && delay < t.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.cfg.MaxDelay {
+		delay = t.cfg.MaxDelay
+	}
+	return delay
+}
+
+func (t *loginThrottle) reset(key string) error {
+	return t.repo.Reset(key)
+}
+
+// checkLockout returns a TooManyAttemptsError while the username or the
+// client address is locked out.
+func (s *AuthService) checkLockout(username, ip string) error {
+	return s.throttle.check(s.throttledKeys(username, ip))
+}
+
+// recordFailedSignIn counts the failure against the username and the client
+// address and locks them out once their threshold is reached.
+func (s *AuthService) recordFailedSignIn(username, ip string) error {
+	for _, key := range s.throttledKeys(username, ip) {
+		threshold := s.throttle.cfg.UsernameThreshold
+		if strings.HasPrefix(key, "ip:") {
+			threshold = s.throttle.cfg.IPThreshold
+		}
+
+		failures, delay, err := s.throttle.recordFailure(key, threshold)
+		if err != nil {
+			return err
+		}
+		if delay > 0 {
+			s.auditLockout(key, username, ip, failures, delay)
+		}
+	}
+	return nil
+}
+
+func (s *AuthService) auditLockout(key, username, ip string, failures int, delay time.Duration) {
+	entry := todo.AuditEntry{
+		Event:  todo.AuditLoginLocked,
+		IP:     ip,
+		Detail: fmt.Sprintf("%s locked for %s after %d failed sign-ins", key, delay, failures),
+	}
+	if user, err := s.repo.GetUser(username); err == nil {
+		entry.UserId = &user.Id
+	}
+	recordAudit(s.auditRepo, entry)
+}
+
+// recordAudit writes an audit entry, failures are only logged.
+func recordAudit(repo repository.Audit, entry todo.AuditEntry) {
+	if err := repo.Record(entry); err != nil {
+		logrus.Errorf("failed to write audit entry: %s", err.Error())
+	}
+	logrus.Warn(entry.Detail)
+}
+
+func (s *AuthService) resetFailedSignIns(username string) error {
+	if s.throttle.cfg.UsernameThreshold <= 0 {
+		return nil
+	}
+	return s.throttle.reset(usernameKey(username))
+}
+
+func (s *AuthService) throttledKeys(username, ip string) []string {
+	keys := make([]string, 0, 2)
+	if s.throttle.cfg.UsernameThreshold > 0 {
+		keys = append(keys, usernameKey(username))
+	}
+	if s.throttle.cfg.IPThreshold > 0 && ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/oidc"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	oidcLoginTTL      = 10 * time.Minute
+	maxUsernameLength = 64
+
+	purposeReauth = "reauth"
+	reauthTTL     = 5 * time.Minute
+	// reauthMaxAge is how long ago the provider may have signed the user in
+	// for the login to count as a fresh proof.
+	reauthMaxAge = 5 * time.Minute
+)
+
+var (
+	ErrUnknownProvider       = errors.New("unknown identity provider")
+	ErrInvalidLoginState     = errors.New("invalid or expired login state")
+	ErrIdentityAlreadyLinked = errors.New("identity is already linked to a user")
+	ErrLastSignInMethod      = errors.New("cannot remove the only way to sign in, set a password first")
+	ErrReauthFailed          = errors.New("sign in again with an identity linked to your account")
+)
+
+var usernameDisallowed = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// OIDCLogin is the start of a login at an identity provider.
+type OIDCLogin struct {
+	// URL is where the browser is sent to sign in.
+	URL string
+	// State has to be kept by the browser that opens URL, in a cookie, and
+	// be handed to OIDCCallback together with the state in the callback.
+	State     string
+	ExpiresAt time.Time
+}
+
+type OIDCCallbackResult struct {
+	// Tokens are set when the callback signed the user in.
+	Tokens *todo.Tokens
+	// ChallengeToken is set instead of Tokens for users with two-factor
+	// authentication, like with SignIn.
+	ChallengeToken string
+	// Linked is set when the callback added an identity to a signed-in user.
+	Linked bool
+	// ReauthToken is set when the callback proved it is the signed-in user,
+	// see todo.Reauthentication.
+	ReauthToken string
+}
+
+// OIDCLoginURL starts an authorization code flow with PKCE. With a
+// linkUserId the callback links the external account to that user instead
+// of signing in.
+func (s *AuthService) OIDCLoginURL(ctx context.Context, provider string, linkUserId int) (OIDCLogin, error) {
+	var loginState todo.OIDCLoginState
+	if linkUserId != 0 {
+		loginState.LinkUserId = &linkUserId
+	}
+	return s.startOIDCLogin(ctx, provider, loginState)
+}
+
+// OIDCReauthURL starts a login that makes the user sign in at the provider
+// again, the callback hands out a reauth token instead of a session.
+func (s *AuthService) OIDCReauthURL(ctx context.Context, provider string, userId int) (OIDCLogin, error) {
+	return s.startOIDCLogin(ctx, provider, todo.OIDCLoginState{LinkUserId: &userId, Reauth: true})
+}
+
+func (s *AuthService) startOIDCLogin(ctx context.Context, provider string, loginState todo.OIDCLoginState) (OIDCLogin, error) {
+	p, ok := s.oidcProviders[provider]
+	if !ok {
+		return OIDCLogin{}, ErrUnknownProvider
+	}
+
+	state, err := randomToken(24)
+	if err != nil {
+		return OIDCLogin{}, err
+	}
+	nonce, err := randomToken(24)
+	if err != nil {
+		return OIDCLogin{}, err
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return OIDCLogin{}, err
+	}
+
+	loginState.State = state
+	loginState.Provider = provider
+	loginState.Nonce = nonce
+	loginState.CodeVerifier = verifier
+	loginState.ExpiresAt = time.Now().Add(oidcLoginTTL)
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier, loginState.Reauth)
+	if err != nil {
+		return OIDCLogin{}, err
+	}
+	if err := s.identityRepo.CreateLoginState(loginState); err != nil {
+		return OIDCLogin{}, err
+	}
+	return OIDCLogin{URL: authURL, State: state, ExpiresAt: loginState.ExpiresAt}, nil
+}
+
+// OIDCCallback completes the flow started by OIDCLoginURL. A known identity
+// signs its user in, an unknown one is provisioned as a new user. The state
+// of the callback has to match boundState, the state kept by the browser,
+// or a victim could be made to complete a login started by somebody else.
+// Signing in honours the lockout and the second factor of the user, and
+// requires a verified email like password sign-ins when that is configured.
+// Unknown identities without an unclaimed verified email are not provisioned
+// then.
+func (s *AuthService) OIDCCallback(ctx context.Context, provider, state, boundState, code,
+	clientIP string) (OIDCCallbackResult, error) {
+	p, ok := s.oidcProviders[provider]
+	if !ok {
+		return OIDCCallbackResult{}, ErrUnknownProvider
+	}
+	if boundState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(boundState)) != 1 {
+		return OIDCCallbackResult{}, ErrInvalidLoginState
+	}
+
+	loginState, err := s.identityRepo.UseLoginState(state, provider)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return OIDCCallbackResult{}, ErrInvalidLoginState
+		}
+		return OIDCCallbackResult{}, err
+	}
+
+	claims, err := p.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		return OIDCCallbackResult{}, err
+	}
+
+	identity, err := s.identityRepo.GetByProviderSubject(provider, claims.Subject)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return OIDCCallbackResult{}, err
+	}
+	known := err == nil
+
+	if loginState.Reauth {
+		// max_age=0 asked for a new sign-in, a provider that ignores it must
+		// not turn an old session into a fresh proof
+		if !known || identity.UserId != *loginState.LinkUserId || claims.AuthTime.IsZero() ||
+			time.Since(claims.AuthTime) > reauthMaxAge {
+			return OIDCCallbackResult{}, ErrReauthFailed
+		}
+		token, err := s.issueUserToken(identity.UserId, purposeReauth, "", reauthTTL)
+		if err != nil {
+			return OIDCCallbackResult{}, err
+		}
+		return OIDCCallbackResult{ReauthToken: token}, nil
+	}
+
+	if loginState.LinkUserId != nil {
+		if known {
+			if identity.UserId == *loginState.LinkUserId {
+				return OIDCCallbackResult{Linked: true}, nil
+			}
+			return OIDCCallbackResult{}, ErrIdentityAlreadyLinked
+		}
+		err := s.identityRepo.Create(todo.UserIdentity{
+			UserId:   *loginState.LinkUserId,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    verifiedEmail(claims),
+		})
+		if err != nil {
+			return OIDCCallbackResult{}, err
+		}
+		return OIDCCallbackResult{Linked: true}, nil
+	}
+
+	userId := identity.UserId
+	if !known {
+		userId, err = s.provisionUser(provider, claims)
+		if err != nil {
+			return OIDCCallbackResult{}, err
+		}
+	}
+
+	user, err := s.repo.GetUserById(userId)
+	if err != nil {
+		return OIDCCallbackResult{}, err
+	}
+	if err := s.checkLockout(user.Username, clientIP); err != nil {
+		return OIDCCallbackResult{}, err
+	}
+	if s.requireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return OIDCCallbackResult{}, ErrEmailNotVerified
+	}
+	if user.TOTPEnabled {
+		challenge, err := s.issueUserToken(user.Id, purposeTwoFactorChallenge, "", twoFactorChallengeTTL)
+		if err != nil {
+			return OIDCCallbackResult{}, err
+		}
+		return OIDCCallbackResult{ChallengeToken: challenge}, nil
+	}
+
+	tokens, err := s.startSession(userId)
+	if err != nil {
+		return OIDCCallbackResult{}, err
+	}
+	return OIDCCallbackResult{Tokens: &tokens}, nil
+}
+
+func (s *AuthService) provisionUser(provider string, claims oidc.Claims) (int, error) {
+	email := verifiedEmail(claims)
+	if email != nil {
+		// an address that already belongs to somebody is not taken over, the
+		// owner can link the identity from their account instead
+		if _, err := s.repo.GetUserByEmail(*email); err == nil {
+			email = nil
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+	}
+	// the account could never sign in, so it is not created
+	if email == nil && s.requireVerifiedEmail {
+		return 0, ErrEmailNotVerified
+	}
+
+	username, err := s.freeUsername(provider, claims)
+	if err != nil {
+		return 0, err
+	}
+	name := claims.Name
+	if name == "" {
+		name = username
+	}
+
+	return s.identityRepo.CreateUser(todo.User{
+		Name:     name,
+		Username: username,
+		Email:    email,
+	}, todo.UserIdentity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    email,
+	})
+}
+
+// freeUsername derives a username from the provider claims and adds a
+// numeric suffix until it does not clash with an existing user.
+func (s *AuthService) freeUsername(provider string, claims oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameDisallowed.ReplaceAllString(strings.ToLower(base), "")
+	if base == "" {
+		base = provider + "-user"
+	}
+	if len(base) > maxUsernameLength-4 {
+		base = base[:maxUsernameLength-4]
+	}
+
+	candidate := base
+	for i := 2; i < 1000; i++ {
+		_, err := s.repo.GetUser(candidate)
+		if errors.Is(err, sql.ErrNoRows) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+	return "", errors.New("could not find a free username")
+}
+
+func verifiedEmail(claims oidc.Claims) *string {
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil
+	}
+	email := normalizeEmail(claims.Email)
+	return &email
+}
+
+func (s *AuthService) GetIdentities(userId int) ([]todo.UserIdentity, error) {
+	return s.identityRepo.GetAll(userId)
+}
+
+// UnlinkIdentity removes an external identity, unless it is the only way
+// left for the user to sign in.
+func (s *AuthService) UnlinkIdentity(userId, id int) error {
+	user, err := s.repo.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+	if user.PasswordHash == "" {
+		identities, err := s.identityRepo.GetAll(userId)
+		if err != nil {
+			return err
+		}
+		if len(identities) <= 1 {
+			return ErrLastSignInMethod
+		}
+	}
+	return s.identityRepo.Delete(userId, id)
+}
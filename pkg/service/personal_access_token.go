@@ -0,0 +1,57 @@
+package service
+
+import (
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"strings"
+)
+
+// personalAccessTokenPrefix makes personal access tokens recognisable, both
+// for the auth middleware and for secret scanners.
+const personalAccessTokenPrefix = "tdp_"
+
+func isPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalAccessTokenPrefix)
+}
+
+type PersonalAccessTokenService struct {
+	repo repository.PersonalAccessToken
+}
+
+func NewPersonalAccessTokenService(repo repository.PersonalAccessToken) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{repo: repo}
+}
+
+// Create stores a new token and returns its id together with the raw token,
+// which is not recoverable afterwards.
+func (s *PersonalAccessTokenService) Create(userId int, input todo.CreateTokenInput) (int, string, error) {
+	if err := input.Validate(); err != nil {
+		return 0, "", err
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return 0, "", err
+	}
+	raw := personalAccessTokenPrefix + secret
+
+	id, err := s.repo.Create(todo.PersonalAccessToken{
+		UserId:    userId,
+		Name:      input.Name,
+		TokenHash: hashToken(raw),
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	return id, raw, nil
+}
+
+func (s *PersonalAccessTokenService) GetAll(userId int) ([]todo.PersonalAccessToken, error) {
+	return s.repo.GetAll(userId)
+}
+
+func (s *PersonalAccessTokenService) Delete(userId, id int) error {
+	return s.repo.Delete(userId, id)
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+var (
+	ErrEmailTaken     = errors.New("email address is already in use")
+	ErrReauthRequired = errors.New("confirm it is you with a reauth_token from signing in with your identity provider again, or a two-factor code")
+)
+
+func (s *AuthService) GetProfile(userId int) (todo.Profile, error) {
+	user, err := s.repo.GetUserById(userId)
+	if err != nil {
+		return todo.Profile{}, err
+	}
+	return todo.NewProfile(user), nil
+}
+
+// UpdateProfile changes the name, email and time zone of the user. A new email address
+// has to be verified again, the verification link is sent right away.
+func (s *AuthService) UpdateProfile(userId int, input todo.UpdateProfileInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	if input.Email != nil {
+		email := normalizeEmail(*input.Email)
+		input.Email = &email
+
+		owner, err := s.repo.GetUserByEmail(email)
+		if err == nil && owner.Id != userId {
+			return ErrEmailTaken
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+
+	if err := s.repo.UpdateProfile(userId, input); err != nil {
+		return err
+	}
+	if input.Email == nil {
+		return nil
+	}
+
+	user, err := s.repo.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	if err := s.sendVerificationEmail(user); err != nil {
+		// another link can be requested, the update itself succeeded
+		logrus.Errorf("failed to send verification email to user %d: %s", userId, err.Error())
+	}
+	return nil
+}
+
+// ChangePassword sets a new password after checking the current one. Every
+// other session of the user is signed out, the caller gets a fresh session
+// in exchange for the one it used.
+func (s *AuthService) ChangePassword(identity Identity, input todo.ChangePasswordInput) (todo.Tokens, error) {
+	if err := s.reauthenticate(identity.UserId, input.CurrentPassword, input.Reauthentication); err != nil {
+		return todo.Tokens{}, err
+	}
+	if err := s.rehashPassword(identity.UserId, input.NewPassword); err != nil {
+		return todo.Tokens{}, err
+	}
+	if err := s.revocations.revokeAll(identity.UserId); err != nil {
+		return todo.Tokens{}, err
+	}
+	return s.startSession(identity.UserId)
+}
+
+// DeleteAccount removes the user after checking it is them and returns
+// everything that was stored about them. Lists shared with other users are
+// kept for them.
+func (s *AuthService) DeleteAccount(userId int, input todo.DeleteAccountInput) (todo.AccountExport, error) {
+	if err := s.reauthenticate(userId, input.Password, input.Reauthentication); err != nil {
+		return todo.AccountExport{}, err
+	}
+
+	export, err := s.exportAccount(userId)
+	if err != nil {
+		return todo.AccountExport{}, err
+	}
+
+	// rejects the tokens of the user on this instance right away, other
+	// instances fail to find the user once their cache expires
+	if err := s.revocations.revokeAll(userId); err != nil {
+		return todo.AccountExport{}, err
+	}
+	if err := s.repo.DeleteUser(userId); err != nil {
+		return todo.AccountExport{}, err
+	}
+	return export, nil
+}
+
+// reauthenticate checks the current password. Users that signed up through
+// OpenID Connect have none, a bearer token alone is not enough for them
+// either: they need a reauth token or a current two-factor code. Wrong
+// passwords and codes count towards the sign-in lockout.
+func (s *AuthService) reauthenticate(userId int, password string, proof todo.Reauthentication) error {
+	user, err := s.repo.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+	if user.PasswordHash == "" {
+		switch {
+		case proof.ReauthToken != "":
+			tokenUserId, _, err := s.useUserToken(proof.ReauthToken, purposeReauth)
+			if err != nil {
+				return err
+			}
+			if tokenUserId != userId {
+				return ErrInvalidUserToken
+			}
+			return nil
+		case proof.Code != "" && user.TOTPEnabled:
+			if err := s.checkLockout(user.Username, ""); err != nil {
+				return err
+			}
+			ok, err := s.checkSecondFactor(user, proof.Code)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				if err := s.recordFailedSignIn(user.Username, ""); err != nil {
+					return err
+				}
+				return ErrInvalidTwoFactorCode
+			}
+			return nil
+		default:
+			return ErrReauthRequired
+		}
+	}
+
+	if err := s.checkLockout(user.Username, ""); err != nil {
+		return err
+	}
+	ok, _, err := verifyPassword(password, user.PasswordHash, s.legacySalt)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err := s.recordFailedSignIn(user.Username, ""); err != nil {
+			return err
+		}
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (s *AuthService) exportAccount(userId int) (todo.AccountExport, error) {
+	user, err := s.repo.GetUserById(userId)
+	if err != nil {
+		return todo.AccountExport{}, err
+	}
+	export := todo.AccountExport{
+		Profile:    todo.NewProfile(user),
+		ExportedAt: time.Now().UTC(),
+	}
+
+	lists, err := s.listRepo.GetAll(userId)
+	if err != nil {
+		return todo.AccountExport{}, err
+	}
+	export.Lists = make([]todo.ListExport, 0, len(lists))
+	for _, list := range lists {
+		items, err := s.itemRepo.GetAll(userId, list.Id, todo.ItemFilter{})
+		if err != nil {
+			return todo.AccountExport{}, err
+		}
+		export.Lists = append(export.Lists, todo.ListExport{TodoList: list, Items: items})
+	}
+
+	if export.Identities, err = s.identityRepo.GetAll(userId); err != nil {
+		return todo.AccountExport{}, err
+	}
+	if export.PersonalAccessTokens, err = s.patRepo.GetAll(userId); err != nil {
+		return todo.AccountExport{}, err
+	}
+	return export, nil
+}
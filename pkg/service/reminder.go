@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/notifier"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"time"
+)
+
+var ErrUnknownChannel = errors.New("unknown notification channel")
+
+type ReminderService struct {
+	repo      repository.Reminder
+	itemRepo  repository.TodoItem
+	notifiers map[string]notifier.Notifier
+}
+
+func NewReminderService(repo repository.Reminder, itemRepo repository.TodoItem,
+	notifiers map[string]notifier.Notifier) *ReminderService {
+	return &ReminderService{repo: repo, itemRepo: itemRepo, notifiers: notifiers}
+}
+
+// Create adds a reminder for the user on an item they can read. Reminders
+// relative to the due date need the item to have one.
+func (s *ReminderService) Create(userId, itemId int, input todo.CreateReminderInput) (int, error) {
+	if input.Channel == "" {
+		input.Channel = notifier.ChannelEmail
+	}
+	if _, ok := s.notifiers[input.Channel]; !ok {
+		return 0, ErrUnknownChannel
+	}
+	item, err := s.itemRepo.GetById(userId, itemId)
+	if err != nil {
+		return 0, err
+	}
+	if input.OffsetMinutes != nil && item.DueAt == nil {
+		return 0, todo.ErrReminderWithoutDue
+	}
+	return s.repo.Create(userId, itemId, input)
+}
+
+func (s *ReminderService) GetAllByItem(userId, itemId int) ([]todo.Reminder, error) {
+	if _, err := s.itemRepo.GetById(userId, itemId); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAllByItem(userId, itemId)
+}
+
+func (s *ReminderService) Delete(userId, id int) error {
+	return s.repo.Delete(userId, id)
+}
+
+// ReminderWorker sends due reminders in the background, through the channel
+// picked for each of them.
+type ReminderWorker struct {
+	*deliveryWorker[todo.ReminderDelivery]
+}
+
+func NewReminderWorker(repo repository.Reminder, cfg Config) *ReminderWorker {
+	return &ReminderWorker{&deliveryWorker[todo.ReminderDelivery]{
+		kind:       "reminder",
+		cfg:        cfg.Reminders,
+		claim:      repo.Claim,
+		markSent:   repo.MarkSent,
+		markFailed: repo.MarkFailed,
+		attempt: func(delivery todo.ReminderDelivery) (int, int) {
+			return delivery.Id, delivery.Attempts
+		},
+		send: func(ctx context.Context, delivery todo.ReminderDelivery) error {
+			n, ok := cfg.Notifiers[delivery.Channel]
+			if !ok {
+				return ErrUnknownChannel
+			}
+			return n.Notify(ctx, reminderNotification(delivery))
+		},
+	}}
+}
+
+func reminderNotification(delivery todo.ReminderDelivery) notifier.Notification {
+	body := fmt.Sprintf("Hi %s,\n\nthis is your reminder for \"%s\".", delivery.Username, delivery.Title)
+	if delivery.DueAt != nil {
+		body = fmt.Sprintf("Hi %s,\n\n\"%s\" is due %s.", delivery.Username, delivery.Title, formatDue(delivery))
+	}
+
+	n := notifier.Notification{
+		Event:   "reminder",
+		UserId:  delivery.UserId,
+		Subject: fmt.Sprintf("Reminder: %s", delivery.Title),
+		Body:    body,
+		Data: map[string]interface{}{
+			"reminder_id": delivery.Id,
+			"item_id":     delivery.ItemId,
+			"list_id":     delivery.ListId,
+			"title":       delivery.Title,
+			"due_at":      delivery.DueAt,
+		},
+	}
+	if delivery.Email != nil {
+		n.Email = *delivery.Email
+	}
+	return n
+}
+
+// formatDue writes the due date in the time zone of the user, all-day items
+// only by their date.
+func formatDue(delivery todo.ReminderDelivery) string {
+	if delivery.AllDay {
+		return "on " + delivery.DueAt.UTC().Format("Monday, January 2")
+	}
+	loc, err := time.LoadLocation(delivery.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return "at " + delivery.DueAt.In(loc).Format("Monday, January 2, 15:04 MST")
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"sync"
+	"time"
+)
+
+// revocationCacheTTL bounds how long a negative lookup is trusted. Tokens
+// revoked on this instance are rejected immediately, revocations made by
+// other instances are picked up within this window.
+const revocationCacheTTL = 30 * time.Second
+
+type cachedValidAfter struct {
+	validAfter *time.Time
+	cachedAt   time.Time
+}
+
+// revocationStore keeps the Postgres revocation list behind an in-process
+// cache so the userIdentity middleware does not hit the database on every
+// request.
+type revocationStore struct {
+	repo repository.Revocation
+
+	mu         sync.Mutex
+	revoked    map[string]time.Time // jti -> token expiry
+	notRevoked map[string]time.Time // jti -> time of the lookup
+	validAfter map[int]cachedValidAfter
+	// the same for the token families revoked by revokeAll
+	revokedSessions    map[string]time.Time
+	notRevokedSessions map[string]time.Time
+	lastPrune          time.Time
+}
+
+func newRevocationStore(repo repository.Revocation) *revocationStore {
+	return &revocationStore{
+		repo:       repo,
+		revoked:    make(map[string]time.Time),
+		notRevoked: make(map[string]time.Time),
+		validAfter: make(map[int]cachedValidAfter),
+
+		revokedSessions:    make(map[string]time.Time),
+		notRevokedSessions: make(map[string]time.Time),
+	}
+}
+
+func (s *revocationStore) revoke(jti string, userId int, expiresAt time.Time) error {
+	if err := s.repo.RevokeToken(jti, userId, expiresAt); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[jti] = expiresAt
+	delete(s.notRevoked, jti)
+	return nil
+}
+
+// revokeAll revokes the tokens of the user. Access tokens are rejected by the
+// session they belong to, the cut-off on their iat only has a precision of
+// seconds.
+func (s *revocationStore) revokeAll(userId int) error {
+	sessionsExpireAt := time.Now().Add(accessTokenTTL)
+	validAfter, families, err := s.repo.RevokeAllTokens(userId, sessionsExpireAt)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validAfter[userId] = cachedValidAfter{validAfter: &validAfter, cachedAt: time.Now()}
+	for _, familyId := range families {
+		s.revokedSessions[familyId] = sessionsExpireAt
+		delete(s.notRevokedSessions, familyId)
+	}
+	return nil
+}
+
+func (s *revocationStore) isRevoked(jti string) (bool, error) {
+	return s.lookup(jti, s.revoked, s.notRevoked, s.repo.IsTokenRevoked)
+}
+
+func (s *revocationStore) isSessionRevoked(familyId string) (bool, error) {
+	return s.lookup(familyId, s.revokedSessions, s.notRevokedSessions, s.repo.IsSessionRevoked)
+}
+
+func (s *revocationStore) lookup(id string, revokedIds, notRevokedIds map[string]time.Time,
+	isRevoked func(string) (bool, error)) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	s.pruneLocked(now)
+	if _, ok := revokedIds[id]; ok {
+		s.mu.Unlock()
+		return true, nil
+	}
+	if checkedAt, ok := notRevokedIds[id]; ok && now.Sub(checkedAt) < revocationCacheTTL {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.mu.Unlock()
+
+	revoked, err := isRevoked(id)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if revoked {
+		// the expiry is unknown here, keep it until the next prune after the ttl
+		revokedIds[id] = now.Add(revocationCacheTTL)
+	} else {
+		notRevokedIds[id] = now
+	}
+	return revoked, nil
+}
+
+func (s *revocationStore) tokensValidAfter(userId int) (*time.Time, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	if cached, ok := s.validAfter[userId]; ok && now.Sub(cached.cachedAt) < revocationCacheTTL {
+		s.mu.Unlock()
+		return cached.validAfter, nil
+	}
+	s.mu.Unlock()
+
+	validAfter, err := s.repo.GetTokensValidAfter(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validAfter[userId] = cachedValidAfter{validAfter: validAfter, cachedAt: now}
+	return validAfter, nil
+}
+
+func (s *revocationStore) pruneLocked(now time.Time) {
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	s.lastPrune = now
+
+	for _, revokedIds := range []map[string]time.Time{s.revoked, s.revokedSessions} {
+		for id, expiresAt := range revokedIds {
+			if now.After(expiresAt) {
+				delete(revokedIds, id)
+			}
+		}
+	}
+	for _, notRevokedIds := range []map[string]time.Time{s.notRevoked, s.notRevokedSessions} {
+		for id, checkedAt := range notRevokedIds {
+			if now.Sub(checkedAt) >= revocationCacheTTL {
+				delete(notRevokedIds, id)
+			}
+		}
+	}
+	for userId, cached := range s.validAfter {
+		if now.Sub(cached.cachedAt) >= revocationCacheTTL {
+			delete(s.validAfter, userId)
+		}
+	}
+}
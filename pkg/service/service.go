@@ -1,14 +1,59 @@
 package service
 
 import (
+	"context"
 	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/mailer"
+	"github.com/Olmosbek510/todo-app/pkg/notifier"
+	"github.com/Olmosbek510/todo-app/pkg/oidc"
 	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"io"
 )
 
 type Authorization interface {
 	CreateUser(user todo.User) (int, error)
-	GenerateToken(username string, password string) (string, error)
-	ParseToken(token string) (int, error)
+	SignIn(username, password, clientIP string) (todo.SignInResult, error)
+	VerifyTwoFactor(challengeToken, code, clientIP string) (todo.Tokens, error)
+	RefreshTokens(refreshToken string) (todo.Tokens, error)
+	ParseToken(token string) (Identity, error)
+	SignOut(identity Identity) error
+	SignOutAll(userId int) error
+	JWKS() JSONWebKeySet
+	SendVerificationEmail(email string) error
+	VerifyEmail(token string) error
+	RequestPasswordReset(email string) error
+	ResetPassword(token, password string) error
+	EnrollTOTP(userId int) (todo.TwoFactorEnrollment, error)
+	ConfirmTOTP(userId int, code string) ([]string, error)
+	DisableTOTP(userId int, code string) error
+	OIDCLoginURL(ctx context.Context, provider string, linkUserId int) (OIDCLogin, error)
+	OIDCReauthURL(ctx context.Context, provider string, userId int) (OIDCLogin, error)
+	OIDCCallback(ctx context.Context, provider, state, boundState, code, clientIP string) (OIDCCallbackResult, error)
+	GetIdentities(userId int) ([]todo.UserIdentity, error)
+	UnlinkIdentity(userId, id int) error
+	GetProfile(userId int) (todo.Profile, error)
+	UpdateProfile(userId int, input todo.UpdateProfileInput) error
+	ChangePassword(identity Identity, input todo.ChangePasswordInput) (todo.Tokens, error)
+	DeleteAccount(userId int, input todo.DeleteAccountInput) (todo.AccountExport, error)
+}
+
+type PersonalAccessToken interface {
+	Create(userId int, input todo.CreateTokenInput) (int, string, error)
+	GetAll(userId int) ([]todo.PersonalAccessToken, error)
+	Delete(userId, id int) error
+}
+
+type Workspace interface {
+	Create(userId int, input todo.CreateWorkspaceInput) (int, error)
+	GetAll(userId int) ([]todo.Workspace, error)
+	GetById(userId, id int) (todo.Workspace, error)
+	Update(userId, id int, input todo.UpdateWorkspaceInput) error
+	Delete(userId, id int) error
+	GetLists(userId, id int) ([]todo.TodoList, error)
+	GetMembers(userId, id int) ([]todo.WorkspaceMember, error)
+	AddMember(userId, id int, input todo.AddMemberInput) error
+	UpdateMemberRole(userId, id, memberId int, input todo.UpdateMemberInput) error
+	RemoveMember(userId, id, memberId int) error
 }
 
 type TodoList interface {
@@ -17,26 +62,152 @@ type TodoList interface {
 	GetById(userId, id int) (todo.TodoList, error)
 	DeleteById(userId, listId int) error
 	Update(userId, listId int, newListBody todo.UpdateListInput) error
+	Move(userId, listId int, input todo.MoveInput) error
+}
+
+type ListMember interface {
+	GetAll(userId, listId int) ([]todo.ListMember, error)
+	Add(userId, listId int, input todo.AddMemberInput) error
+	UpdateRole(userId, listId, memberId int, input todo.UpdateMemberInput) error
+	Remove(userId, listId, memberId int) error
+}
+
+type Invitation interface {
+	Create(userId, listId int, input todo.CreateInvitationInput) (int, string, error)
+	GetAllByList(userId, listId int) ([]todo.Invitation, error)
+	Revoke(userId, listId, id int) error
+	GetPending(userId int) ([]todo.Invitation, error)
+	Accept(userId, id int) error
+	Decline(userId, id int) error
+	AcceptToken(userId int, token string) (int, error)
+}
+
+type ShareLink interface {
+	Create(userId, listId int, input todo.CreateShareLinkInput) (int, string, error)
+	GetAll(userId, listId int) ([]todo.ShareLink, error)
+	Revoke(userId, listId, id int) error
+	Open(token, password, clientIP string) (todo.SharedList, error)
+	URL(token string) string
 }
 
 type TodoItem interface {
 	Create(userId, listId int, todoItem todo.TodoItem) (int, error)
-	GetAll(userId, listId int) ([]todo.TodoItem, error)
+	GetAll(userId, listId int, filter todo.ItemFilter) ([]todo.TodoItem, error)
 	GetById(userId, itemId int) (todo.TodoItem, error)
 	Delete(userId, itemId int) error
 	Update(userId, listId int, itemInput todo.UpdateItemInput) error
+	GetDue(userId int, due string) ([]todo.TodoItem, error)
+	Move(userId, itemId int, input todo.MoveItemInput) error
+	Copy(userId, itemId int, input todo.MoveItemInput) (int, error)
+	Bulk(userId int, input todo.BulkItemInput) (todo.BulkItemReport, error)
+	GetCompletions(userId, itemId int) ([]todo.ItemCompletion, error)
+}
+
+type Reminder interface {
+	Create(userId, itemId int, input todo.CreateReminderInput) (int, error)
+	GetAllByItem(userId, itemId int) ([]todo.Reminder, error)
+	Delete(userId, id int) error
+}
+
+type Comment interface {
+	Create(userId, itemId int, input todo.CreateCommentInput) (int, error)
+	GetAll(userId, itemId, after, limit int) (todo.CommentPage, error)
+	Update(userId, id int, input todo.UpdateCommentInput) error
+	Delete(userId, id int) error
+}
+
+type Attachment interface {
+	MaxSize() int64
+	Create(ctx context.Context, userId, itemId int, filename string, size int64, content io.Reader) (todo.Attachment, error)
+	GetAll(userId, itemId int) ([]todo.Attachment, error)
+	GetById(userId, id int) (todo.Attachment, error)
+	Delete(userId, id int) error
+	GetUsage(userId int) (todo.StorageUsage, error)
+	Open(ctx context.Context, token string) (todo.Attachment, io.ReadCloser, error)
+}
+
+type Trash interface {
+	GetAll(userId int) (todo.Trash, error)
+	RestoreList(userId, listId int) error
+	RestoreItem(userId, itemId int) error
+	DeleteList(userId, listId int) error
+	DeleteItem(userId, itemId int) error
+}
+
+type Label interface {
+	Create(userId int, input todo.CreateLabelInput) (int, error)
+	GetAll(userId int) ([]todo.Label, error)
+	GetById(userId, id int) (todo.Label, error)
+	Update(userId, id int, input todo.UpdateLabelInput) error
+	Delete(userId, id int) error
+	AddToItem(userId, itemId, labelId int) error
+	RemoveFromItem(userId, itemId, labelId int) error
+}
+
+type Config struct {
+	Keys *KeySet
+	// LegacyPasswordSalt verifies password hashes created before argon2id
+	// was introduced. Leave it empty once no such hashes are left.
+	LegacyPasswordSalt string
+
+	Mailer mailer.Mailer
+	// PublicURL is the address the app is reachable at, used for links in
+	// emails.
+	PublicURL string
+	// RequireVerifiedEmail makes an email mandatory at sign-up and blocks
+	// sign-in until it is verified.
+	RequireVerifiedEmail bool
+	// TOTPIssuer names the app in authenticator apps.
+	TOTPIssuer string
+	// OIDCProviders are the identity providers users can sign in with, keyed
+	// by the name used in the login URL.
+	OIDCProviders map[string]*oidc.Provider
+	// Lockout throttles repeated failed sign-ins.
+	Lockout LockoutConfig
+	// MaxItemDepth limits how many levels of subtasks an item can have,
+	// counting top-level items as the first. 0 allows any depth.
+	MaxItemDepth int
+	// Notifiers deliver reminders and mentions, keyed by the channel users
+	// pick.
+	Notifiers map[string]notifier.Notifier
+	Reminders DeliveryConfig
+	Comments  CommentConfig
+	// Attachments holds where uploaded files are stored and how much of it
+	// users get.
+	Attachments AttachmentConfig
+	Trash       TrashConfig
 }
 
 type Service struct {
 	Authorization
+	PersonalAccessToken
+	Workspace
 	TodoList
+	ListMember
+	Invitation
+	ShareLink
 	TodoItem
+	Label
+	Reminder
+	Comment
+	Attachment
+	Trash
 }
 
-func NewService(repos *repository.Repository) *Service {
+func NewService(repos *repository.Repository, cfg Config) *Service {
 	return &Service{
-		Authorization: NewAuthService(repos.Authorization),
-		TodoList:      NewTodoListService(repos.TodoList),
-		TodoItem:      NewTodoItemService(repos.TodoItem, repos.TodoList),
+		Authorization:       NewAuthService(repos, cfg),
+		PersonalAccessToken: NewPersonalAccessTokenService(repos.PersonalAccessToken),
+		Workspace:           NewWorkspaceService(repos.Workspace, repos.TodoList, repos.Authorization),
+		TodoList:            NewTodoListService(repos.TodoList, repos.Workspace),
+		ListMember:          NewListMemberService(repos.ListMember, repos.Authorization),
+		Invitation:          NewInvitationService(repos, cfg),
+		ShareLink:           NewShareLinkService(repos, cfg),
+		TodoItem:            NewTodoItemService(repos.TodoItem, repos.TodoList, repos.Authorization, cfg.MaxItemDepth),
+		Label:               NewLabelService(repos.Label, repos.TodoItem, repos.TodoList),
+		Reminder:            NewReminderService(repos.Reminder, repos.TodoItem, cfg.Notifiers),
+		Comment:             NewCommentService(repos, cfg),
+		Attachment:          NewAttachmentService(repos, cfg),
+		Trash:               NewTrashService(repos.Trash, cfg),
 	}
 }
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrShareLinkNotFound     = errors.New("share link does not exist or has expired")
+	ErrSharePasswordRequired = errors.New("share link is protected by a password")
+	ErrInvalidSharePassword  = errors.New("wrong share link password")
+)
+
+type ShareLinkService struct {
+	repo       repository.ShareLink
+	memberRepo repository.ListMember
+	auditRepo  repository.Audit
+	throttle   *loginThrottle
+	publicURL  string
+}
+
+func NewShareLinkService(repos *repository.Repository, cfg Config) *ShareLinkService {
+	return &ShareLinkService{
+		repo:       repos.ShareLink,
+		memberRepo: repos.ListMember,
+		auditRepo:  repos.Audit,
+		throttle:   newLoginThrottle(repos.LoginAttempt, cfg.Lockout),
+		publicURL:  strings.TrimSuffix(cfg.PublicURL, "/"),
+	}
+}
+
+// URL is the public address of the share link with the given token.
+func (s *ShareLinkService) URL(token string) string {
+	return s.publicURL + "/share/" + token
+}
+
+// Create adds a share link to the list and returns its id together with the
+// raw token, which is not recoverable afterwards. Only owners can share.
+func (s *ShareLinkService) Create(userId, listId int, input todo.CreateShareLinkInput) (int, string, error) {
+	if err := input.Validate(); err != nil {
+		return 0, "", err
+	}
+	if err := requireListOwner(s.memberRepo, userId, listId); err != nil {
+		return 0, "", err
+	}
+
+	token, err := randomToken(24)
+	if err != nil {
+		return 0, "", err
+	}
+	link := todo.ShareLink{
+		ListId:    listId,
+		CreatedBy: userId,
+		TokenHash: hashToken(token),
+		ExpiresAt: input.ExpiresAt,
+	}
+	if input.Password != "" {
+		passwordHash, err := hashPassword(input.Password)
+		if err != nil {
+			return 0, "", err
+		}
+		link.PasswordHash = &passwordHash
+	}
+
+	id, err := s.repo.Create(link)
+	if err != nil {
+		return 0, "", err
+	}
+	return id, token, nil
+}
+
+func (s *ShareLinkService) GetAll(userId, listId int) ([]todo.ShareLink, error) {
+	if err := requireListOwner(s.memberRepo, userId, listId); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAll(listId)
+}
+
+func (s *ShareLinkService) Revoke(userId, listId, id int) error {
+	if err := requireListOwner(s.memberRepo, userId, listId); err != nil {
+		return err
+	}
+	return s.repo.Delete(listId, id)
+}
+
+// Open returns the list behind a share link. It does not require an account,
+// the token and the optional password are all the caller has to know. Wrong
+// passwords lock the link and the client address out like failed sign-ins,
+// before any more passwords are hashed.
+func (s *ShareLinkService) Open(token, password, clientIP string) (todo.SharedList, error) {
+	link, err := s.repo.GetByHash(hashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return todo.SharedList{}, ErrShareLinkNotFound
+		}
+		return todo.SharedList{}, err
+	}
+
+	if link.PasswordHash != nil {
+		if password == "" {
+			return todo.SharedList{}, ErrSharePasswordRequired
+		}
+		keys := s.throttledKeys(link.Id, clientIP)
+		if err := s.throttle.check(keys); err != nil {
+			return todo.SharedList{}, err
+		}
+		ok, _, err := verifyPassword(password, *link.PasswordHash, "")
+		if err != nil {
+			return todo.SharedList{}, err
+		}
+		if !ok {
+			if err := s.recordFailure(link, keys, clientIP); err != nil {
+				return todo.SharedList{}, err
+			}
+			return todo.SharedList{}, ErrInvalidSharePassword
+		}
+		if s.throttle.cfg.UsernameThreshold > 0 {
+			if err := s.throttle.reset(shareLinkKey(link.Id)); err != nil {
+				return todo.SharedList{}, err
+			}
+		}
+	}
+	list, err := s.repo.GetSharedList(link.ListId)
+	if errors.Is(err, sql.ErrNoRows) {
+		// the list went to the trash since the link was looked up
+		return list, ErrShareLinkNotFound
+	}
+	return list, err
+}
+
+func shareLinkKey(id int) string {
+	return "share:" + strconv.Itoa(id)
+}
+
+// throttledKeys limits the link like a username, the client address shares
+// its limit with sign-ins.
+func (s *ShareLinkService) throttledKeys(id int, ip string) []string {
+	keys := make([]string, 0, 2)
+	if s.throttle.cfg.UsernameThreshold > 0 {
+		keys = append(keys, shareLinkKey(id))
+	}
+	if s.throttle.cfg.IPThreshold > 0 && ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
+
+func (s *ShareLinkService) recordFailure(link todo.ShareLink, keys []string, ip string) error {
+	for _, key := range keys {
+		threshold := s.throttle.cfg.UsernameThreshold
+		if strings.HasPrefix(key, "ip:") {
+			threshold = s.throttle.cfg.IPThreshold
+		}
+
+		failures, delay, err := s.throttle.recordFailure(key, threshold)
+		if err != nil {
+			return err
+		}
+		if delay > 0 {
+			recordAudit(s.auditRepo, todo.AuditEntry{
+				UserId: &link.CreatedBy,
+				Event:  todo.AuditShareLinkLocked,
+				IP:     ip,
+				Detail: fmt.Sprintf("%s locked for %s after %d wrong share link passwords", key, delay, failures),
+			})
+		}
+	}
+	return nil
+}
@@ -2,16 +2,235 @@ package service
 
 import (
 	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/position"
 	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"github.com/Olmosbek510/todo-app/pkg/rrule"
+	"time"
+)
+
+var (
+	ErrInvalidParent = repository.ErrInvalidParent
+	ErrItemCycle     = repository.ErrItemCycle
+	ErrMaxDepth      = repository.ErrMaxDepth
+	ErrInvalidAnchor = repository.ErrInvalidAnchor
+	// ErrPositionOrder and ErrPositionExhausted mean the stored order has no
+	// room for the row, like when two rows ended up at the same position.
+	ErrPositionOrder     = position.ErrOrder
+	ErrPositionExhausted = position.ErrExhausted
+
+	ErrInvalidRecurrence = rrule.ErrInvalid
 )
 
 type TodoItemService struct {
 	repo     repository.TodoItem
 	listRepo repository.TodoList
+	userRepo repository.Authorization
+	// maxDepth limits how deeply subtasks can be nested, 0 means no limit.
+	maxDepth int
 }
 
+// Update checks changed dates and rules against the ones already stored.
+// Completing a recurring item is done last, after the other changes, in the
+// same transaction.
 func (t *TodoItemService) Update(userId, itemId int, itemInput todo.UpdateItemInput) error {
-	return t.repo.Update(userId, itemId, itemInput)
+	op, err := t.prepareUpdate(userId, itemId, itemInput)
+	if err != nil {
+		return err
+	}
+	return t.repo.Apply(userId, op, t.maxDepth)
+}
+
+// prepareUpdate turns an update into the operation the repository applies.
+// All three date fields are written together so that switching an item to
+// all-day also drops the time of day from its dates. Marking a recurring
+// item done becomes the completion of its current occurrence.
+func (t *TodoItemService) prepareUpdate(userId, itemId int, itemInput todo.UpdateItemInput) (todo.ItemOperation, error) {
+	op := todo.ItemOperation{ItemId: itemId}
+	var item todo.TodoItem
+	if itemInput.HasDates() || itemInput.Recurrence != nil || itemInput.Done != nil && *itemInput.Done {
+		var err error
+		if item, err = t.repo.GetById(userId, itemId); err != nil {
+			return op, err
+		}
+	}
+
+	if itemInput.HasDates() || itemInput.Recurrence != nil {
+		if itemInput.StartAt.Set {
+			item.StartAt = itemInput.StartAt.Time
+		}
+		if itemInput.DueAt.Set {
+			item.DueAt = itemInput.DueAt.Time
+		}
+		if itemInput.AllDay != nil {
+			item.AllDay = *itemInput.AllDay
+		}
+		if itemInput.Recurrence != nil {
+			item.Recurrence = itemInput.Recurrence
+			if *item.Recurrence == "" {
+				item.Recurrence = nil
+			}
+		}
+		item.NormalizeDates()
+		if err := normalizeRecurrence(&item); err != nil {
+			return op, err
+		}
+		if err := item.Validate(); err != nil {
+			return op, err
+		}
+		itemInput.StartAt = todo.NullableTime{Set: true, Time: item.StartAt}
+		itemInput.DueAt = todo.NullableTime{Set: true, Time: item.DueAt}
+		itemInput.AllDay = &item.AllDay
+		if itemInput.Recurrence != nil && item.Recurrence != nil {
+			itemInput.Recurrence = item.Recurrence
+			timeZone, err := t.userTimeZone(userId)
+			if err != nil {
+				return op, err
+			}
+			itemInput.RecurrenceTimeZone, item.RecurrenceTimeZone = timeZone, timeZone
+		}
+	}
+
+	if itemInput.Done != nil && *itemInput.Done && item.Recurrence != nil && !item.Done {
+		itemInput.Done = nil
+		// the occurrence is counted from the stored item, a new rule starts
+		// again with the first one
+		if itemInput.Recurrence != nil {
+			item.Occurrence = 1
+		}
+		next, err := nextOccurrence(item)
+		if err != nil {
+			return op, err
+		}
+		op.Complete = &todo.OccurrenceCompletion{Occurrence: item.Occurrence, Next: next}
+	}
+	if itemInput.HasValues() {
+		op.Update = &itemInput
+	}
+	return op, nil
+}
+
+// Bulk applies one action to many items in a single transaction and reports
+// the outcome for every item. In atomic mode nothing is changed once an item
+// fails, the other items are reported as skipped.
+func (t *TodoItemService) Bulk(userId int, input todo.BulkItemInput) (todo.BulkItemReport, error) {
+	if input.Mode == "" {
+		input.Mode = todo.BulkAtomic
+	}
+	atomic := input.Mode == todo.BulkAtomic
+	report := todo.BulkItemReport{Mode: input.Mode, Results: make([]todo.BulkItemResult, len(input.Ids))}
+	for i, id := range input.Ids {
+		report.Results[i] = todo.BulkItemResult{Id: id, Status: todo.BulkStatusSkipped}
+	}
+
+	ops := make([]todo.ItemOperation, 0, len(input.Ids))
+	// results[opResults[j]] is the result of ops[j]
+	opResults := make([]int, 0, len(input.Ids))
+	for i, id := range input.Ids {
+		op, err := t.prepareBulk(userId, id, input)
+		if err != nil {
+			report.Results[i].Status, report.Results[i].Err = todo.BulkStatusFailed, err
+			if atomic {
+				return report, nil
+			}
+			continue
+		}
+		ops = append(ops, op)
+		opResults = append(opResults, i)
+	}
+
+	errs, err := t.repo.Bulk(userId, ops, atomic, t.maxDepth)
+	if err != nil {
+		return report, err
+	}
+	failed := false
+	for j, opErr := range errs {
+		if opErr != nil {
+			failed = true
+			report.Results[opResults[j]].Status, report.Results[opResults[j]].Err = todo.BulkStatusFailed, opErr
+		}
+	}
+	if atomic && failed {
+		return report, nil
+	}
+	for j, opErr := range errs {
+		if opErr == nil {
+			report.Results[opResults[j]].Status = todo.BulkStatusOk
+		}
+	}
+	return report, nil
+}
+
+// prepareBulk prepares the action for one item. Every move gets the anchors
+// of the input, the repository chains them when it applies them.
+func (t *TodoItemService) prepareBulk(userId, itemId int, input todo.BulkItemInput) (todo.ItemOperation, error) {
+	done := true
+	switch input.Action {
+	case todo.BulkDelete:
+		return todo.ItemOperation{ItemId: itemId, Delete: true}, nil
+	case todo.BulkMove:
+		move := *input.Move
+		return todo.ItemOperation{ItemId: itemId, Move: &move}, nil
+	case todo.BulkReopen:
+		done = false
+		fallthrough
+	case todo.BulkComplete:
+		return t.prepareUpdate(userId, itemId, todo.UpdateItemInput{Done: &done})
+	}
+	return t.prepareUpdate(userId, itemId, *input.Update)
+}
+
+// normalizeRecurrence checks the rule of the item and stores it the way
+// rrule formats it.
+func normalizeRecurrence(item *todo.TodoItem) error {
+	if item.Recurrence == nil {
+		return nil
+	}
+	rule, err := rrule.Parse(*item.Recurrence)
+	if err != nil {
+		return err
+	}
+	formatted := rule.String()
+	item.Recurrence = &formatted
+	return nil
+}
+
+// userTimeZone returns the time zone of the user, which new rules recur in.
+func (t *TodoItemService) userTimeZone(userId int) (*string, error) {
+	user, err := t.userRepo.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	return &user.TimeZone, nil
+}
+
+// nextOccurrence returns the occurrence following the current one of the
+// recurring item, or nil when its rule has run out. Timed items recur in the
+// time zone stored with the rule so that they keep their time of day across
+// daylight saving changes, no matter who completes them. All-day items
+// recur on their UTC dates.
+func nextOccurrence(item todo.TodoItem) (*todo.Occurrence, error) {
+	rule, err := rrule.Parse(*item.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := time.UTC
+	if !item.AllDay && item.RecurrenceTimeZone != nil {
+		if loc, err = time.LoadLocation(*item.RecurrenceTimeZone); err != nil {
+			return nil, err
+		}
+	}
+
+	dueAt, ok := rule.Next(item.DueAt.In(loc), item.Occurrence)
+	if !ok {
+		return nil, nil
+	}
+	next := &todo.Occurrence{DueAt: dueAt.UTC()}
+	if item.StartAt != nil {
+		startAt := dueAt.Add(item.StartAt.Sub(*item.DueAt)).UTC()
+		next.StartAt = &startAt
+	}
+	return next, nil
 }
 
 func (t *TodoItemService) Delete(userId, itemId int) error {
@@ -22,8 +241,30 @@ func (t *TodoItemService) GetById(userId, itemId int) (todo.TodoItem, error) {
 	return t.repo.GetById(userId, itemId)
 }
 
-func (t *TodoItemService) GetAll(userId, listId int) ([]todo.TodoItem, error) {
-	return t.repo.GetAll(userId, listId)
+func (t *TodoItemService) GetAll(userId, listId int, filter todo.ItemFilter) ([]todo.TodoItem, error) {
+	return t.repo.GetAll(userId, listId, filter)
+}
+
+// GetCompletions returns the completion history of a recurring item.
+func (t *TodoItemService) GetCompletions(userId, itemId int) ([]todo.ItemCompletion, error) {
+	return t.repo.GetCompletions(userId, itemId)
+}
+
+// Move places the item next to other items of its list or of the list it
+// is moved to.
+func (t *TodoItemService) Move(userId, itemId int, input todo.MoveItemInput) error {
+	return t.repo.Move(userId, itemId, input)
+}
+
+// Copy copies the item with its subtasks and returns the id of the copy.
+func (t *TodoItemService) Copy(userId, itemId int, input todo.MoveItemInput) (int, error) {
+	return t.repo.Copy(userId, itemId, input)
+}
+
+// GetDue returns the open items due in the period named by due, one of the
+// todo.Due* filters.
+func (t *TodoItemService) GetDue(userId int, due string) ([]todo.TodoItem, error) {
+	return t.repo.GetDue(userId, due)
 }
 
 func (t *TodoItemService) Create(userId int, listId int, todoItem todo.TodoItem) (int, error) {
@@ -32,9 +273,23 @@ func (t *TodoItemService) Create(userId int, listId int, todoItem todo.TodoItem)
 		// the listRepo does not exist or does not belong to user
 		return 0, err
 	}
-	return t.repo.Create(listId, todoItem)
+	todoItem.NormalizeDates()
+	if err := normalizeRecurrence(&todoItem); err != nil {
+		return 0, err
+	}
+	if err := todoItem.Validate(); err != nil {
+		return 0, err
+	}
+	todoItem.RecurrenceTimeZone = nil
+	if todoItem.Recurrence != nil {
+		if todoItem.RecurrenceTimeZone, err = t.userTimeZone(userId); err != nil {
+			return 0, err
+		}
+	}
+	return t.repo.Create(userId, listId, todoItem, t.maxDepth)
 }
 
-func NewTodoItemService(repo repository.TodoItem, listRepo repository.TodoList) *TodoItemService {
-	return &TodoItemService{repo: repo, listRepo: listRepo}
+func NewTodoItemService(repo repository.TodoItem, listRepo repository.TodoList, userRepo repository.Authorization,
+	maxDepth int) *TodoItemService {
+	return &TodoItemService{repo: repo, listRepo: listRepo, userRepo: userRepo, maxDepth: maxDepth}
 }
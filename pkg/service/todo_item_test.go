@@ -0,0 +1,117 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"reflect"
+	"testing"
+)
+
+var errApply = errors.New("apply failed")
+
+// fakeItemRepo knows the items in items and fails the operations on the
+// items in applyErrs when they are applied.
+type fakeItemRepo struct {
+	repository.TodoItem
+	items     map[int]todo.TodoItem
+	applyErrs map[int]error
+
+	// applied are the items of the operations handed to Bulk.
+	applied []int
+}
+
+func (r *fakeItemRepo) GetById(userId, itemId int) (todo.TodoItem, error) {
+	item, ok := r.items[itemId]
+	if !ok {
+		return todo.TodoItem{}, sql.ErrNoRows
+	}
+	return item, nil
+}
+
+func (r *fakeItemRepo) Bulk(userId int, ops []todo.ItemOperation, atomic bool, maxDepth int) ([]error, error) {
+	results := make([]error, len(ops))
+	for i, op := range ops {
+		r.applied = append(r.applied, op.ItemId)
+		results[i] = r.applyErrs[op.ItemId]
+		if results[i] != nil && atomic {
+			break
+		}
+	}
+	return results, nil
+}
+
+func TestTodoItemServiceBulk(t *testing.T) {
+	const (
+		ok      = todo.BulkStatusOk
+		failed  = todo.BulkStatusFailed
+		skipped = todo.BulkStatusSkipped
+	)
+	items := map[int]todo.TodoItem{1: {Id: 1}, 3: {Id: 3}, 4: {Id: 4}}
+
+	tests := []struct {
+		name        string
+		ids         []int
+		mode        string
+		applyErrs   map[int]error
+		wantStatus  []string
+		wantErrs    []error
+		wantApplied []int
+	}{
+		{
+			name:        "all succeed",
+			ids:         []int{1, 3, 4},
+			mode:        todo.BulkPartial,
+			wantStatus:  []string{ok, ok, ok},
+			wantErrs:    []error{nil, nil, nil},
+			wantApplied: []int{1, 3, 4},
+		},
+		{
+			name:        "partial reports every failure and applies the rest",
+			ids:         []int{1, 2, 3, 4},
+			mode:        todo.BulkPartial,
+			applyErrs:   map[int]error{3: errApply},
+			wantStatus:  []string{ok, failed, failed, ok},
+			wantErrs:    []error{nil, sql.ErrNoRows, errApply, nil},
+			wantApplied: []int{1, 3, 4},
+		},
+		{
+			name:        "atomic skips everything after a failed preparation",
+			ids:         []int{1, 2, 3, 4},
+			mode:        todo.BulkAtomic,
+			wantStatus:  []string{skipped, failed, skipped, skipped},
+			wantErrs:    []error{nil, sql.ErrNoRows, nil, nil},
+			wantApplied: nil,
+		},
+		{
+			name:        "atomic by default skips everything when an item fails to apply",
+			ids:         []int{1, 3, 4},
+			mode:        "",
+			applyErrs:   map[int]error{3: errApply},
+			wantStatus:  []string{skipped, failed, skipped},
+			wantErrs:    []error{nil, errApply, nil},
+			wantApplied: []int{1, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeItemRepo{items: items, applyErrs: tt.applyErrs}
+			service := NewTodoItemService(repo, nil, nil, 0)
+
+			report, err := service.Bulk(1, todo.BulkItemInput{Ids: tt.ids, Action: todo.BulkComplete, Mode: tt.mode})
+			if err != nil {
+				t.Fatalf("Bulk() error = %v", err)
+			}
+			for i, result := range report.Results {
+				if result.Id != tt.ids[i] || result.Status != tt.wantStatus[i] || !errors.Is(result.Err, tt.wantErrs[i]) {
+					t.Errorf("result %d = %+v, want item %d %s with %v", i, result, tt.ids[i], tt.wantStatus[i],
+						tt.wantErrs[i])
+				}
+			}
+			if !reflect.DeepEqual(repo.applied, tt.wantApplied) {
+				t.Errorf("applied %v, want %v", repo.applied, tt.wantApplied)
+			}
+		})
+	}
+}
@@ -6,7 +6,8 @@ import (
 )
 
 type TodoListService struct {
-	repo repository.TodoList
+	repo          repository.TodoList
+	workspaceRepo repository.Workspace
 }
 
 func (t *TodoListService) Update(userId int, listId int, newListBody todo.UpdateListInput) error {
@@ -28,10 +29,24 @@ func (t *TodoListService) GetAll(userId int) ([]todo.TodoList, error) {
 	return t.repo.GetAll(userId)
 }
 
+// Move changes where the list is shown among the lists of the user.
+func (t *TodoListService) Move(userId, listId int, input todo.MoveInput) error {
+	return t.repo.Move(userId, listId, input)
+}
+
+// Create adds the list to the workspace given in list or, without one, to
+// the personal workspace of the user.
 func (t *TodoListService) Create(userId int, list todo.TodoList) (int, error) {
+	if list.WorkspaceId == 0 {
+		workspaceId, err := t.workspaceRepo.GetPersonalId(userId)
+		if err != nil {
+			return 0, err
+		}
+		list.WorkspaceId = workspaceId
+	}
 	return t.repo.Create(userId, list)
 }
 
-func NewTodoListService(repo repository.TodoList) *TodoListService {
-	return &TodoListService{repo: repo}
+func NewTodoListService(repo repository.TodoList, workspaceRepo repository.Workspace) *TodoListService {
+	return &TodoListService{repo: repo, workspaceRepo: workspaceRepo}
 }
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// TrashConfig tunes how long deleted lists and items can be restored.
+type TrashConfig struct {
+	// Retention is how long things stay in the trash before they are
+	// deleted for good.
+	Retention time.Duration
+	// PurgeInterval is how often the trash is emptied of what is past the
+	// retention.
+	PurgeInterval time.Duration
+}
+
+type TrashService struct {
+	repo      repository.Trash
+	retention time.Duration
+}
+
+func NewTrashService(repo repository.Trash, cfg Config) *TrashService {
+	return &TrashService{repo: repo, retention: cfg.Trash.Retention}
+}
+
+// GetAll returns the trash of the user with the time everything in it will
+// be deleted for good.
+func (s *TrashService) GetAll(userId int) (todo.Trash, error) {
+	trash, err := s.repo.GetAll(userId)
+	if err != nil {
+		return trash, err
+	}
+	for i := range trash.Lists {
+		trash.Lists[i].PurgeAt = trash.Lists[i].DeletedAt.Add(s.retention)
+	}
+	for i := range trash.Items {
+		trash.Items[i].PurgeAt = trash.Items[i].DeletedAt.Add(s.retention)
+	}
+	return trash, nil
+}
+
+func (s *TrashService) RestoreList(userId, listId int) error {
+	return s.repo.RestoreList(userId, listId)
+}
+
+func (s *TrashService) RestoreItem(userId, itemId int) error {
+	return s.repo.RestoreItem(userId, itemId)
+}
+
+func (s *TrashService) DeleteList(userId, listId int) error {
+	return s.repo.DeleteList(userId, listId)
+}
+
+func (s *TrashService) DeleteItem(userId, itemId int) error {
+	return s.repo.DeleteItem(userId, itemId)
+}
+
+// TrashPurger deletes what is past the retention from the trash in the
+// background.
+type TrashPurger struct {
+	repo      repository.Trash
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewTrashPurger(repo repository.Trash, cfg Config) *TrashPurger {
+	return &TrashPurger{repo: repo, retention: cfg.Trash.Retention, interval: cfg.Trash.PurgeInterval}
+}
+
+// Run purges the trash until ctx is cancelled.
+func (w *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if err := w.RunOnce(ctx); err != nil {
+			logrus.Errorf("failed to purge the trash: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce deletes everything that went to the trash longer than the
+// retention ago. Files of purged items are left to the attachment sweeper.
+func (w *TrashPurger) RunOnce(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return w.repo.Purge(time.Now().Add(-w.retention))
+}
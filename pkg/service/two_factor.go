@@ -0,0 +1,194 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/Olmosbek510/todo-app"
+	"strings"
+	"time"
+)
+
+const (
+	purposeTwoFactorChallenge = "2fa_challenge"
+	twoFactorChallengeTTL     = 5 * time.Minute
+	maxTwoFactorAttempts      = 5
+	recoveryCodeCount         = 10
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled    = errors.New("two-factor enrolment has not been started")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+// EnrollTOTP creates a new secret for the user. It only becomes active once
+// a code generated from it is confirmed with ConfirmTOTP.
+func (s *AuthService) EnrollTOTP(userId int) (todo.TwoFactorEnrollment, error) {
+	user, err := s.repo.GetUserById(userId)
+	if err != nil {
+		return todo.TwoFactorEnrollment{}, err
+	}
+	if user.TOTPEnabled {
+		return todo.TwoFactorEnrollment{}, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return todo.TwoFactorEnrollment{}, err
+	}
+	if err := s.repo.SetTOTPSecret(userId, secret); err != nil {
+		return todo.TwoFactorEnrollment{}, err
+	}
+	return todo.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totpURI(s.totpIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication and returns the recovery
+// codes, which are shown to the user only this once.
+func (s *AuthService) ConfirmTOTP(userId int, code string) ([]string, error) {
+	user, err := s.repo.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	step, ok, err := matchTOTP(*user.TOTPSecret, code, time.Now(), 0)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw, err := generateTOTPSecret()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = strings.ToLower(raw[:5] + "-" + raw[5:10])
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	if err := s.repo.EnableTOTP(userId, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off, which requires a current
+// code or an unused recovery code.
+func (s *AuthService) DisableTOTP(userId int, code string) error {
+	user, err := s.repo.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	ok, err := s.checkSecondFactor(user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	return s.repo.DisableTOTP(userId)
+}
+
+// VerifyTwoFactor finishes a sign-in that returned a challenge token. The
+// challenge is consumed on success and after too many wrong codes. Wrong
+// codes also count as failed sign-ins, new challenges cannot be used to
+// keep guessing.
+func (s *AuthService) VerifyTwoFactor(challengeToken, code, clientIP string) (todo.Tokens, error) {
+	claims, err := s.parseUserToken(challengeToken, purposeTwoFactorChallenge)
+	if err != nil {
+		return todo.Tokens{}, err
+	}
+	active, err := s.userTokenRepo.IsActive(claims.Id, purposeTwoFactorChallenge)
+	if err != nil {
+		return todo.Tokens{}, err
+	}
+	if !active {
+		return todo.Tokens{}, ErrInvalidUserToken
+	}
+
+	user, err := s.repo.GetUserById(claims.userId())
+	if err != nil {
+		return todo.Tokens{}, err
+	}
+	if err := s.checkLockout(user.Username, clientIP); err != nil {
+		return todo.Tokens{}, err
+	}
+
+	ok, err := s.checkSecondFactor(user, code)
+	if err != nil {
+		return todo.Tokens{}, err
+	}
+	if !ok {
+		if err := s.recordFailedSignIn(user.Username, clientIP); err != nil {
+			return todo.Tokens{}, err
+		}
+		attempts, err := s.userTokenRepo.RecordFailure(claims.Id)
+		if err != nil {
+			return todo.Tokens{}, err
+		}
+		if attempts >= maxTwoFactorAttempts {
+			if err := s.userTokenRepo.InvalidateAll(user.Id, purposeTwoFactorChallenge); err != nil {
+				return todo.Tokens{}, err
+			}
+		}
+		return todo.Tokens{}, ErrInvalidTwoFactorCode
+	}
+
+	if _, _, err := s.useUserToken(challengeToken, purposeTwoFactorChallenge); err != nil {
+		return todo.Tokens{}, err
+	}
+	if err := s.resetFailedSignIns(user.Username); err != nil {
+		return todo.Tokens{}, err
+	}
+	return s.startSession(user.Id)
+}
+
+func (s *AuthService) checkSecondFactor(user todo.User, code string) (bool, error) {
+	if user.TOTPSecret == nil {
+		return false, nil
+	}
+
+	if isTOTPCode(code) {
+		var lastStep int64
+		if user.TOTPLastStep != nil {
+			lastStep = *user.TOTPLastStep
+		}
+		step, ok, err := matchTOTP(*user.TOTPSecret, code, time.Now(), lastStep)
+		if err != nil || !ok {
+			return false, err
+		}
+		return used(s.repo.UseTOTPStep(user.Id, step))
+	}
+
+	return used(s.repo.UseRecoveryCode(user.Id, hashToken(normalizeRecoveryCode(code))))
+}
+
+// used maps the result of a conditional update to whether it took effect.
+func used(err error) (bool, error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"strconv"
+	"time"
+)
+
+const (
+	purposeVerifyEmail   = "verify_email"
+	purposeResetPassword = "reset_password"
+
+	verifyEmailTokenTTL   = 48 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+)
+
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// userTokenClaims are carried by signed single-use tokens that are mailed to
+// users. They are signed with the same keys as access tokens, the purpose
+// claim keeps the two from being mistaken for each other.
+type userTokenClaims struct {
+	jwt.StandardClaims
+	Purpose string `json:"purpose"`
+	Email   string `json:"email,omitempty"`
+}
+
+func (c userTokenClaims) userId() int {
+	id, _ := strconv.Atoi(c.Subject)
+	return id
+}
+
+// issueUserToken signs a token for purpose and records its id, so it can be
+// used exactly once. Earlier unused tokens of the same purpose are
+// invalidated.
+func (s *AuthService) issueUserToken(userId int, purpose, email string, ttl time.Duration) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	if err := s.userTokenRepo.InvalidateAll(userId, purpose); err != nil {
+		return "", err
+	}
+	if err := s.userTokenRepo.Create(jti, userId, purpose, expiresAt); err != nil {
+		return "", err
+	}
+
+	return s.keys.sign(&userTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Subject:   strconv.Itoa(userId),
+			ExpiresAt: expiresAt.Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+		Purpose: purpose,
+		Email:   email,
+	})
+}
+
+// useUserToken verifies a token issued for purpose and consumes it.
+func (s *AuthService) useUserToken(raw, purpose string) (int, userTokenClaims, error) {
+	claims, err := s.parseUserToken(raw, purpose)
+	if err != nil {
+		return 0, userTokenClaims{}, err
+	}
+
+	userId, err := s.userTokenRepo.Use(claims.Id, purpose)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, userTokenClaims{}, ErrInvalidUserToken
+		}
+		return 0, userTokenClaims{}, err
+	}
+	return userId, claims, nil
+}
+
+// parseUserToken verifies signature, expiry and purpose without consuming
+// the token.
+func (s *AuthService) parseUserToken(raw, purpose string) (userTokenClaims, error) {
+	var claims userTokenClaims
+	if _, err := jwt.ParseWithClaims(raw, &claims, s.keys.keyFunc); err != nil {
+		return userTokenClaims{}, ErrInvalidUserToken
+	}
+	if claims.Purpose != purpose || claims.Id == "" {
+		return userTokenClaims{}, ErrInvalidUserToken
+	}
+	return claims, nil
+}
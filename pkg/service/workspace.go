@@ -0,0 +1,137 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/Olmosbek510/todo-app"
+	"github.com/Olmosbek510/todo-app/pkg/repository"
+)
+
+var (
+	ErrPersonalWorkspace = errors.New("personal workspaces cannot be shared, left or deleted")
+	ErrLastOwner         = repository.ErrLastOwner
+)
+
+type WorkspaceService struct {
+	repo     repository.Workspace
+	listRepo repository.TodoList
+	userRepo repository.Authorization
+}
+
+func NewWorkspaceService(repo repository.Workspace, listRepo repository.TodoList, userRepo repository.Authorization) *WorkspaceService {
+	return &WorkspaceService{repo: repo, listRepo: listRepo, userRepo: userRepo}
+}
+
+func (s *WorkspaceService) Create(userId int, input todo.CreateWorkspaceInput) (int, error) {
+	return s.repo.Create(userId, input.Name)
+}
+
+func (s *WorkspaceService) GetAll(userId int) ([]todo.Workspace, error) {
+	return s.repo.GetAll(userId)
+}
+
+func (s *WorkspaceService) GetById(userId, id int) (todo.Workspace, error) {
+	return s.repo.GetById(userId, id)
+}
+
+func (s *WorkspaceService) Update(userId, id int, input todo.UpdateWorkspaceInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	if _, err := s.requireOwner(userId, id); err != nil {
+		return err
+	}
+	return s.repo.Update(id, *input.Name)
+}
+
+// Delete removes the workspace together with all of its lists.
+func (s *WorkspaceService) Delete(userId, id int) error {
+	workspace, err := s.requireOwner(userId, id)
+	if err != nil {
+		return err
+	}
+	if workspace.Personal {
+		return ErrPersonalWorkspace
+	}
+	return s.repo.Delete(id)
+}
+
+func (s *WorkspaceService) GetLists(userId, id int) ([]todo.TodoList, error) {
+	if _, err := s.repo.GetById(userId, id); err != nil {
+		return nil, err
+	}
+	return s.listRepo.GetAllInWorkspace(userId, id)
+}
+
+func (s *WorkspaceService) GetMembers(userId, id int) ([]todo.WorkspaceMember, error) {
+	if _, err := s.repo.GetById(userId, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetMembers(id)
+}
+
+// AddMember gives a user access to every list of the workspace.
+func (s *WorkspaceService) AddMember(userId, id int, input todo.AddMemberInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	workspace, err := s.requireOwner(userId, id)
+	if err != nil {
+		return err
+	}
+	if workspace.Personal {
+		return ErrPersonalWorkspace
+	}
+
+	member, err := s.userRepo.GetUser(input.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if _, err := s.repo.GetById(member.Id, id); err == nil {
+		return ErrAlreadyMember
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return s.repo.AddMember(id, member.Id, input.Role)
+}
+
+func (s *WorkspaceService) UpdateMemberRole(userId, id, memberId int, input todo.UpdateMemberInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	if _, err := s.requireOwner(userId, id); err != nil {
+		return err
+	}
+	return s.repo.UpdateMemberRole(id, memberId, input.Role)
+}
+
+// RemoveMember takes a member out of the workspace, which revokes their
+// access to all of its lists. Owners can remove anyone, every other member
+// can only remove themselves.
+func (s *WorkspaceService) RemoveMember(userId, id, memberId int) error {
+	workspace, err := s.repo.GetById(userId, id)
+	if err != nil {
+		return err
+	}
+	if workspace.Personal {
+		return ErrPersonalWorkspace
+	}
+	if userId != memberId && workspace.Role != todo.ListRoleOwner {
+		return ErrInsufficientRole
+	}
+	return s.repo.RemoveMember(id, memberId)
+}
+
+func (s *WorkspaceService) requireOwner(userId, id int) (todo.Workspace, error) {
+	workspace, err := s.repo.GetById(userId, id)
+	if err != nil {
+		return todo.Workspace{}, err
+	}
+	if workspace.Role != todo.ListRoleOwner {
+		return todo.Workspace{}, ErrInsufficientRole
+	}
+	return workspace, nil
+}
@@ -0,0 +1,73 @@
+package todo
+
+import (
+	"errors"
+	"time"
+)
+
+// Reminder notifies the user who created it about an item, at a fixed time
+// or a number of minutes before the item is due. Reminders of other users on
+// the same item are not visible.
+type Reminder struct {
+	Id            int        `json:"id" db:"id"`
+	ItemId        int        `json:"item_id" db:"item_id"`
+	RemindAt      *time.Time `json:"remind_at" db:"remind_at"`
+	OffsetMinutes *int       `json:"offset_minutes" db:"offset_minutes"`
+	// Channel is how the reminder is delivered, like "email" or "webhook".
+	Channel   string     `json:"channel" db:"channel"`
+	Status    string     `json:"status" db:"status"`
+	Attempts  int        `json:"attempts" db:"attempts"`
+	LastError *string    `json:"last_error" db:"last_error"`
+	SentAt    *time.Time `json:"sent_at" db:"sent_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Delivery states of a reminder. A sending reminder is claimed by one app
+// instance, failed ones ran out of attempts.
+const (
+	ReminderPending = "pending"
+	ReminderSending = "sending"
+	ReminderSent    = "sent"
+	ReminderFailed  = "failed"
+)
+
+// maxReminderOffset is a year in minutes.
+const maxReminderOffset = 366 * 24 * 60
+
+var ErrReminderWithoutDue = errors.New("reminders relative to the due date need an item with a due date")
+
+type CreateReminderInput struct {
+	// Either RemindAt or OffsetMinutes before the due date of the item.
+	RemindAt      *time.Time `json:"remind_at"`
+	OffsetMinutes *int       `json:"offset_minutes"`
+	// Channel defaults to email.
+	Channel string `json:"channel"`
+}
+
+func (i *CreateReminderInput) Validate() error {
+	if (i.RemindAt == nil) == (i.OffsetMinutes == nil) {
+		return errors.New("set either remind_at or offset_minutes")
+	}
+	if i.OffsetMinutes != nil && (*i.OffsetMinutes < 0 || *i.OffsetMinutes > maxReminderOffset) {
+		return errors.New("offset_minutes must be between 0 and a year")
+	}
+	return nil
+}
+
+// ReminderDelivery is a reminder claimed for sending, with what the message
+// needs.
+type ReminderDelivery struct {
+	Id       int    `db:"id"`
+	Channel  string `db:"channel"`
+	Attempts int    `db:"attempts"`
+	UserId   int    `db:"user_id"`
+	Username string `db:"username"`
+	// Email is only set once it is verified.
+	Email    *string    `db:"email"`
+	TimeZone string     `db:"time_zone"`
+	ItemId   int        `db:"item_id"`
+	ListId   int        `db:"list_id"`
+	Title    string     `db:"title"`
+	DueAt    *time.Time `db:"due_at"`
+	AllDay   bool       `db:"all_day"`
+}
@@ -0,0 +1,12 @@
+CREATE TABLE refresh_tokens
+(
+    id         serial                                      not null unique,
+    user_id    int references users (id) on delete cascade not null,
+    family_id  varchar(64)                                 not null,
+    token_hash varchar(64)                                 not null unique,
+    expires_at timestamptz                                 not null,
+    revoked_at timestamptz,
+    created_at timestamptz                                 not null default now()
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
@@ -0,0 +1,10 @@
+CREATE TABLE revoked_tokens
+(
+    jti        varchar(64)                                 not null unique,
+    user_id    int references users (id) on delete cascade not null,
+    expires_at timestamptz                                 not null,
+    revoked_at timestamptz                                 not null default now()
+);
+
+ALTER TABLE users
+    ADD COLUMN tokens_valid_after timestamptz;
@@ -0,0 +1,11 @@
+CREATE TABLE personal_access_tokens
+(
+    id           serial                                      not null unique,
+    user_id      int references users (id) on delete cascade not null,
+    name         varchar(255)                                not null,
+    token_hash   varchar(64)                                 not null unique,
+    scopes       varchar(255)                                not null,
+    expires_at   timestamptz,
+    last_used_at timestamptz,
+    created_at   timestamptz                                 not null default now()
+);
@@ -0,0 +1,12 @@
+ALTER TABLE users
+    ADD COLUMN email             varchar(255) unique,
+    ADD COLUMN email_verified_at timestamptz;
+
+CREATE TABLE user_tokens
+(
+    jti        varchar(64)                                 not null unique,
+    user_id    int references users (id) on delete cascade not null,
+    purpose    varchar(32)                                 not null,
+    expires_at timestamptz                                 not null,
+    used_at    timestamptz
+);
@@ -0,0 +1,9 @@
+ALTER TABLE user_tokens
+    DROP COLUMN failed_attempts;
+
+DROP TABLE recovery_codes;
+
+ALTER TABLE users
+    DROP COLUMN totp_last_step,
+    DROP COLUMN totp_enabled,
+    DROP COLUMN totp_secret;
@@ -0,0 +1,15 @@
+ALTER TABLE users
+    ADD COLUMN totp_secret    varchar(64),
+    ADD COLUMN totp_enabled   boolean not null default false,
+    ADD COLUMN totp_last_step bigint;
+
+CREATE TABLE recovery_codes
+(
+    id        serial                                      not null unique,
+    user_id   int references users (id) on delete cascade not null,
+    code_hash varchar(64)                                 not null,
+    used_at   timestamptz
+);
+
+ALTER TABLE user_tokens
+    ADD COLUMN failed_attempts int not null default 0;
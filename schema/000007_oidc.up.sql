@@ -0,0 +1,20 @@
+CREATE TABLE user_identities
+(
+    id         serial                                      not null unique,
+    user_id    int references users (id) on delete cascade not null,
+    provider   varchar(64)                                 not null,
+    subject    varchar(255)                                not null,
+    email      varchar(255),
+    created_at timestamptz                                 not null default now(),
+    unique (provider, subject)
+);
+
+CREATE TABLE oidc_login_states
+(
+    state         varchar(64)                        not null unique,
+    provider      varchar(64)                        not null,
+    nonce         varchar(64)                        not null,
+    code_verifier varchar(128)                       not null,
+    link_user_id  int references users (id) on delete cascade,
+    expires_at    timestamptz                        not null
+);
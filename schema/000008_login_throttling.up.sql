@@ -0,0 +1,17 @@
+CREATE TABLE login_attempts
+(
+    key             varchar(320) not null unique,
+    failures        int          not null default 0,
+    locked_until    timestamptz,
+    last_failure_at timestamptz  not null default now()
+);
+
+CREATE TABLE audit_log
+(
+    id         serial                                       not null unique,
+    user_id    int references users (id) on delete set null,
+    event      varchar(64)                                  not null,
+    ip         varchar(64),
+    detail     text,
+    created_at timestamptz                                  not null default now()
+);
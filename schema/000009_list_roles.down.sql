@@ -0,0 +1,3 @@
+ALTER TABLE users_lists
+    DROP CONSTRAINT users_lists_user_id_list_id_key,
+    DROP COLUMN role;
@@ -0,0 +1,8 @@
+-- every existing link had full control over the list, so it becomes an owner
+ALTER TABLE users_lists
+    ADD COLUMN role varchar(16) not null default 'owner'
+        check (role in ('owner', 'editor', 'viewer')),
+    ADD CONSTRAINT users_lists_user_id_list_id_key unique (user_id, list_id);
+
+ALTER TABLE users_lists
+    ALTER COLUMN role DROP DEFAULT;
@@ -0,0 +1,21 @@
+-- an invitation targets an existing user (invitee_id) or an email address
+-- that may not have an account yet. Expiry is derived from expires_at.
+CREATE TABLE list_invitations
+(
+    id           serial                                           not null unique,
+    list_id      int references todo_lists (id) on delete cascade not null,
+    inviter_id   int references users (id) on delete cascade      not null,
+    invitee_id   int references users (id) on delete cascade,
+    email        varchar(255),
+    role         varchar(16)                                      not null
+        check (role in ('owner', 'editor', 'viewer')),
+    status       varchar(16)                                      not null default 'pending'
+        check (status in ('pending', 'accepted', 'declined')),
+    created_at   timestamptz                                      not null default now(),
+    expires_at   timestamptz                                      not null,
+    responded_at timestamptz,
+    check (invitee_id IS NOT NULL OR email IS NOT NULL)
+);
+
+CREATE INDEX list_invitations_invitee_id_idx ON list_invitations (invitee_id);
+CREATE INDEX list_invitations_email_idx ON list_invitations (email);
@@ -0,0 +1,10 @@
+CREATE TABLE share_links
+(
+    id            serial                                           not null unique,
+    list_id       int references todo_lists (id) on delete cascade not null,
+    created_by    int references users (id) on delete cascade      not null,
+    token_hash    varchar(64)                                      not null unique,
+    password_hash varchar(255),
+    expires_at    timestamptz,
+    created_at    timestamptz                                      not null default now()
+);
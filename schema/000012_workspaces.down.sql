@@ -0,0 +1,15 @@
+DROP VIEW list_access;
+
+-- workspace members keep their access as direct members of the lists
+INSERT INTO users_lists (user_id, list_id, role)
+SELECT wm.user_id, tl.id, wm.role
+FROM todo_lists tl
+         JOIN workspace_members wm ON wm.workspace_id = tl.workspace_id
+ON CONFLICT (user_id, list_id) DO NOTHING;
+
+ALTER TABLE todo_lists
+    DROP COLUMN workspace_id;
+
+DROP TABLE workspace_members;
+
+DROP TABLE workspaces;
@@ -0,0 +1,79 @@
+CREATE TABLE workspaces
+(
+    id               serial                                      not null unique,
+    name             varchar(255)                                not null,
+    -- set for the personal workspace every user gets
+    personal_user_id int references users (id) on delete cascade unique,
+    created_at       timestamptz                                 not null default now()
+);
+
+CREATE TABLE workspace_members
+(
+    id           serial                                           not null unique,
+    workspace_id int references workspaces (id) on delete cascade not null,
+    user_id      int references users (id) on delete cascade      not null,
+    role         varchar(16)                                      not null
+        check (role in ('owner', 'editor', 'viewer')),
+    unique (workspace_id, user_id)
+);
+
+INSERT INTO workspaces (name, personal_user_id)
+SELECT 'Personal', id
+FROM users;
+
+INSERT INTO workspace_members (workspace_id, user_id, role)
+SELECT id, personal_user_id, 'owner'
+FROM workspaces;
+
+ALTER TABLE todo_lists
+    ADD COLUMN workspace_id int references workspaces (id) on delete cascade;
+
+-- every list moves into the personal workspace of its first owner, who has
+-- access through the workspace from now on. The remaining users_lists rows
+-- are lists shared with individual users.
+UPDATE todo_lists tl
+SET workspace_id = w.id
+FROM (SELECT DISTINCT ON (list_id) list_id, user_id
+      FROM users_lists
+      WHERE role = 'owner'
+      ORDER BY list_id, id) o
+         JOIN workspaces w ON w.personal_user_id = o.user_id
+WHERE tl.id = o.list_id;
+
+DELETE
+FROM users_lists ul USING todo_lists tl, workspaces w
+WHERE tl.id = ul.list_id
+  AND w.id = tl.workspace_id
+  AND w.personal_user_id = ul.user_id;
+
+-- lists without an owner were not reachable by anyone
+DELETE
+FROM todo_items ti USING lists_items li, todo_lists tl
+WHERE ti.id = li.item_id
+  AND tl.id = li.list_id
+  AND tl.workspace_id IS NULL;
+
+DELETE
+FROM todo_lists
+WHERE workspace_id IS NULL;
+
+ALTER TABLE todo_lists
+    ALTER COLUMN workspace_id SET NOT NULL;
+
+-- the effective role of every user on every list they can reach, through
+-- the workspace of the list or because it was shared with them directly
+CREATE VIEW list_access AS
+SELECT user_id,
+       list_id,
+       CASE max(CASE role WHEN 'owner' THEN 3 WHEN 'editor' THEN 2 ELSE 1 END)
+           WHEN 3 THEN 'owner'
+           WHEN 2 THEN 'editor'
+           ELSE 'viewer'
+           END AS role
+FROM (SELECT user_id, list_id, role
+      FROM users_lists
+      UNION ALL
+      SELECT wm.user_id, tl.id, wm.role
+      FROM todo_lists tl
+               JOIN workspace_members wm ON wm.workspace_id = tl.workspace_id) access
+GROUP BY user_id, list_id;
@@ -0,0 +1,10 @@
+DROP INDEX todo_items_due_at_idx;
+
+ALTER TABLE todo_items
+    DROP CONSTRAINT todo_items_start_before_due,
+    DROP COLUMN all_day,
+    DROP COLUMN due_at,
+    DROP COLUMN start_at;
+
+ALTER TABLE users
+    DROP COLUMN time_zone;
@@ -0,0 +1,10 @@
+ALTER TABLE users
+    ADD COLUMN time_zone varchar(64) not null default 'UTC';
+
+ALTER TABLE todo_items
+    ADD COLUMN start_at timestamptz,
+    ADD COLUMN due_at   timestamptz,
+    ADD COLUMN all_day  boolean not null default false,
+    ADD CONSTRAINT todo_items_start_before_due CHECK (start_at <= due_at);
+
+CREATE INDEX todo_items_due_at_idx ON todo_items (due_at) WHERE NOT done;
@@ -0,0 +1,5 @@
+DROP TABLE items_labels;
+DROP TABLE labels;
+
+ALTER TABLE todo_items
+    DROP COLUMN priority;
@@ -0,0 +1,23 @@
+ALTER TABLE todo_items
+    ADD COLUMN priority smallint not null default 0 CHECK (priority BETWEEN 0 AND 3);
+
+CREATE TABLE labels
+(
+    id           serial                                           not null unique,
+    name         varchar(64)                                      not null,
+    color        varchar(7)                                       not null,
+    user_id      int references users (id) on delete cascade,
+    workspace_id int references workspaces (id) on delete cascade,
+    created_at   timestamptz                                      not null default now(),
+    CHECK ((user_id IS NULL) <> (workspace_id IS NULL))
+);
+
+CREATE UNIQUE INDEX labels_user_name_idx ON labels (user_id, lower(name)) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX labels_workspace_name_idx ON labels (workspace_id, lower(name)) WHERE workspace_id IS NOT NULL;
+
+CREATE TABLE items_labels
+(
+    item_id  int references todo_items (id) on delete cascade not null,
+    label_id int references labels (id) on delete cascade     not null,
+    primary key (item_id, label_id)
+);
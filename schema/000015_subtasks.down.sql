@@ -0,0 +1,8 @@
+ALTER TABLE todo_lists
+    DROP COLUMN auto_complete_parent,
+    DROP COLUMN complete_subtasks;
+
+DROP INDEX todo_items_parent_id_idx;
+
+ALTER TABLE todo_items
+    DROP COLUMN parent_id;
@@ -0,0 +1,8 @@
+ALTER TABLE todo_items
+    ADD COLUMN parent_id int references todo_items (id) on delete cascade;
+
+CREATE INDEX todo_items_parent_id_idx ON todo_items (parent_id);
+
+ALTER TABLE todo_lists
+    ADD COLUMN complete_subtasks    boolean not null default false,
+    ADD COLUMN auto_complete_parent boolean not null default false;
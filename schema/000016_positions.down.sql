@@ -0,0 +1,6 @@
+DROP TABLE list_positions;
+
+DROP INDEX todo_items_position_idx;
+
+ALTER TABLE todo_items
+    DROP COLUMN position;
@@ -0,0 +1,30 @@
+-- positions are fractional keys compared byte by byte, see pkg/position
+ALTER TABLE todo_items
+    ADD COLUMN position text COLLATE "C";
+
+UPDATE todo_items ti
+SET position = 'h' || lpad(p.n::text, 8, '0')
+FROM (SELECT item_id, row_number() OVER (PARTITION BY list_id ORDER BY item_id) AS n FROM lists_items) p
+WHERE p.item_id = ti.id;
+
+UPDATE todo_items
+SET position = 'a0'
+WHERE position IS NULL;
+
+ALTER TABLE todo_items
+    ALTER COLUMN position SET NOT NULL;
+
+CREATE INDEX todo_items_position_idx ON todo_items (position);
+
+-- the order of lists is kept per user, lists without a row come last
+CREATE TABLE list_positions
+(
+    user_id  int references users (id) on delete cascade      not null,
+    list_id  int references todo_lists (id) on delete cascade not null,
+    position text COLLATE "C"                                 not null,
+    primary key (user_id, list_id)
+);
+
+INSERT INTO list_positions (user_id, list_id, position)
+SELECT user_id, list_id, 'h' || lpad((row_number() OVER (PARTITION BY user_id ORDER BY list_id))::text, 8, '0')
+FROM list_access;
@@ -0,0 +1,5 @@
+DROP TABLE item_completions;
+
+ALTER TABLE todo_items
+    DROP COLUMN occurrence,
+    DROP COLUMN recurrence;
@@ -0,0 +1,14 @@
+ALTER TABLE todo_items
+    ADD COLUMN recurrence text,
+    ADD COLUMN occurrence int not null default 1;
+
+CREATE TABLE item_completions
+(
+    id           serial                                          not null unique,
+    item_id      int references todo_items (id) on delete cascade not null,
+    user_id      int references users (id) on delete set null,
+    due_at       timestamptz,
+    completed_at timestamptz                                     not null default now()
+);
+
+CREATE INDEX item_completions_item_id_idx ON item_completions (item_id, completed_at);
@@ -0,0 +1,20 @@
+CREATE TABLE reminders
+(
+    id              serial                                           not null unique,
+    item_id         int references todo_items (id) on delete cascade not null,
+    user_id         int references users (id) on delete cascade      not null,
+    remind_at       timestamptz,
+    offset_minutes  int,
+    channel         varchar(32)                                      not null,
+    status          varchar(16)                                      not null default 'pending',
+    attempts        int                                              not null default 0,
+    next_attempt_at timestamptz,
+    locked_until    timestamptz,
+    last_error      text,
+    sent_at         timestamptz,
+    created_at      timestamptz                                      not null default now(),
+    CHECK ((remind_at IS NULL) <> (offset_minutes IS NULL))
+);
+
+CREATE INDEX reminders_item_id_idx ON reminders (item_id, user_id);
+CREATE INDEX reminders_open_idx ON reminders (status) WHERE status IN ('pending', 'sending');
@@ -0,0 +1,20 @@
+CREATE TABLE item_comments
+(
+    id         serial                                              not null unique,
+    item_id    int references todo_items (id) on delete cascade    not null,
+    parent_id  int references item_comments (id) on delete cascade,
+    user_id    int references users (id) on delete set null,
+    body       text                                                not null,
+    created_at timestamptz                                         not null default now(),
+    edited_at  timestamptz
+);
+
+CREATE INDEX item_comments_item_id_idx ON item_comments (item_id, id) WHERE parent_id IS NULL;
+CREATE INDEX item_comments_parent_id_idx ON item_comments (parent_id, id);
+
+CREATE TABLE comment_mentions
+(
+    comment_id int references item_comments (id) on delete cascade not null,
+    user_id    int references users (id) on delete cascade         not null,
+    PRIMARY KEY (comment_id, user_id)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE attachments
+(
+    id           serial                                            not null unique,
+    -- null once the item is deleted, the file is then removed in the background
+    item_id      int references todo_items (id) on delete set null,
+    user_id      int references users (id) on delete set null,
+    filename     varchar(255)                                      not null,
+    content_type varchar(255)                                      not null,
+    size         bigint                                            not null,
+    storage_key  varchar(255)                                      not null unique,
+    created_at   timestamptz                                       not null default now()
+);
+
+CREATE INDEX attachments_item_id_idx ON attachments (item_id);
+CREATE INDEX attachments_user_id_idx ON attachments (user_id) WHERE item_id IS NOT NULL;
+CREATE INDEX attachments_detached_idx ON attachments (id) WHERE item_id IS NULL;
@@ -0,0 +1,26 @@
+DROP VIEW list_access;
+ALTER VIEW list_access_all RENAME TO list_access;
+
+-- without a trash, what is in it is gone
+DELETE
+FROM todo_items ti USING lists_items li, todo_lists tl
+WHERE ti.id = li.item_id
+  AND tl.id = li.list_id
+  AND tl.deleted_at IS NOT NULL;
+
+DELETE
+FROM todo_lists
+WHERE deleted_at IS NOT NULL;
+
+DELETE
+FROM todo_items
+WHERE deleted_at IS NOT NULL;
+
+DROP INDEX todo_items_deleted_at_idx;
+DROP INDEX todo_lists_deleted_at_idx;
+
+ALTER TABLE todo_items
+    DROP COLUMN deleted_at;
+
+ALTER TABLE todo_lists
+    DROP COLUMN deleted_at;
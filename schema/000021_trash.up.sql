@@ -0,0 +1,19 @@
+ALTER TABLE todo_lists
+    ADD COLUMN deleted_at timestamptz;
+
+-- an item deleted with its subtasks marks all of them with the same time
+ALTER TABLE todo_items
+    ADD COLUMN deleted_at timestamptz;
+
+CREATE INDEX todo_lists_deleted_at_idx ON todo_lists (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX todo_items_deleted_at_idx ON todo_items (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- list_access keeps its meaning for everything that checks access, lists in
+-- the trash are only reachable through list_access_all
+ALTER VIEW list_access RENAME TO list_access_all;
+
+CREATE VIEW list_access AS
+SELECT la.user_id, la.list_id, la.role
+FROM list_access_all la
+         JOIN todo_lists tl ON tl.id = la.list_id
+WHERE tl.deleted_at IS NULL;
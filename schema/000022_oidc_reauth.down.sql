@@ -0,0 +1,2 @@
+ALTER TABLE oidc_login_states
+    DROP COLUMN reauth;
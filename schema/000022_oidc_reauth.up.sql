@@ -0,0 +1,4 @@
+-- logins that only prove who the signed-in user link_user_id is, before
+-- changes that need a password from users who have none
+ALTER TABLE oidc_login_states
+    ADD COLUMN reauth boolean not null default false;
@@ -0,0 +1,19 @@
+-- timed items recur in the time zone of the user who set the rule, rules
+-- set before keep the time zone of the first owner of their workspace
+ALTER TABLE todo_items
+    ADD COLUMN recurrence_time_zone varchar(64);
+
+UPDATE todo_items ti
+SET recurrence_time_zone = u.time_zone
+FROM lists_items li,
+     todo_lists tl,
+     users u
+WHERE li.item_id = ti.id
+  AND tl.id = li.list_id
+  AND ti.recurrence IS NOT NULL
+  AND u.id = (SELECT wm.user_id
+              FROM workspace_members wm
+              WHERE wm.workspace_id = tl.workspace_id
+                AND wm.role = 'owner'
+              ORDER BY wm.id
+              LIMIT 1);
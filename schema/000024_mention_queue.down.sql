@@ -0,0 +1,10 @@
+DROP INDEX comment_mentions_open_idx;
+
+ALTER TABLE comment_mentions
+    DROP COLUMN sent_at,
+    DROP COLUMN last_error,
+    DROP COLUMN locked_until,
+    DROP COLUMN next_attempt_at,
+    DROP COLUMN attempts,
+    DROP COLUMN status,
+    DROP COLUMN id;
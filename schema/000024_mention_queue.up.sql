@@ -0,0 +1,15 @@
+-- mentions are notified by a background worker like reminders, the ones
+-- recorded before were notified right away
+ALTER TABLE comment_mentions
+    ADD COLUMN id              serial      not null unique,
+    ADD COLUMN status          varchar(16) not null default 'sent',
+    ADD COLUMN attempts        int         not null default 0,
+    ADD COLUMN next_attempt_at timestamptz,
+    ADD COLUMN locked_until    timestamptz,
+    ADD COLUMN last_error      text,
+    ADD COLUMN sent_at         timestamptz;
+
+ALTER TABLE comment_mentions
+    ALTER COLUMN status SET DEFAULT 'pending';
+
+CREATE INDEX comment_mentions_open_idx ON comment_mentions (status) WHERE status IN ('pending', 'sending');
@@ -0,0 +1,10 @@
+-- sign-out-all revokes the sessions open at the time, a cut-off on the
+-- whole-second iat of access tokens missed those issued earlier in the same
+-- second
+CREATE TABLE revoked_sessions
+(
+    family_id  varchar(64)                                 not null unique,
+    user_id    int references users (id) on delete cascade not null,
+    expires_at timestamptz                                 not null,
+    revoked_at timestamptz                                 not null default now()
+);
@@ -0,0 +1,70 @@
+package todo
+
+import (
+	"errors"
+	"time"
+)
+
+// ShareLink gives read-only access to a list to anyone who has the link,
+// optionally guarded by a password.
+type ShareLink struct {
+	Id           int        `json:"id" db:"id"`
+	ListId       int        `json:"list_id" db:"list_id"`
+	CreatedBy    int        `json:"created_by" db:"created_by"`
+	TokenHash    string     `json:"-" db:"token_hash"`
+	PasswordHash *string    `json:"-" db:"password_hash"`
+	HasPassword  bool       `json:"has_password" db:"has_password"`
+	ExpiresAt    *time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+type CreateShareLinkInput struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	Password  string     `json:"password"`
+}
+
+func (i *CreateShareLinkInput) Validate() error {
+	if i.ExpiresAt != nil && !i.ExpiresAt.After(time.Now()) {
+		return errors.New("expiry must be in the future")
+	}
+	return nil
+}
+
+// SharedList is what a share link shows.
+type SharedList struct {
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Items       []SharedItem `json:"items"`
+}
+
+// SharedItem is an item as a share link shows it, with its subtasks. It
+// leaves out what only members of the list get to see, like labels and the
+// time zone of whoever set the recurrence.
+type SharedItem struct {
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Done        bool         `json:"done"`
+	StartAt     *time.Time   `json:"start_at"`
+	DueAt       *time.Time   `json:"due_at"`
+	AllDay      bool         `json:"all_day"`
+	Priority    int          `json:"priority"`
+	Children    []SharedItem `json:"children"`
+}
+
+// NewSharedItems converts items nested by ItemTree.
+func NewSharedItems(items []TodoItem) []SharedItem {
+	shared := make([]SharedItem, 0, len(items))
+	for _, item := range items {
+		shared = append(shared, SharedItem{
+			Title:       item.Title,
+			Description: item.Description,
+			Done:        item.Done,
+			StartAt:     item.StartAt,
+			DueAt:       item.DueAt,
+			AllDay:      item.AllDay,
+			Priority:    item.Priority,
+			Children:    NewSharedItems(item.Children),
+		})
+	}
+	return shared
+}
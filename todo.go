@@ -1,17 +1,81 @@
 package todo
 
-import "errors"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+)
 
 type TodoList struct {
 	Id          int    `json:"id" db:"id"`
 	Title       string `json:"title" db:"title" binding:"required"`
 	Description string `json:"description" db:"description"`
+	// WorkspaceId defaults to the personal workspace of the creator.
+	WorkspaceId int `json:"workspace_id" db:"workspace_id"`
+	// CompleteSubtasks completes all subtasks of an item along with it.
+	CompleteSubtasks bool `json:"complete_subtasks" db:"complete_subtasks"`
+	// AutoCompleteParent completes an item once all of its subtasks are
+	// done and reopens it when one of them is reopened.
+	AutoCompleteParent bool `json:"auto_complete_parent" db:"auto_complete_parent"`
+	// Role is the role of the requesting user, it is ignored on create.
+	Role string `json:"role,omitempty" db:"role"`
+}
+
+// Roles a user can have on a list. Viewers can only read the list and its
+// items, editors can change them as well and owners can additionally delete
+// the list and manage its members.
+const (
+	ListRoleOwner  = "owner"
+	ListRoleEditor = "editor"
+	ListRoleViewer = "viewer"
+)
+
+func ValidListRole(role string) bool {
+	switch role {
+	case ListRoleOwner, ListRoleEditor, ListRoleViewer:
+		return true
+	}
+	return false
 }
 
 type UserList struct {
-	Id     int
-	UserId int
-	ListId int
+	Id     int    `db:"id"`
+	UserId int    `db:"user_id"`
+	ListId int    `db:"list_id"`
+	Role   string `db:"role"`
+}
+
+// ListMember is a user a list is shared with directly. Members of the
+// workspace of the list are not included.
+type ListMember struct {
+	UserId   int    `json:"user_id" db:"user_id"`
+	Username string `json:"username" db:"username"`
+	Name     string `json:"name" db:"name"`
+	Role     string `json:"role" db:"role"`
+}
+
+type AddMemberInput struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+func (i *AddMemberInput) Validate() error {
+	if !ValidListRole(i.Role) {
+		return errors.New("role must be one of owner, editor or viewer")
+	}
+	return nil
+}
+
+type UpdateMemberInput struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func (i *UpdateMemberInput) Validate() error {
+	if !ValidListRole(i.Role) {
+		return errors.New("role must be one of owner, editor or viewer")
+	}
+	return nil
 }
 
 type TodoItem struct {
@@ -19,6 +83,164 @@ type TodoItem struct {
 	Title       string `json:"title" db:"title" binding:"required"`
 	Description string `json:"description" db:"description"`
 	Done        bool   `json:"done" db:"done"`
+	// ListId is taken from the path on create.
+	ListId int `json:"list_id" db:"list_id"`
+	// ParentId makes the item a subtask of another item in the same list.
+	ParentId *int       `json:"parent_id" db:"parent_id"`
+	StartAt  *time.Time `json:"start_at" db:"start_at"`
+	DueAt    *time.Time `json:"due_at" db:"due_at"`
+	// AllDay items only have a start and due date, they are stored as
+	// midnight UTC and compared against the calendar of the user.
+	AllDay   bool `json:"all_day" db:"all_day"`
+	Priority int  `json:"priority" db:"priority"`
+	// Recurrence is an RRULE such as "FREQ=WEEKLY;BYDAY=MO,TH". Completing a
+	// recurring item moves it to its next occurrence instead of closing it,
+	// until the rule runs out.
+	Recurrence *string `json:"recurrence" db:"recurrence"`
+	// RecurrenceTimeZone is the time zone timed items recur in, the one of
+	// the user who set the rule. It is set by the service.
+	RecurrenceTimeZone *string `json:"recurrence_time_zone" db:"recurrence_time_zone"`
+	// Occurrence counts the occurrences of a recurring item for the COUNT
+	// of its rule.
+	Occurrence int `json:"-" db:"occurrence"`
+	// Labels are the labels on the item the requesting user can see, they
+	// are ignored on create.
+	Labels []Label `json:"labels" db:"-"`
+	// Children are only filled in when items are requested as a tree.
+	Children []TodoItem `json:"children,omitempty" db:"-"`
+}
+
+var (
+	ErrStartAfterDue        = errors.New("start must not be after the due date")
+	ErrRecurrenceWithoutDue = errors.New("recurring items need a due date")
+)
+
+// NormalizeDates drops the time of day from the dates of all-day items.
+func (i *TodoItem) NormalizeDates() {
+	if !i.AllDay {
+		return
+	}
+	i.StartAt = allDayDate(i.StartAt)
+	i.DueAt = allDayDate(i.DueAt)
+}
+
+func (i *TodoItem) Validate() error {
+	if !ValidPriority(i.Priority) {
+		return ErrInvalidPriority
+	}
+	if i.StartAt != nil && i.DueAt != nil && i.StartAt.After(*i.DueAt) {
+		return ErrStartAfterDue
+	}
+	if i.Recurrence != nil && i.DueAt == nil {
+		return ErrRecurrenceWithoutDue
+	}
+	return nil
+}
+
+// ItemCompletion records that an occurrence of a recurring item was
+// completed.
+type ItemCompletion struct {
+	Id int `json:"id" db:"id"`
+	// UserId is the user who completed it, null once they are deleted.
+	UserId      *int       `json:"user_id" db:"user_id"`
+	DueAt       *time.Time `json:"due_at" db:"due_at"`
+	CompletedAt time.Time  `json:"completed_at" db:"completed_at"`
+}
+
+// Occurrence is the next occurrence of a recurring item.
+type Occurrence struct {
+	StartAt *time.Time
+	DueAt   time.Time
+}
+
+// allDayDate keeps the calendar date as it was written, regardless of the
+// offset it came with.
+func allDayDate(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	year, month, day := t.Date()
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &date
+}
+
+// Filters for items with a due date across all lists of a user, "today" and
+// "week" are the day and the Monday to Sunday week in the time zone of the
+// user.
+const (
+	DueOverdue  = "overdue"
+	DueToday    = "today"
+	DueThisWeek = "week"
+)
+
+// NullableTime tells a missing field apart from an explicit null, which
+// clears the value.
+type NullableTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+func (t *NullableTime) UnmarshalJSON(data []byte) error {
+	t.Set = true
+	if bytes.Equal(data, []byte("null")) {
+		t.Time = nil
+		return nil
+	}
+	var value time.Time
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	t.Time = &value
+	return nil
+}
+
+// NullableInt is NullableTime for numbers.
+type NullableInt struct {
+	Set   bool
+	Value *int
+}
+
+func (i *NullableInt) UnmarshalJSON(data []byte) error {
+	i.Set = true
+	if bytes.Equal(data, []byte("null")) {
+		i.Value = nil
+		return nil
+	}
+	var value int
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	i.Value = &value
+	return nil
+}
+
+// ItemTree nests items under their parents in the order they are given.
+// Items whose parent is not among them become roots.
+func ItemTree(items []TodoItem) []TodoItem {
+	children := make(map[int][]TodoItem)
+	present := make(map[int]bool, len(items))
+	for _, item := range items {
+		present[item.Id] = true
+	}
+
+	roots := make([]TodoItem, 0)
+	for _, item := range items {
+		if item.ParentId != nil && present[*item.ParentId] {
+			children[*item.ParentId] = append(children[*item.ParentId], item)
+		} else {
+			roots = append(roots, item)
+		}
+	}
+
+	var attach func(items []TodoItem)
+	attach = func(items []TodoItem) {
+		for i := range items {
+			items[i].Children = children[items[i].Id]
+			attach(items[i].Children)
+		}
+	}
+	attach(roots)
+	return roots
 }
 
 type ListsItem struct {
@@ -27,27 +249,73 @@ type ListsItem struct {
 	ItemId int
 }
 
+// MoveInput places an item or list right after AfterId and ahead of
+// BeforeId. One anchor is enough, without any it goes to the end.
+type MoveInput struct {
+	AfterId  *int `json:"after_id"`
+	BeforeId *int `json:"before_id"`
+}
+
+// MoveItemInput takes the item with its subtasks to the list ListId, its own
+// list by default, and places it there like MoveInput. In another list the
+// item becomes a top-level item.
+type MoveItemInput struct {
+	MoveInput
+	ListId *int `json:"list_id"`
+}
+
 type UpdateListInput struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
+	Title              *string `json:"title"`
+	Description        *string `json:"description"`
+	CompleteSubtasks   *bool   `json:"complete_subtasks"`
+	AutoCompleteParent *bool   `json:"auto_complete_parent"`
 }
 
 func (i *UpdateListInput) Validate() error {
-	if i.Title == nil && i.Description == nil {
+	if i.Title == nil && i.Description == nil && i.CompleteSubtasks == nil && i.AutoCompleteParent == nil {
 		return errors.New("update structure has no values")
 	}
 	return nil
 }
 
 type UpdateItemInput struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
-	Done        *bool   `json:"done"`
+	Title       *string      `json:"title"`
+	Description *string      `json:"description"`
+	Done        *bool        `json:"done"`
+	StartAt     NullableTime `json:"start_at" swaggertype:"string" format:"date-time"`
+	DueAt       NullableTime `json:"due_at" swaggertype:"string" format:"date-time"`
+	AllDay      *bool        `json:"all_day"`
+	Priority    *int         `json:"priority"`
+	// ParentId moves the item under another item of the list, null makes it
+	// a top-level item again.
+	ParentId NullableInt `json:"parent_id" swaggertype:"integer"`
+	// Recurrence replaces the RRULE of the item, an empty string stops it
+	// from recurring.
+	Recurrence *string `json:"recurrence"`
+	// RecurrenceTimeZone goes with a new rule, it is set by the service.
+	RecurrenceTimeZone *string `json:"-"`
+}
+
+// HasDates reports whether the update touches the start or due date.
+func (i *UpdateItemInput) HasDates() bool {
+	return i.StartAt.Set || i.DueAt.Set || i.AllDay != nil
+}
+
+// HasValues reports whether the update changes anything.
+func (i *UpdateItemInput) HasValues() bool {
+	return i.Title != nil || i.Description != nil || i.Done != nil || i.Priority != nil || i.ParentId.Set ||
+		i.HasDates() || i.Recurrence != nil
 }
 
 func (i *UpdateItemInput) Validate() error {
-	if i.Title == nil && i.Description == nil && i.Done == nil {
+	if !i.HasValues() {
 		return errors.New("update item structure has no values")
 	}
+	if i.Priority != nil && !ValidPriority(*i.Priority) {
+		return ErrInvalidPriority
+	}
+	if i.StartAt.Time != nil && i.DueAt.Time != nil && i.StartAt.Time.After(*i.DueAt.Time) {
+		return ErrStartAfterDue
+	}
 	return nil
 }
@@ -0,0 +1,118 @@
+package todo
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// SignInResult holds either tokens, or the challenge token to exchange for
+// them once the second factor was checked.
+type SignInResult struct {
+	Tokens         *Tokens
+	ChallengeToken string
+}
+
+type RefreshToken struct {
+	Id        int        `db:"id"`
+	UserId    int        `db:"user_id"`
+	FamilyId  string     `db:"family_id"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+const (
+	ScopeListsRead  = "lists:read"
+	ScopeListsWrite = "lists:write"
+	ScopeItemsRead  = "items:read"
+	ScopeItemsWrite = "items:write"
+)
+
+var knownScopes = map[string]bool{
+	ScopeListsRead:  true,
+	ScopeListsWrite: true,
+	ScopeItemsRead:  true,
+	ScopeItemsWrite: true,
+}
+
+// Scopes is stored as a comma separated column.
+type Scopes []string
+
+func (s Scopes) Value() (driver.Value, error) {
+	return strings.Join(s, ","), nil
+}
+
+func (s *Scopes) Scan(src interface{}) error {
+	var raw string
+	switch v := src.(type) {
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	case nil:
+		*s = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Scopes", src)
+	}
+	if raw == "" {
+		*s = Scopes{}
+		return nil
+	}
+	*s = strings.Split(raw, ",")
+	return nil
+}
+
+// Allows reports whether scope is granted. A write scope implies the read
+// scope of the same resource.
+func (s Scopes) Allows(scope string) bool {
+	resource, _, _ := strings.Cut(scope, ":")
+	for _, granted := range s {
+		if granted == scope || granted == resource+":write" {
+			return true
+		}
+	}
+	return false
+}
+
+type PersonalAccessToken struct {
+	Id         int        `json:"id" db:"id"`
+	UserId     int        `json:"-" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	Scopes     Scopes     `json:"scopes" db:"scopes" swaggertype:"array,string"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+type CreateTokenInput struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (i *CreateTokenInput) Validate() error {
+	if len(i.Scopes) == 0 {
+		return errors.New("token needs at least one scope")
+	}
+	for _, scope := range i.Scopes {
+		if !knownScopes[scope] {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	if i.ExpiresAt != nil && !i.ExpiresAt.After(time.Now()) {
+		return errors.New("expiry must be in the future")
+	}
+	return nil
+}
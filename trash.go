@@ -0,0 +1,33 @@
+package todo
+
+import "time"
+
+// TrashedList is a list in the trash. Its items come back with it when it
+// is restored.
+type TrashedList struct {
+	Id          int       `json:"id" db:"id"`
+	Title       string    `json:"title" db:"title"`
+	WorkspaceId int       `json:"workspace_id" db:"workspace_id"`
+	DeletedAt   time.Time `json:"deleted_at" db:"deleted_at"`
+	// PurgeAt is when the list is deleted for good.
+	PurgeAt time.Time `json:"purge_at" db:"-"`
+}
+
+// TrashedItem is an item deleted on its own, its subtasks deleted along
+// with it are not listed separately.
+type TrashedItem struct {
+	Id        int       `json:"id" db:"id"`
+	Title     string    `json:"title" db:"title"`
+	ListId    int       `json:"list_id" db:"list_id"`
+	ListTitle string    `json:"list_title" db:"list_title"`
+	ParentId  *int      `json:"parent_id" db:"parent_id"`
+	DeletedAt time.Time `json:"deleted_at" db:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at" db:"-"`
+}
+
+// Trash is what the user deleted and can still restore, the most recently
+// deleted first.
+type Trash struct {
+	Lists []TrashedList `json:"lists"`
+	Items []TrashedItem `json:"items"`
+}
@@ -1,8 +1,135 @@
 package todo
 
+import (
+	"errors"
+	"time"
+)
+
 type User struct {
-	Id       int    `json:"-" db:"id"`
-	Name     string `json:"name" binding:"required"`
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Id       int     `json:"-" db:"id"`
+	Name     string  `json:"name" binding:"required"`
+	Username string  `json:"username" binding:"required"`
+	Password string  `json:"password" binding:"required"`
+	Email    *string `json:"email" db:"email" binding:"omitempty,email"`
+	// TimeZone is an IANA zone name, due dates are grouped into days in it.
+	TimeZone string `json:"-" db:"time_zone"`
+
+	PasswordHash    string     `json:"-" db:"password_hash"`
+	EmailVerifiedAt *time.Time `json:"-" db:"email_verified_at"`
+	TOTPSecret      *string    `json:"-" db:"totp_secret"`
+	TOTPEnabled     bool       `json:"-" db:"totp_enabled"`
+	TOTPLastStep    *int64     `json:"-" db:"totp_last_step"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider.
+type UserIdentity struct {
+	Id        int       `json:"id" db:"id"`
+	UserId    int       `json:"-" db:"user_id"`
+	Provider  string    `json:"provider" db:"provider"`
+	Subject   string    `json:"subject" db:"subject"`
+	Email     *string   `json:"email" db:"email"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type OIDCLoginState struct {
+	State        string `db:"state"`
+	Provider     string `db:"provider"`
+	Nonce        string `db:"nonce"`
+	CodeVerifier string `db:"code_verifier"`
+	// LinkUserId is the signed-in user that started the flow to link an
+	// identity or, with Reauth, to prove it is them.
+	LinkUserId *int      `db:"link_user_id"`
+	Reauth     bool      `db:"reauth"`
+	ExpiresAt  time.Time `db:"expires_at"`
+}
+
+// Profile is the part of a user that is shown to the user themselves.
+type Profile struct {
+	Id               int     `json:"id" db:"id"`
+	Name             string  `json:"name" db:"name"`
+	Username         string  `json:"username" db:"username"`
+	Email            *string `json:"email" db:"email"`
+	EmailVerified    bool    `json:"email_verified"`
+	TwoFactorEnabled bool    `json:"two_factor_enabled"`
+	HasPassword      bool    `json:"has_password"`
+	TimeZone         string  `json:"time_zone" db:"time_zone"`
+}
+
+func NewProfile(user User) Profile {
+	return Profile{
+		Id:               user.Id,
+		Name:             user.Name,
+		Username:         user.Username,
+		Email:            user.Email,
+		EmailVerified:    user.EmailVerifiedAt != nil,
+		TwoFactorEnabled: user.TOTPEnabled,
+		HasPassword:      user.PasswordHash != "",
+		TimeZone:         user.TimeZone,
+	}
+}
+
+type UpdateProfileInput struct {
+	Name     *string `json:"name"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+	TimeZone *string `json:"time_zone"`
+}
+
+func (i *UpdateProfileInput) Validate() error {
+	if i.Name == nil && i.Email == nil && i.TimeZone == nil {
+		return errors.New("update profile structure has no values")
+	}
+	if i.TimeZone != nil {
+		// "Local" would be the zone of the server
+		if _, err := time.LoadLocation(*i.TimeZone); err != nil || *i.TimeZone == "" || *i.TimeZone == "Local" {
+			return errors.New("time_zone must be an IANA time zone such as Europe/Berlin")
+		}
+	}
+	if i.Name != nil && *i.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	if i.Email != nil && *i.Email == "" {
+		return errors.New("email must not be empty")
+	}
+	return nil
+}
+
+// Reauthentication proves it is the user themselves behind a session.
+// Users with a password give it, users that signed up through OpenID Connect
+// and never had one give a reauth_token from signing in with their identity
+// provider again or, with two-factor authentication, a current code.
+type Reauthentication struct {
+	ReauthToken string `json:"reauth_token"`
+	Code        string `json:"code"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required"`
+	Reauthentication
+}
+
+type DeleteAccountInput struct {
+	Password string `json:"password"`
+	Reauthentication
+}
+
+// AccountExport is everything stored about a user, handed out when the
+// account is deleted.
+type AccountExport struct {
+	Profile              Profile               `json:"profile"`
+	Lists                []ListExport          `json:"lists"`
+	Identities           []UserIdentity        `json:"identities"`
+	PersonalAccessTokens []PersonalAccessToken `json:"personal_access_tokens"`
+	ExportedAt           time.Time             `json:"exported_at"`
+}
+
+type ListExport struct {
+	TodoList
+	Items []TodoItem `json:"items"`
 }
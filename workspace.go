@@ -0,0 +1,42 @@
+package todo
+
+import (
+	"errors"
+	"time"
+)
+
+// Workspace owns lists. Its members can reach every list in it with their
+// workspace role, on top of lists shared with them individually. Every user
+// has a personal workspace that cannot be shared or deleted.
+type Workspace struct {
+	Id        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Personal  bool      `json:"personal" db:"personal"`
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type WorkspaceMember struct {
+	UserId   int    `json:"user_id" db:"user_id"`
+	Username string `json:"username" db:"username"`
+	Name     string `json:"name" db:"name"`
+	Role     string `json:"role" db:"role"`
+}
+
+type CreateWorkspaceInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type UpdateWorkspaceInput struct {
+	Name *string `json:"name"`
+}
+
+func (i *UpdateWorkspaceInput) Validate() error {
+	if i.Name == nil {
+		return errors.New("update structure has no values")
+	}
+	if *i.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	return nil
+}